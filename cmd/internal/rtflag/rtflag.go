@@ -0,0 +1,121 @@
+// Package rtflag parses the flags of the route table shared by the tools, so the tools open the same route table
+// with the same keys as the services.
+package rtflag
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable/etcd"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable/redis"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// Flags are the flags of the backend, the name, the ttl, the key format and the scopes of the route table
+type Flags struct {
+	backend   *string
+	redis     *string
+	password  *string
+	db        *int
+	etcd      *string
+	name      *string
+	ttl       *time.Duration
+	cluster   *bool
+	sidScope  *bool
+	sid       *int64
+	zoneScope *bool
+	zone      *uint
+}
+
+// Register defines the flags in the flag set
+func Register(fs *flag.FlagSet) *Flags {
+	return &Flags{
+		backend: fs.String("backend", "redis", "route table backend, redis or etcd. "+
+			"The sql backend is not supported because the tool is built without the database drivers, use the admin service instead"),
+		redis:     fs.String("redis", "127.0.0.1:6379", "comma separated redis addresses, two or more addresses are the seeds of the redis cluster"),
+		password:  fs.String("password", "", "redis password"),
+		db:        fs.Int("db", 0, "redis db, it's ignored by the redis cluster"),
+		etcd:      fs.String("etcd", "127.0.0.1:2379", "comma separated etcd endpoints"),
+		name:      fs.String("name", "", "route table name"),
+		ttl:       fs.Duration("ttl", time.Hour*24*7, "ttl of the routes set by the tool, it should be the same as the services"),
+		cluster:   fs.Bool("cluster-key", false, "use the cluster key format of the route table"),
+		sidScope:  fs.Bool("sid-scope", false, "the route table is scoped by sid"),
+		sid:       fs.Int64("sid", 0, "sid of the routes when the route table is scoped by sid"),
+		zoneScope: fs.Bool("zone-scope", false, "the route table is scoped by zone"),
+		zone:      fs.Uint("zone", 0, "zone of the routes when the route table is scoped by zone"),
+	}
+}
+
+// Name returns the name of the route table
+func (f *Flags) Name() string {
+	return *f.name
+}
+
+// Context returns the ctx of the route table calls, which carries the sid of the scope
+func (f *Flags) Context(ctx context.Context) context.Context {
+	return routetable.NewSIDContext(ctx, *f.sid)
+}
+
+// Open opens the backend and creates the route table, the closer closes the backend
+func (f *Flags) Open() (routetable.RouteTable, func(), error) {
+	if *f.name == "" {
+		return nil, nil, fmt.Errorf("name is required")
+	}
+
+	rtd, closer, err := f.openBackend()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	opts := []routetable.Option{routetable.WithTTL(*f.ttl)}
+	if *f.cluster {
+		opts = append(opts, routetable.WithKeyFormat(routetable.ClusterKeyFormat))
+	}
+	if *f.sidScope {
+		opts = append(opts, routetable.WithSIDScope())
+	}
+	if *f.zoneScope {
+		opts = append(opts, routetable.WithZone(uint32(*f.zone)))
+	}
+	return routetable.NewRouteTable(*f.name, rtd, opts...), closer, nil
+}
+
+func (f *Flags) openBackend() (routetable.RouteTableData, func(), error) {
+	switch *f.backend {
+	case "redis":
+		rdb := goredis.NewUniversalClient(&goredis.UniversalOptions{
+			Addrs:    split(*f.redis),
+			Password: *f.password,
+			DB:       *f.db,
+		})
+		return redis.NewRouteTable(rdb), func() { _ = rdb.Close() }, nil
+	case "etcd":
+		cli, err := clientv3.New(clientv3.Config{
+			Endpoints:   split(*f.etcd),
+			DialTimeout: time.Second * 5,
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("connect etcd failed: %w", err)
+		}
+		return etcd.NewRouteTable(cli), func() { _ = cli.Close() }, nil
+	case "sql":
+		return nil, nil, fmt.Errorf("the sql backend is not supported by the tool, use the admin service instead")
+	default:
+		return nil, nil, fmt.Errorf("unknown backend %s", *f.backend)
+	}
+}
+
+func split(s string) []string {
+	var result []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
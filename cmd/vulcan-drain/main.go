@@ -0,0 +1,73 @@
+// Command vulcan-drain drains a node by moving its routes to the other nodes of the same color.
+// The route table scoped by sid is drained one sid at a time, see -sid-scope and -sid.
+// The flags of the route table are the same as vulcan-rt, the moved routes are set with -ttl.
+//
+// Usage:
+//
+//	vulcan-drain -redis 127.0.0.1:6379 -name player -ttl 168h -color blue -addr 10.0.2.31:9000 -targets 10.0.2.32:9000,10.0.2.33:9000
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/vulcan-frame/vulcan-pkg-app/cmd/internal/rtflag"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/drain"
+)
+
+var (
+	rtFlags      = rtflag.Register(flag.CommandLine)
+	flagColor    = flag.String("color", "", "color of the node")
+	flagAddr     = flag.String("addr", "", "address of the draining node")
+	flagTargets  = flag.String("targets", "", "comma separated addresses of the target nodes")
+	flagBatch    = flag.Int64("batch", 100, "count of the routes moved in a batch")
+	flagInterval = flag.Duration("interval", time.Second, "pause between two batches")
+	flagUndrain  = flag.Bool("undrain", false, "unmark the node as draining instead of draining it")
+)
+
+func main() {
+	flag.Parse()
+
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	if *flagAddr == "" {
+		return fmt.Errorf("addr is required")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx = rtFlags.Context(ctx)
+
+	rt, closer, err := rtFlags.Open()
+	if err != nil {
+		return err
+	}
+	defer closer()
+	d := drain.New(rt, drain.WithBatchSize(*flagBatch), drain.WithInterval(*flagInterval))
+
+	if *flagUndrain {
+		return d.Undrain(ctx, *flagColor, *flagAddr)
+	}
+
+	var targets []string
+	for _, t := range strings.Split(*flagTargets, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			targets = append(targets, t)
+		}
+	}
+
+	result, err := d.Drain(ctx, *flagColor, *flagAddr, targets)
+	fmt.Printf("moved=%d skipped=%d failed=%d\n", result.Moved, result.Skipped, result.Failed)
+	return err
+}
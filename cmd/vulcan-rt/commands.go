@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	verrors "github.com/vulcan-frame/vulcan-pkg-app/errors"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+)
+
+const (
+	scanPageSize = 1000
+)
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+type commander struct {
+	rt  routetable.ObjectRouteTable
+	out *printer
+}
+
+func (c *commander) get(ctx context.Context, args []string) error {
+	color, oid, err := parseRoute(args, 2)
+	if err != nil {
+		return err
+	}
+
+	entry, err := c.rt.LoadEntryObject(ctx, color, oid)
+	if err != nil {
+		return err
+	}
+	_, gen, err := c.rt.LoadGenObject(ctx, color, oid)
+	if err != nil {
+		return err
+	}
+
+	c.out.header("color", "oid", "addr", "node", "version", "assigned_at", "gen")
+	c.out.row(color, oid, entry.Addr, entry.Node, entry.Version, formatTime(entry.AssignedAt), gen)
+	return nil
+}
+
+func (c *commander) set(ctx context.Context, args []string) error {
+	color, oid, err := parseRoute(args, 3)
+	if err != nil {
+		return err
+	}
+
+	old, gen, err := c.rt.GetSetGenObject(ctx, color, oid, args[2])
+	if err != nil && !errors.Is(err, verrors.ErrRouteTableNotFound) {
+		return err
+	}
+	c.out.header("color", "oid", "old", "new", "gen")
+	c.out.row(color, oid, old, args[2], gen)
+	return nil
+}
+
+func (c *commander) del(ctx context.Context, args []string) error {
+	color, oid, err := parseRoute(args, 2)
+	if err != nil {
+		return err
+	}
+	return c.rt.DelObject(ctx, color, oid)
+}
+
+func (c *commander) delIfSame(ctx context.Context, args []string) error {
+	color, oid, err := parseRoute(args, 3)
+	if err != nil {
+		return err
+	}
+	return c.rt.DelIfSameObject(ctx, color, oid, args[2])
+}
+
+func (c *commander) scan(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("scan", flag.ContinueOnError)
+	addr := fs.String("addr", "", "address of the node")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *addr == "" || fs.NArg() != 1 {
+		return fmt.Errorf("usage: scan -addr <addr> <color>")
+	}
+	color := fs.Arg(0)
+
+	c.out.header("color", "oid", "addr")
+	var cursor string
+	for {
+		oids, next, err := c.rt.ListObjectsByAddr(ctx, color, *addr, cursor, scanPageSize)
+		if err != nil {
+			return err
+		}
+		for _, oid := range oids {
+			c.out.row(color, oid, *addr)
+		}
+		if next == "" {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// list prints the routes of the color
+func (c *commander) list(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: list <color>")
+	}
+	color := args[0]
+
+	r, ok := c.rt.(routetable.Ranger)
+	if !ok {
+		return fmt.Errorf("the route table does not support range")
+	}
+
+	c.out.header("color", "oid", "addr", "node", "version", "assigned_at")
+	return r.Range(ctx, color, func(oids []string, entries []routetable.RouteEntry) error {
+		for i, oid := range oids {
+			e := entries[i]
+			c.out.row(color, oid, e.Addr, e.Node, e.Version, formatTime(e.AssignedAt))
+		}
+		return nil
+	})
+}
+
+// export writes the snapshot of the color to stdout or the file
+func (c *commander) export(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", "json", "snapshot format, json (json lines) or proto (length delimited protobuf)")
+	file := fs.String("file", "", "snapshot file, the default is stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: export [-format json|proto] [-file path] <color>")
+	}
+
+	r, ok := c.rt.(routetable.Exporter)
+	if !ok {
+		return fmt.Errorf("the route table does not support export")
+	}
+
+	var out io.Writer = os.Stdout
+	if *file != "" {
+		f, err := os.Create(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	var w routetable.SnapshotWriter
+	switch *format {
+	case "json":
+		w = routetable.NewJSONSnapshotWriter(out)
+	case "proto":
+		w = routetable.NewProtoSnapshotWriter(out)
+	default:
+		return fmt.Errorf("unknown snapshot format %s", *format)
+	}
+
+	n, err := r.Export(ctx, fs.Arg(0), w)
+	fmt.Fprintf(os.Stderr, "exported=%d\n", n)
+	return err
+}
+
+// importRoutes restores the snapshot of export to the color from stdin or the file
+func (c *commander) importRoutes(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := fs.String("format", "json", "snapshot format, json (json lines) or proto (length delimited protobuf)")
+	mode := fs.String("mode", "missing", "overwrite the existing routes, or only set the missing ones")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		return fmt.Errorf("usage: import [-format json|proto] [-mode overwrite|missing] <color> [file]")
+	}
+
+	r, ok := c.rt.(routetable.Importer)
+	if !ok {
+		return fmt.Errorf("the route table does not support import")
+	}
+
+	var importMode routetable.ImportMode
+	switch *mode {
+	case "overwrite":
+		importMode = routetable.ImportOverwrite
+	case "missing":
+		importMode = routetable.ImportOnlyMissing
+	default:
+		return fmt.Errorf("unknown import mode %s", *mode)
+	}
+
+	var in io.Reader = os.Stdin
+	if fs.NArg() == 2 && fs.Arg(1) != "-" {
+		f, err := os.Open(fs.Arg(1))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	var rd routetable.SnapshotReader
+	switch *format {
+	case "json":
+		rd = routetable.NewJSONSnapshotReader(in)
+	case "proto":
+		rd = routetable.NewProtoSnapshotReader(in)
+	default:
+		return fmt.Errorf("unknown snapshot format %s", *format)
+	}
+
+	result, err := r.Import(ctx, fs.Arg(0), rd, importMode)
+	c.out.header("imported", "skipped")
+	c.out.row(result.Imported, result.Skipped)
+	return err
+}
+
+func (c *commander) ttl(ctx context.Context, args []string) error {
+	if len(args) == 3 {
+		color, oid, err := parseRoute(args, 3)
+		if err != nil {
+			return err
+		}
+		dur, err := time.ParseDuration(args[2])
+		if err != nil {
+			return fmt.Errorf("duration illegal: %w", err)
+		}
+		if dur <= 0 {
+			return fmt.Errorf("duration must be positive, use del to delete the route")
+		}
+		if _, err := c.rt.LoadObject(ctx, color, oid); err != nil {
+			return err
+		}
+		return c.rt.DelDelayObject(ctx, color, oid, dur)
+	}
+
+	color, oid, err := parseRoute(args, 2)
+	if err != nil {
+		return err
+	}
+	r, ok := c.rt.(routetable.TTLReader)
+	if !ok {
+		return fmt.Errorf("the route table does not support reading the ttl")
+	}
+	ttl, err := r.TTLObject(ctx, color, oid)
+	if err != nil {
+		return err
+	}
+
+	c.out.header("color", "oid", "ttl")
+	c.out.row(color, oid, ttl.Round(time.Millisecond).String())
+	return nil
+}
+
+// mergeSID moves the routes of the color from a sid to another for the server merge
+func (c *commander) mergeSID(ctx context.Context, args []string) error {
+	if len(args) != 3 {
+		return fmt.Errorf("usage: merge-sid <color> <from> <to>")
+	}
+	from, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("from must be int64: %w", err)
+	}
+	to, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return fmt.Errorf("to must be int64: %w", err)
+	}
+
+	r, ok := c.rt.(routetable.SIDMerger)
+	if !ok {
+		return fmt.Errorf("the route table does not support merging sids")
+	}
+
+	result, err := r.MergeSID(ctx, args[0], from, to)
+	c.out.header("merged", "conflicts")
+	c.out.row(result.Merged, result.Conflicts)
+	return err
+}
+
+// migrateScope moves the routes of the color written before the route table is scoped to the sid and zone of the flags
+func (c *commander) migrateScope(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: migrate-scope <color>")
+	}
+
+	r, ok := c.rt.(routetable.ScopeMigrator)
+	if !ok {
+		return fmt.Errorf("the route table does not support migrating to the scope")
+	}
+
+	n, err := r.MigrateToScope(ctx, args[0])
+	c.out.header("migrated")
+	c.out.row(n)
+	return err
+}
+
+// parseRoute parses the color and the oid from the args, n is the required count of the args.
+// The oid is the object string of the route, the int64 oids are in decimal.
+func parseRoute(args []string, n int) (string, string, error) {
+	if len(args) != n {
+		return "", "", fmt.Errorf("%d args are required, got %d", n, len(args))
+	}
+	if args[1] == "" {
+		return "", "", fmt.Errorf("oid is empty")
+	}
+	return args[0], args[1], nil
+}
@@ -0,0 +1,116 @@
+// Command vulcan-rt inspects and fixes the routes of a route table. The keys are built by routetable.NewRouteTable,
+// so they are always the same as the services.
+//
+// The oid is the object string of the route, the int64 oids are in decimal.
+//
+// Usage:
+//
+//	vulcan-rt [flags] get <color> <oid>
+//	vulcan-rt [flags] set <color> <oid> <addr>
+//	vulcan-rt [flags] del <color> <oid>
+//	vulcan-rt [flags] del-if-same <color> <oid> <addr>
+//	vulcan-rt [flags] scan -addr <addr> <color>
+//	vulcan-rt [flags] list <color>
+//	vulcan-rt [flags] export [-format json|proto] [-file path] <color>
+//	vulcan-rt [flags] import [-format json|proto] [-mode overwrite|missing] <color> [file]
+//	vulcan-rt [flags] ttl <color> <oid> [duration]
+//	vulcan-rt -sid-scope [flags] merge-sid <color> <from> <to>
+//	vulcan-rt -sid-scope|-zone-scope [flags] migrate-scope <color>
+//
+// Example:
+//
+//	vulcan-rt -redis 127.0.0.1:6379 -name player -o json get blue 10001
+//	vulcan-rt -redis 10.0.1.1:6379,10.0.1.2:6379 -cluster-key -name player list blue
+//
+// The backend is redis or etcd. The sql backend is not supported because the tool is built without the database
+// drivers, use the admin service of the services instead.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/vulcan-frame/vulcan-pkg-app/cmd/internal/rtflag"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+)
+
+var (
+	rtFlags     = rtflag.Register(flag.CommandLine)
+	flagOutput  = flag.String("o", "table", "output format, table or json (json lines)")
+	flagTimeout = flag.Duration("timeout", time.Minute, "timeout of the command")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: vulcan-rt [flags] get|set|del|del-if-same|scan|list|export|import|ttl|merge-sid|migrate-scope args...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	if flag.NArg() == 0 {
+		flag.Usage()
+		return fmt.Errorf("command is required")
+	}
+	if *flagOutput != "table" && *flagOutput != "json" {
+		return fmt.Errorf("unknown output format %s", *flagOutput)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, *flagTimeout)
+	defer cancel()
+	ctx = rtFlags.Context(ctx)
+
+	rt, closer, err := rtFlags.Open()
+	if err != nil {
+		return err
+	}
+	defer closer()
+
+	c := &commander{
+		rt:  routetable.Objects(rt),
+		out: newPrinter(os.Stdout, *flagOutput),
+	}
+	defer c.out.flush()
+
+	cmd, args := flag.Arg(0), flag.Args()[1:]
+	switch cmd {
+	case "get":
+		return c.get(ctx, args)
+	case "set":
+		return c.set(ctx, args)
+	case "del":
+		return c.del(ctx, args)
+	case "del-if-same":
+		return c.delIfSame(ctx, args)
+	case "scan":
+		return c.scan(ctx, args)
+	case "list":
+		return c.list(ctx, args)
+	case "export":
+		return c.export(ctx, args)
+	case "import":
+		return c.importRoutes(ctx, args)
+	case "ttl":
+		return c.ttl(ctx, args)
+	case "merge-sid":
+		return c.mergeSID(ctx, args)
+	case "migrate-scope":
+		return c.migrateScope(ctx, args)
+	default:
+		flag.Usage()
+		return fmt.Errorf("unknown command %s", cmd)
+	}
+}
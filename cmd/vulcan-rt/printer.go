@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// printer prints the rows as a table or json lines
+type printer struct {
+	json bool
+	cols []string
+	tw   *tabwriter.Writer
+	enc  *json.Encoder
+}
+
+func newPrinter(w io.Writer, format string) *printer {
+	return &printer{
+		json: format == "json",
+		tw:   tabwriter.NewWriter(w, 0, 4, 2, ' ', 0),
+		enc:  json.NewEncoder(w),
+	}
+}
+
+// header sets the columns of the rows, the table header is printed immediately
+func (p *printer) header(cols ...string) {
+	p.cols = cols
+	if !p.json {
+		fmt.Fprintln(p.tw, strings.ToUpper(strings.Join(cols, "\t")))
+	}
+}
+
+func (p *printer) row(vals ...any) {
+	if p.json {
+		m := make(map[string]any, len(vals))
+		for i, v := range vals {
+			m[p.cols[i]] = v
+		}
+		_ = p.enc.Encode(m)
+		return
+	}
+
+	strs := make([]string, len(vals))
+	for i, v := range vals {
+		strs[i] = fmt.Sprint(v)
+	}
+	fmt.Fprintln(p.tw, strings.Join(strs, "\t"))
+}
+
+func (p *printer) flush() {
+	_ = p.tw.Flush()
+}
@@ -73,6 +73,59 @@ func OID(ctx context.Context) (int64, error) {
 	return id, nil
 }
 
+// ObjectKey is the composite oid of an object in the module, such as the guild UUID or the room code.
+// It's routed by the string "module:id", or the id if the module is empty, so the module must not contain ":".
+type ObjectKey struct {
+	Module string
+	ID     string
+}
+
+func (k ObjectKey) String() string {
+	if k.Module == "" {
+		return k.ID
+	}
+	return k.Module + ":" + k.ID
+}
+
+// ParseObjectKey parses the string of ObjectKey, the module is the part before the first ":"
+func ParseObjectKey(s string) ObjectKey {
+	module, id, ok := strings.Cut(s, ":")
+	if !ok {
+		return ObjectKey{ID: s}
+	}
+	return ObjectKey{Module: module, ID: id}
+}
+
+// SetOIDString sets the opaque string oid, the int64 oid of SetOID is the same as its decimal string
+func SetOIDString(ctx context.Context, id string) context.Context {
+	return metadata.AppendToClientContext(ctx, CtxOID, id)
+}
+
+func OIDString(ctx context.Context) (string, error) {
+	md, ok := metadata.FromServerContext(ctx)
+	if !ok {
+		return "", errors.New("metadata not in context")
+	}
+
+	str := md.Get(CtxOID)
+	if str == "" {
+		return "", errors.New("oid not in metadata")
+	}
+	return str, nil
+}
+
+func SetObjectKey(ctx context.Context, key ObjectKey) context.Context {
+	return SetOIDString(ctx, key.String())
+}
+
+func OIDKey(ctx context.Context) (ObjectKey, error) {
+	str, err := OIDString(ctx)
+	if err != nil {
+		return ObjectKey{}, err
+	}
+	return ParseObjectKey(str), nil
+}
+
 func SetSID(ctx context.Context, id int64) context.Context {
 	return metadata.AppendToClientContext(ctx, CtxSID, strconv.FormatInt(id, 10))
 }
@@ -9,6 +9,9 @@ import (
 // Route table errors
 var (
 	ErrRouteTableNotFound = errors.New("route table not found")
+	ErrRouteTableDegraded = errors.New("route table degraded")
+	// ErrRouteTableUnsupported is returned when the backend doesn't implement the optional interface of the operation
+	ErrRouteTableUnsupported = errors.New("route table operation unsupported")
 )
 
 // Tunnel errors
@@ -0,0 +1,70 @@
+package balancer
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/selector"
+	"github.com/pkg/errors"
+)
+
+// assignment is the result of an assignment shared by the concurrent picks of the route
+type assignment struct {
+	addr     string
+	degraded bool // the route table failed and the degraded mode is entered
+}
+
+func (k routeKey) String() string {
+	return strconv.FormatInt(k.sid, 10) + "\x00" + k.color + "\x00" + k.oid
+}
+
+// assign assigns a node to the missing route. The concurrent picks of the route in the process
+// wait for the SetNx of the first one instead of racing in the route table, such as in the login storms.
+func (p *Balancer) assign(ctx context.Context, key routeKey, nodes []selector.WeightedNode) (selector.WeightedNode, selector.DoneFunc, error) {
+	var selected selector.WeightedNode // set only in the pick which runs the assignment
+	v, err, _ := p.assigns.Do(key.String(), func() (any, error) {
+		// the result is shared, so the assignment is not canceled with the ctx of the first pick
+		ctx := context.WithoutCancel(ctx)
+
+		// the draining nodes keep serving the routed objects, but they are not assigned new ones
+		candidates := p.filterDraining(ctx, key.color, nodes)
+		// the assignment made in the degraded mode is kept until it is written back
+		selected = findNode(candidates, p.degrader.pendingAddr(key))
+		if selected == nil {
+			selected = p.pickWeighted(candidates)
+		}
+
+		// the route table may be set by other processes, so it's set only if it's still empty
+		ok, result, err := p.routeTable.SetNxEntryObject(ctx, key.color, key.oid, newRouteEntry(selected))
+		if err != nil {
+			if p.degrader.failure(err) {
+				return assignment{degraded: true}, nil
+			}
+			return nil, err
+		}
+		p.degrader.remember(key, result.Addr)
+		if !ok {
+			// the conflict is an expected race of the processes, it is counted by the instrumented route table
+			log.Debugf("routeTable is set by other connections. oid=%s color=%s oldConn=%s newConn=%s", key.oid, key.color, result.Addr, selected.Address())
+			selected = nil
+		}
+		return assignment{addr: result.Addr}, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	a := v.(assignment)
+	if a.degraded {
+		return p.pickDegraded(key, nodes)
+	}
+	if selected != nil {
+		// the route table is set by this pick
+		return selected, selected.Pick(), nil
+	}
+	if node := findNode(nodes, a.addr); node != nil {
+		return node, nil, nil
+	}
+	return nil, nil, errors.Errorf("the existed connection in routeTable is not found. oid=%s color=%s oldConn=%s", key.oid, key.color, a.addr)
+}
@@ -0,0 +1,70 @@
+package balancer
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+)
+
+// slowRouteTable counts the SetNx calls and blocks them until released
+type slowRouteTable struct {
+	*flakyRouteTable
+	setNx   atomic.Int32
+	release chan struct{}
+}
+
+func (rt *slowRouteTable) SetNxEntryObject(ctx context.Context, color string, oid string, entry routetable.RouteEntry) (bool, routetable.RouteEntry, error) {
+	rt.setNx.Add(1)
+	<-rt.release
+	return rt.flakyRouteTable.SetNxEntryObject(ctx, color, oid, entry)
+}
+
+func TestBalancer_AssignSingleFlight(t *testing.T) {
+	rt := &slowRouteTable{flakyRouteTable: newFlakyRouteTable(t), release: make(chan struct{})}
+	p := newTestBalancer(rt, DegradeNone)
+	nodes := testNodes("a:1", "b:1", "c:1")
+
+	const n = 16
+	addrs := make([]string, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// a canceled pick does not cancel the shared assignment
+			ctx, cancel := context.WithCancel(oidCtx(1))
+			if i == 0 {
+				cancel()
+			}
+			defer cancel()
+
+			node, _, err := p.Pick(ctx, nodes)
+			if assert.NoError(t, err) {
+				addrs[i] = node.Address()
+			}
+		}()
+	}
+
+	time.Sleep(time.Millisecond * 50)
+	close(rt.release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), rt.setNx.Load())
+	stored, err := rt.Load(context.Background(), "red", 1)
+	require.NoError(t, err)
+	for _, addr := range addrs {
+		assert.Equal(t, stored, addr)
+	}
+
+	// the next assignment of the route is not shared
+	node, _, err := p.Pick(oidCtx(2), nodes)
+	require.NoError(t, err)
+	assert.NotEmpty(t, node.Address())
+	assert.Equal(t, int32(2), rt.setNx.Load())
+}
@@ -0,0 +1,393 @@
+package balancer
+
+import (
+	"context"
+	"hash/fnv"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/selector"
+	"github.com/pkg/errors"
+	verrors "github.com/vulcan-frame/vulcan-pkg-app/errors"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+// DegradePolicy is how the balancer picks the nodes when the route table backend is unavailable
+type DegradePolicy string
+
+const (
+	// DegradeNone returns the errors of the route table, it is the default
+	DegradeNone DegradePolicy = ""
+	// DegradeFailFast fails the picks without calling the route table until the backend recovers
+	DegradeFailFast DegradePolicy = "failfast"
+	// DegradeSnapshot serves the routes from the local snapshot of the recent picks, the others fail
+	DegradeSnapshot DegradePolicy = "snapshot"
+	// DegradeHash serves the routes from the local snapshot, the others are picked by the consistent hashing on oid.
+	// The assignments of the master are written back to the route table when the backend recovers.
+	DegradeHash DegradePolicy = "hash"
+)
+
+const (
+	defaultFailureThreshold = 5
+	defaultCooldown         = time.Second * 5
+	defaultSnapshotSize     = 1 << 16
+	defaultSnapshotTTL      = time.Minute
+	reconcileTimeout        = time.Second * 30
+
+	meterName = "github.com/vulcan-frame/vulcan-pkg-app/router/balancer"
+)
+
+type DegradeOption func(d *degrader)
+
+// WithFailureThreshold sets the count of the consecutive backend failures to enter the degraded mode
+func WithFailureThreshold(n int) DegradeOption {
+	return func(d *degrader) {
+		d.threshold = n
+	}
+}
+
+// WithCooldown sets the interval of probing the backend in the degraded mode
+func WithCooldown(dur time.Duration) DegradeOption {
+	return func(d *degrader) {
+		d.cooldown = dur
+	}
+}
+
+// WithSnapshotSize sets the max count of the routes in the local snapshot
+func WithSnapshotSize(n int) DegradeOption {
+	return func(d *degrader) {
+		d.snapshotSize = n
+	}
+}
+
+// WithSnapshotTTL sets how long a route in the local snapshot is served in the degraded mode
+func WithSnapshotTTL(dur time.Duration) DegradeOption {
+	return func(d *degrader) {
+		d.snapshotTTL = dur
+	}
+}
+
+// WithDegrade sets the policy of the degraded mode.
+// The balancer enters the degraded mode after the consecutive backend failures, probes the backend once per cooldown,
+// and leaves the degraded mode when the probe succeeds.
+func WithDegrade(policy DegradePolicy, opts ...DegradeOption) Option {
+	return func(o *options) {
+		o.degradePolicy = policy
+		o.degradeOpts = opts
+	}
+}
+
+// routeKey is the route of the balancer, the sid is the scope of the route table, see routetable.WithSIDScope
+type routeKey struct {
+	sid   int64
+	color string
+	oid   string
+}
+
+type snapshotEntry struct {
+	addr string
+	at   time.Time
+}
+
+// degrader is the circuit breaker of the route table shared by the balancers of a builder
+type degrader struct {
+	policy       DegradePolicy
+	rt           routetable.ObjectRouteTable
+	threshold    int
+	cooldown     time.Duration
+	snapshotSize int
+	snapshotTTL  time.Duration
+
+	mu       sync.Mutex
+	failures int
+	open     bool
+	openedAt time.Time
+	probing  bool
+	snapshot map[routeKey]snapshotEntry
+	pending  map[routeKey]routetable.RouteEntry // the assignments made in the degraded mode
+
+	reconciling bool
+
+	degradedGauge metric.Int64UpDownCounter
+	picks         metric.Int64Counter
+	reconciled    metric.Int64Counter
+}
+
+func newDegrader(policy DegradePolicy, rt routetable.ObjectRouteTable, opts ...DegradeOption) *degrader {
+	if policy == DegradeNone {
+		return nil
+	}
+
+	d := &degrader{
+		policy:       policy,
+		rt:           rt,
+		threshold:    defaultFailureThreshold,
+		cooldown:     defaultCooldown,
+		snapshotSize: defaultSnapshotSize,
+		snapshotTTL:  defaultSnapshotTTL,
+		snapshot:     make(map[routeKey]snapshotEntry),
+		pending:      make(map[routeKey]routetable.RouteEntry),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	d.initMetrics()
+	return d
+}
+
+func (d *degrader) initMetrics() {
+	meter := otel.Meter(meterName)
+	var err error
+	if d.degradedGauge, err = meter.Int64UpDownCounter("router.balancer.degraded",
+		metric.WithDescription("Whether the balancer is in the degraded mode")); err != nil {
+		log.Errorf("create router.balancer.degraded metric failed. err=%+v", err)
+		d.degradedGauge = noop.Int64UpDownCounter{}
+	}
+	if d.picks, err = meter.Int64Counter("router.balancer.degraded.picks",
+		metric.WithDescription("The count of the picks served in the degraded mode")); err != nil {
+		log.Errorf("create router.balancer.degraded.picks metric failed. err=%+v", err)
+		d.picks = noop.Int64Counter{}
+	}
+	if d.reconciled, err = meter.Int64Counter("router.balancer.reconciled",
+		metric.WithDescription("The count of the degraded assignments written back to the route table")); err != nil {
+		log.Errorf("create router.balancer.reconciled metric failed. err=%+v", err)
+		d.reconciled = noop.Int64Counter{}
+	}
+}
+
+// allow returns whether the route table should be called, it is false in the degraded mode except for the probe
+func (d *degrader) allow() bool {
+	if d == nil {
+		return true
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.open {
+		return true
+	}
+	if d.probing || time.Since(d.openedAt) < d.cooldown {
+		return false
+	}
+	d.probing = true
+	return true
+}
+
+func (d *degrader) success() {
+	if d == nil {
+		return
+	}
+
+	d.mu.Lock()
+	d.failures = 0
+	d.probing = false
+	if !d.open {
+		d.mu.Unlock()
+		return
+	}
+	d.open = false
+	// the pending assignments are kept until written back, the master keeps assigning them before that
+	pending := len(d.pending)
+	d.mu.Unlock()
+
+	log.Infof("route table recovered, leave the degraded mode. policy=%s pending=%d", d.policy, pending)
+	d.degradedGauge.Add(context.Background(), -1, metric.WithAttributes(attribute.String("policy", string(d.policy))))
+	if pending > 0 {
+		go d.reconcile()
+	}
+}
+
+// failure counts the backend failure, it returns whether the pick should be served in the degraded mode,
+// the fail fast policy returns the errors until the degraded mode is entered
+func (d *degrader) failure(err error) bool {
+	if d == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) {
+		// the call canceled by the caller tells nothing about the backend, but the probe is released for the next pick
+		d.mu.Lock()
+		d.probing = false
+		d.mu.Unlock()
+		return false
+	}
+
+	d.mu.Lock()
+	d.failures++
+	d.probing = false
+	entered := false
+	if d.open {
+		d.openedAt = time.Now()
+	} else if d.failures >= d.threshold {
+		d.open = true
+		d.openedAt = time.Now()
+		entered = true
+	}
+	d.mu.Unlock()
+
+	if entered {
+		log.Warnf("route table unavailable, enter the degraded mode. policy=%s failures=%d err=%+v", d.policy, d.threshold, err)
+		d.degradedGauge.Add(context.Background(), 1, metric.WithAttributes(attribute.String("policy", string(d.policy))))
+	}
+	return d.policy != DegradeFailFast
+}
+
+// remember records the route of a successful pick in the snapshot
+func (d *degrader) remember(key routeKey, addr string) {
+	if d == nil || d.policy == DegradeFailFast {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.rememberLocked(key, addr)
+}
+
+func (d *degrader) rememberLocked(key routeKey, addr string) {
+	if _, ok := d.snapshot[key]; !ok && len(d.snapshot) >= d.snapshotSize {
+		// evict an arbitrary route, the snapshot is a best effort
+		for k := range d.snapshot {
+			delete(d.snapshot, k)
+			break
+		}
+	}
+	d.snapshot[key] = snapshotEntry{addr: addr, at: time.Now()}
+}
+
+// pick picks the node in the degraded mode
+func (d *degrader) pick(key routeKey, nodes []selector.WeightedNode, balancerType BalancerType) (selector.WeightedNode, error) {
+	result := "failfast"
+	defer func() {
+		d.picks.Add(context.Background(), 1, metric.WithAttributes(
+			attribute.String("policy", string(d.policy)), attribute.String("result", result)))
+	}()
+
+	if d.policy == DegradeFailFast {
+		return nil, errors.Wrapf(verrors.ErrRouteTableDegraded, "oid=%s color=%s sid=%d", key.oid, key.color, key.sid)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if entry, ok := d.snapshot[key]; ok && time.Since(entry.at) < d.snapshotTTL {
+		for _, node := range nodes {
+			if node.Address() == entry.addr {
+				result = "snapshot"
+				return node, nil
+			}
+		}
+	}
+
+	if d.policy != DegradeHash {
+		result = "miss"
+		return nil, errors.Wrapf(verrors.ErrRouteTableDegraded, "route not in snapshot. oid=%s color=%s sid=%d", key.oid, key.color, key.sid)
+	}
+
+	result = "hash"
+	selected := hashPick(key.oid, nodes)
+	if balancerType == BalancerTypeMaster {
+		d.rememberLocked(key, selected.Address())
+		if _, ok := d.pending[key]; ok || len(d.pending) < d.snapshotSize {
+			d.pending[key] = newRouteEntry(selected)
+		} else {
+			log.Warnf("too many degraded assignments, the assignment is not written back. oid=%s color=%s sid=%d addr=%s", key.oid, key.color, key.sid, selected.Address())
+		}
+	}
+	return selected, nil
+}
+
+// reconcile writes back the assignments made in the degraded mode, the routes assigned by others are kept.
+// The assignments failed to be written back are retried after the cooldown, until the degraded mode is entered again,
+// in which case they are written back by the next recovery.
+func (d *degrader) reconcile() {
+	d.mu.Lock()
+	if d.reconciling || d.open || len(d.pending) == 0 {
+		d.mu.Unlock()
+		return
+	}
+	d.reconciling = true
+	pending := maps.Clone(d.pending)
+	d.mu.Unlock()
+
+	defer func() {
+		d.mu.Lock()
+		d.reconciling = false
+		retry := !d.open && len(d.pending) > 0
+		d.mu.Unlock()
+		if retry {
+			time.AfterFunc(d.cooldown, d.reconcile)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+
+	var ok, conflict, failed int64
+	for key, entry := range pending {
+		set, result, err := d.rt.SetNxEntryObject(routetable.NewSIDContext(ctx, key.sid), key.color, key.oid, entry)
+		switch {
+		case err != nil:
+			// the assignment is kept for the retry
+			failed++
+			log.Errorf("reconcile the degraded assignment failed. oid=%s color=%s sid=%d addr=%s err=%+v", key.oid, key.color, key.sid, entry.Addr, err)
+		case set:
+			ok++
+			d.done(key, entry)
+		default:
+			conflict++
+			d.done(key, entry)
+			log.Warnf("the degraded assignment conflicts with the route table. oid=%s color=%s sid=%d degraded=%s current=%s", key.oid, key.color, key.sid, entry.Addr, result.Addr)
+			d.remember(key, result.Addr)
+		}
+	}
+
+	d.reconciled.Add(ctx, ok, metric.WithAttributes(attribute.String("result", "ok")))
+	d.reconciled.Add(ctx, conflict, metric.WithAttributes(attribute.String("result", "conflict")))
+	d.reconciled.Add(ctx, failed, metric.WithAttributes(attribute.String("result", "error")))
+	log.Infof("reconcile the degraded assignments done. ok=%d conflict=%d error=%d", ok, conflict, failed)
+}
+
+// done removes the written back assignment unless it is assigned again
+func (d *degrader) done(key routeKey, entry routetable.RouteEntry) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.pending[key] == entry {
+		delete(d.pending, key)
+	}
+}
+
+// pendingAddr returns the address assigned in the degraded mode which is not written back yet
+func (d *degrader) pendingAddr(key routeKey) string {
+	if d == nil {
+		return ""
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.pending[key].Addr
+}
+
+// hashPick picks the node by the rendezvous hashing on oid, only the objects on the removed node move when the nodes change
+func hashPick(oid string, nodes []selector.WeightedNode) selector.WeightedNode {
+	var (
+		selected selector.WeightedNode
+		maxScore uint64
+	)
+	for _, node := range nodes {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(node.Address()))
+		_, _ = h.Write([]byte(oid))
+		if score := h.Sum64(); selected == nil || score > maxScore {
+			selected, maxScore = node, score
+		}
+	}
+	return selected
+}
@@ -0,0 +1,190 @@
+package balancer
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/metadata"
+	"github.com/go-kratos/kratos/v2/registry"
+	"github.com/go-kratos/kratos/v2/selector"
+	"github.com/go-kratos/kratos/v2/selector/node/direct"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	vctx "github.com/vulcan-frame/vulcan-pkg-app/context"
+	verrors "github.com/vulcan-frame/vulcan-pkg-app/errors"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable/memory"
+)
+
+var errUnavailable = errors.New("backend unavailable")
+
+// flakyRouteTable fails the calls of the balancer when it is down
+type flakyRouteTable struct {
+	*routetable.BaseRouteTable
+	down atomic.Bool
+}
+
+func (rt *flakyRouteTable) LoadAndExpireObject(ctx context.Context, color string, oid string) (string, error) {
+	if rt.down.Load() {
+		return "", errUnavailable
+	}
+	return rt.BaseRouteTable.LoadAndExpireObject(ctx, color, oid)
+}
+
+func (rt *flakyRouteTable) SetNxEntryObject(ctx context.Context, color string, oid string, entry routetable.RouteEntry) (bool, routetable.RouteEntry, error) {
+	if rt.down.Load() {
+		return false, routetable.RouteEntry{}, errUnavailable
+	}
+	return rt.BaseRouteTable.SetNxEntryObject(ctx, color, oid, entry)
+}
+
+func (rt *flakyRouteTable) Draining(ctx context.Context, color string, addrs []string) ([]bool, error) {
+	return make([]bool, len(addrs)), nil
+}
+
+func newFlakyRouteTable(t *testing.T) *flakyRouteTable {
+	data := memory.NewRouteTable()
+	t.Cleanup(data.Close)
+	return &flakyRouteTable{BaseRouteTable: routetable.New(data, "test")}
+}
+
+func testNodes(addrs ...string) []selector.WeightedNode {
+	b := &direct.Builder{}
+	nodes := make([]selector.WeightedNode, len(addrs))
+	for i, addr := range addrs {
+		nodes[i] = b.Build(selector.NewNode("grpc", addr, &registry.ServiceInstance{ID: addr, Metadata: map[string]string{}}))
+	}
+	return nodes
+}
+
+func oidCtx(oid int64) context.Context {
+	md := metadata.New()
+	md.Set(vctx.CtxOID, strconv.FormatInt(oid, 10))
+	md.Set(vctx.CtxColor, "red")
+	return metadata.NewServerContext(context.Background(), md)
+}
+
+func newTestBalancer(rt routetable.ObjectRouteTable, policy DegradePolicy, opts ...DegradeOption) *Balancer {
+	b := &Builder{
+		balancerType: BalancerTypeMaster,
+		routeTable:   rt,
+		degrader:     newDegrader(policy, rt, opts...),
+	}
+	return b.Build().(*Balancer)
+}
+
+func TestBalancer_DegradeSnapshot(t *testing.T) {
+	rt := newFlakyRouteTable(t)
+	p := newTestBalancer(rt, DegradeSnapshot, WithFailureThreshold(1), WithCooldown(time.Hour))
+	nodes := testNodes("a", "b")
+
+	picked, _, err := p.Pick(oidCtx(1), nodes)
+	require.NoError(t, err)
+
+	rt.down.Store(true)
+	node, _, err := p.Pick(oidCtx(1), nodes)
+	require.NoError(t, err)
+	assert.Equal(t, picked.Address(), node.Address())
+
+	_, _, err = p.Pick(oidCtx(2), nodes)
+	assert.ErrorIs(t, err, verrors.ErrRouteTableDegraded)
+}
+
+func TestBalancer_DegradeFailFast(t *testing.T) {
+	rt := newFlakyRouteTable(t)
+	p := newTestBalancer(rt, DegradeFailFast, WithFailureThreshold(2), WithCooldown(time.Hour))
+	nodes := testNodes("a", "b")
+
+	rt.down.Store(true)
+	_, _, err := p.Pick(oidCtx(1), nodes)
+	assert.ErrorIs(t, err, errUnavailable)
+	_, _, err = p.Pick(oidCtx(1), nodes)
+	assert.ErrorIs(t, err, errUnavailable)
+
+	// the route table is not called after the degraded mode is entered
+	_, _, err = p.Pick(oidCtx(1), nodes)
+	assert.ErrorIs(t, err, verrors.ErrRouteTableDegraded)
+}
+
+func TestBalancer_DegradeHashReconcile(t *testing.T) {
+	ctx := context.Background()
+	rt := newFlakyRouteTable(t)
+	p := newTestBalancer(rt, DegradeHash, WithFailureThreshold(1), WithCooldown(time.Millisecond))
+	nodes := testNodes("a", "b", "c")
+
+	rt.down.Store(true)
+	node, _, err := p.Pick(oidCtx(1), nodes)
+	require.NoError(t, err)
+	assert.Equal(t, hashPick("1", nodes).Address(), node.Address())
+
+	// the assignment is written back after the probe succeeds
+	rt.down.Store(false)
+	time.Sleep(time.Millisecond * 2)
+	again, _, err := p.Pick(oidCtx(1), nodes)
+	require.NoError(t, err)
+	assert.Equal(t, node.Address(), again.Address())
+
+	assert.Eventually(t, func() bool {
+		addr, err := rt.Load(ctx, "red", 1)
+		return err == nil && addr == node.Address()
+	}, time.Second, time.Millisecond*10)
+}
+
+// cancelableRouteTable fails the loads with the error of the ctx
+type cancelableRouteTable struct {
+	*flakyRouteTable
+}
+
+func (rt *cancelableRouteTable) LoadAndExpireObject(ctx context.Context, color string, oid string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return rt.flakyRouteTable.LoadAndExpireObject(ctx, color, oid)
+}
+
+func TestBalancer_DegradeProbeCanceled(t *testing.T) {
+	rt := &cancelableRouteTable{flakyRouteTable: newFlakyRouteTable(t)}
+	p := newTestBalancer(rt, DegradeSnapshot, WithFailureThreshold(1), WithCooldown(time.Millisecond))
+	nodes := testNodes("a", "b")
+
+	rt.down.Store(true)
+	_, _, err := p.Pick(oidCtx(1), nodes)
+	assert.ErrorIs(t, err, verrors.ErrRouteTableDegraded)
+	rt.down.Store(false)
+
+	// the probe is canceled by the caller
+	time.Sleep(time.Millisecond * 2)
+	ctx, cancel := context.WithCancel(oidCtx(1))
+	cancel()
+	_, _, err = p.Pick(ctx, nodes)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// the next pick after the cooldown probes again and leaves the degraded mode
+	time.Sleep(time.Millisecond * 2)
+	_, _, err = p.Pick(oidCtx(1), nodes)
+	require.NoError(t, err)
+	assert.True(t, p.degrader.allow())
+}
+
+func TestDegrader_ReconcileRetry(t *testing.T) {
+	ctx := context.Background()
+	rt := newFlakyRouteTable(t)
+	d := newDegrader(DegradeHash, rt, WithCooldown(time.Millisecond))
+	key := routeKey{color: "red", oid: "1"}
+	d.pending[key] = routetable.RouteEntry{Addr: "a"}
+
+	rt.down.Store(true)
+	d.reconcile()
+	assert.Equal(t, "a", d.pendingAddr(key), "the failed assignment is kept")
+
+	// the failed assignment is retried on the timer
+	rt.down.Store(false)
+	assert.Eventually(t, func() bool {
+		addr, err := rt.LoadObject(ctx, "red", "1")
+		return err == nil && addr == "a" && d.pendingAddr(key) == ""
+	}, time.Second, time.Millisecond*10)
+}
@@ -0,0 +1,81 @@
+package balancer
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/selector"
+	"github.com/vulcan-frame/vulcan-pkg-app/router"
+)
+
+// drainingState is the cached draining marks of the nodes in a color
+type drainingState struct {
+	expireAt time.Time
+	addrs    map[string]bool
+}
+
+// filterDraining removes the draining nodes, all the nodes are returned if they are all draining
+func (p *Balancer) filterDraining(ctx context.Context, color string, nodes []selector.WeightedNode) []selector.WeightedNode {
+	draining := p.loadDraining(ctx, color, nodes)
+	if len(draining) == 0 {
+		return nodes
+	}
+
+	filtered := make([]selector.WeightedNode, 0, len(nodes))
+	for _, node := range nodes {
+		if !draining[node.Address()] {
+			filtered = append(filtered, node)
+		}
+	}
+	if len(filtered) == 0 {
+		log.Warnf("all the nodes are draining, ignore the draining marks. color=%s nodes=%d", color, len(nodes))
+		return nodes
+	}
+	return filtered
+}
+
+// loadDraining loads the draining marks of the nodes, the marks are cached for router.HolderCacheTimeout
+func (p *Balancer) loadDraining(ctx context.Context, color string, nodes []selector.WeightedNode) map[string]bool {
+	now := time.Now()
+
+	p.drainMu.Lock()
+	state, ok := p.draining[color]
+	p.drainMu.Unlock()
+
+	if ok && now.Before(state.expireAt) {
+		fresh := true
+		for _, node := range nodes {
+			if _, ok := state.addrs[node.Address()]; !ok {
+				fresh = false
+				break
+			}
+		}
+		if fresh {
+			return state.addrs
+		}
+	}
+
+	addrs := make([]string, len(nodes))
+	for i, node := range nodes {
+		addrs[i] = node.Address()
+	}
+	marks, err := p.routeTable.Draining(ctx, color, addrs)
+	if err != nil {
+		log.Errorf("load the draining nodes failed, ignore the draining marks. color=%s err=%+v", color, err)
+		return nil
+	}
+
+	state = drainingState{
+		expireAt: now.Add(router.HolderCacheTimeout),
+		addrs:    make(map[string]bool, len(addrs)),
+	}
+	for i, addr := range addrs {
+		state.addrs[addr] = marks[i]
+	}
+
+	p.drainMu.Lock()
+	p.draining[color] = state
+	p.drainMu.Unlock()
+	return state.addrs
+}
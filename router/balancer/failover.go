@@ -0,0 +1,153 @@
+package balancer
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/selector"
+	"github.com/pkg/errors"
+	verrors "github.com/vulcan-frame/vulcan-pkg-app/errors"
+)
+
+// ErrRoutedNodeUnavailable is returned when the routed node is not ready and not stale yet
+var ErrRoutedNodeUnavailable = errors.New("the routed node is unavailable")
+
+type FailoverOption func(f *failover)
+
+// WithHealthCheck sets the checker of the routed nodes which are not ready, the route is taken over immediately if it returns false
+func WithHealthCheck(f func(ctx context.Context, addr string) bool) FailoverOption {
+	return func(fo *failover) {
+		fo.healthCheck = f
+	}
+}
+
+// WithOnTakeover sets the hook called after a route is taken over from the stale node.
+// It's called synchronously before the request is sent to the new owner, so the new owner can be told to load the state.
+func WithOnTakeover(f func(ctx context.Context, color string, oid string, from, to string)) FailoverOption {
+	return func(fo *failover) {
+		fo.onTakeover = f
+	}
+}
+
+// WithFailover makes the master balancer take over the routes of the stale nodes.
+// A routed node is stale when it has been absent from the ready nodes for absentFor, or failed the health check.
+// The route is swapped to the new node by CompareAndSwap, so only one of the concurrent pickers takes it over.
+func WithFailover(absentFor time.Duration, opts ...FailoverOption) Option {
+	return func(o *options) {
+		o.failoverAbsentFor = absentFor
+		o.failoverOpts = opts
+		o.failover = true
+	}
+}
+
+// failover tracks the absent routed nodes, it's shared by the balancers of a builder
+type failover struct {
+	absentFor   time.Duration
+	healthCheck func(ctx context.Context, addr string) bool
+	onTakeover  func(ctx context.Context, color string, oid string, from, to string)
+
+	absent sync.Map // addr -> *absence
+}
+
+// absence is the time the routed node found absent and the last time its routes are picked while absent
+type absence struct {
+	since time.Time
+	seen  atomic.Int64 // unix nano
+}
+
+func newFailover(enabled bool, absentFor time.Duration, opts ...FailoverOption) *failover {
+	if !enabled {
+		return nil
+	}
+
+	f := &failover{absentFor: absentFor}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// present clears the absent mark of the node
+func (f *failover) present(addr string) {
+	if f == nil {
+		return
+	}
+	if _, ok := f.absent.Load(addr); ok {
+		f.absent.Delete(addr)
+	}
+}
+
+// stale returns whether the routed node which is not ready should be taken over
+func (f *failover) stale(ctx context.Context, addr string) bool {
+	if f.healthCheck != nil && !f.healthCheck(ctx, addr) {
+		return true
+	}
+	now := time.Now()
+	v, ok := f.absent.Load(addr)
+	if !ok {
+		a := &absence{since: now}
+		v, _ = f.absent.LoadOrStore(addr, a)
+	}
+	a := v.(*absence)
+	a.seen.Store(now.UnixNano())
+	return now.Sub(a.since) >= f.absentFor
+}
+
+// prune forgets the absent nodes whose routes are not picked for twice absentFor, such as the pods replaced by
+// a rolling deploy, so the routes picked in the meantime keep their absent time. It's called on every discovery update.
+// A route of the pruned node picked later is taken over after another absentFor.
+func (f *failover) prune() {
+	if f == nil {
+		return
+	}
+
+	now := time.Now()
+	f.absent.Range(func(addr, v any) bool {
+		a := v.(*absence)
+		if now.Sub(time.Unix(0, a.seen.Load())) >= 2*f.absentFor {
+			f.absent.CompareAndDelete(addr, a)
+		}
+		return true
+	})
+}
+
+// takeover swaps the route from the stale node to a new node
+func (p *Balancer) takeover(ctx context.Context, color string, oid string, stale string, nodes []selector.WeightedNode) (selector.WeightedNode, selector.DoneFunc, error) {
+	current, gen, err := p.routeTable.LoadGenObject(ctx, color, oid)
+	if err != nil && !errors.Is(err, verrors.ErrRouteTableNotFound) {
+		return nil, nil, err
+	}
+	if err == nil && current != stale {
+		// the route is changed by others
+		return p.routedNode(color, oid, current, nodes)
+	}
+
+	selected := p.pickWeighted(p.filterDraining(ctx, color, nodes))
+	// the generation of the missing route is 0, so the swap fails if the route is set by others after LoadGen
+	ok, _, err := p.routeTable.CompareAndSwapObject(ctx, color, oid, gen, newRouteEntry(selected).Encode())
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		if current, _, err = p.routeTable.LoadGenObject(ctx, color, oid); err != nil {
+			return nil, nil, err
+		}
+		return p.routedNode(color, oid, current, nodes)
+	}
+
+	log.Warnf("route is taken over from the stale node. oid=%s color=%s from=%s to=%s", oid, color, stale, selected.Address())
+	if p.failover.onTakeover != nil {
+		p.failover.onTakeover(ctx, color, oid, stale, selected.Address())
+	}
+	return selected, selected.Pick(), nil
+}
+
+func (p *Balancer) routedNode(color string, oid string, addr string, nodes []selector.WeightedNode) (selector.WeightedNode, selector.DoneFunc, error) {
+	if node := findNode(nodes, addr); node != nil {
+		return node, nil, nil
+	}
+	return nil, nil, errors.Wrapf(ErrRoutedNodeUnavailable, "oid=%s color=%s addr=%s", oid, color, addr)
+}
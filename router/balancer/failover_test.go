@@ -0,0 +1,140 @@
+package balancer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/resolver"
+)
+
+// fakeSubConn is a ready SubConn of the picker
+type fakeSubConn struct {
+	balancer.SubConn
+	addr string
+}
+
+func buildPicker(b *balancerBuilder, addrs ...string) balancer.Picker {
+	info := base.PickerBuildInfo{ReadySCs: make(map[balancer.SubConn]base.SubConnInfo, len(addrs))}
+	for _, addr := range addrs {
+		info.ReadySCs[&fakeSubConn{addr: addr}] = base.SubConnInfo{Address: resolver.Address{Addr: addr}}
+	}
+	return b.Build(info)
+}
+
+func pickAddr(picker balancer.Picker, oid int64) (string, error) {
+	result, err := picker.Pick(balancer.PickInfo{Ctx: oidCtx(oid)})
+	if err != nil {
+		return "", err
+	}
+	return result.SubConn.(*fakeSubConn).addr, nil
+}
+
+type takeover struct {
+	oid      string
+	from, to string
+}
+
+func TestBalancer_FailoverAbsent(t *testing.T) {
+	ctx := context.Background()
+	rt := newFlakyRouteTable(t)
+	require.NoError(t, rt.Store(ctx, "red", 1, "gone:1"))
+
+	var takeovers []takeover
+	b := &balancerBuilder{builder: NewBuilder(WithBalancerType(BalancerTypeMaster), WithRouteTable(rt),
+		WithFailover(time.Millisecond*50, WithOnTakeover(func(ctx context.Context, color string, oid string, from, to string) {
+			takeovers = append(takeovers, takeover{oid: oid, from: from, to: to})
+		})))}
+
+	picker := buildPicker(b, "a:1", "b:1")
+	_, err := pickAddr(picker, 1)
+	assert.ErrorIs(t, err, ErrRoutedNodeUnavailable)
+
+	// the absent time is kept by the builder when the picker is rebuilt
+	time.Sleep(time.Millisecond * 60)
+	picker = buildPicker(b, "a:1", "b:1", "c:1")
+	addr, err := pickAddr(picker, 1)
+	require.NoError(t, err)
+
+	stored, err := rt.Load(ctx, "red", 1)
+	require.NoError(t, err)
+	assert.Equal(t, addr, stored)
+	assert.Equal(t, []takeover{{oid: "1", from: "gone:1", to: addr}}, takeovers)
+
+	again, err := pickAddr(picker, 1)
+	require.NoError(t, err)
+	assert.Equal(t, addr, again)
+	assert.Len(t, takeovers, 1)
+}
+
+func TestFailover_Prune(t *testing.T) {
+	ctx := context.Background()
+	f := newFailover(true, time.Millisecond*50)
+
+	assert.False(t, f.stale(ctx, "gone:1"))
+	assert.False(t, f.stale(ctx, "left:1"))
+	time.Sleep(time.Millisecond * 60)
+	// the routes of gone:1 are still picked, so its absent time is kept
+	assert.True(t, f.stale(ctx, "gone:1"))
+	time.Sleep(time.Millisecond * 50)
+	f.prune()
+
+	_, ok := f.absent.Load("gone:1")
+	assert.True(t, ok)
+	_, ok = f.absent.Load("left:1")
+	assert.False(t, ok, "the node not picked for twice the threshold is pruned")
+}
+
+func TestBalancer_FailoverHealthCheck(t *testing.T) {
+	ctx := context.Background()
+	rt := newFlakyRouteTable(t)
+	require.NoError(t, rt.Store(ctx, "red", 1, "sick:1"))
+	require.NoError(t, rt.Store(ctx, "red", 2, "slow:1"))
+
+	b := &balancerBuilder{builder: NewBuilder(WithBalancerType(BalancerTypeMaster), WithRouteTable(rt),
+		WithFailover(time.Hour, WithHealthCheck(func(ctx context.Context, addr string) bool {
+			return addr != "sick:1"
+		})))}
+	picker := buildPicker(b, "a:1")
+
+	addr, err := pickAddr(picker, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "a:1", addr)
+
+	_, err = pickAddr(picker, 2)
+	assert.ErrorIs(t, err, ErrRoutedNodeUnavailable)
+	stored, err := rt.Load(ctx, "red", 2)
+	require.NoError(t, err)
+	assert.Equal(t, "slow:1", stored)
+}
+
+func TestBalancer_FailoverRace(t *testing.T) {
+	ctx := context.Background()
+	rt := newFlakyRouteTable(t)
+	require.NoError(t, rt.Store(ctx, "red", 1, "gone:1"))
+
+	b := &balancerBuilder{builder: NewBuilder(WithBalancerType(BalancerTypeMaster), WithRouteTable(rt), WithFailover(0))}
+	picker := buildPicker(b, "a:1", "b:1", "c:1")
+
+	results := make(chan string, 8)
+	for i := 0; i < cap(results); i++ {
+		go func() {
+			addr, err := pickAddr(picker, 1)
+			assert.NoError(t, err)
+			results <- addr
+		}()
+	}
+
+	stored := ""
+	for i := 0; i < cap(results); i++ {
+		addr := <-results
+		if stored == "" {
+			stored, _ = rt.Load(ctx, "red", 1)
+		}
+		assert.Equal(t, stored, addr)
+	}
+}
@@ -22,16 +22,16 @@ var (
 
 // RegisterBalancer Register a balancer for master
 // return the balancer name
-func RegisterMasterBalancer(rt routetable.RouteTable) {
+func RegisterMasterBalancer(rt routetable.RouteTable, opts ...Option) {
 	t := BalancerTypeMaster
-	registerBalancer(t, NewBuilder(WithBalancerType(t), WithRouteTable(rt)))
+	registerBalancer(t, NewBuilder(append([]Option{WithBalancerType(t), WithRouteTable(rt)}, opts...)...))
 	MasterBalancerRegistered.Store(true)
 }
 
 // RegisterBalancer Register a balancer for reader
 // return the balancer name
-func RegisterReaderBalancer(rt routetable.RouteTable) {
+func RegisterReaderBalancer(rt routetable.RouteTable, opts ...Option) {
 	t := BalancerTypeReader
-	registerBalancer(t, NewBuilder(WithBalancerType(t), WithRouteTable(rt)))
+	registerBalancer(t, NewBuilder(append([]Option{WithBalancerType(t), WithRouteTable(rt)}, opts...)...))
 	ReaderBalancerRegistered.Store(true)
 }
@@ -2,16 +2,18 @@ package balancer
 
 import (
 	"context"
-	"strconv"
 	"sync"
+	"time"
 
-	"github.com/go-kratos/kratos/v2/log"
 	"github.com/go-kratos/kratos/v2/metadata"
 	"github.com/go-kratos/kratos/v2/selector"
 	"github.com/go-kratos/kratos/v2/selector/node/direct"
 	"github.com/pkg/errors"
 	vctx "github.com/vulcan-frame/vulcan-pkg-app/context"
+	verrors "github.com/vulcan-frame/vulcan-pkg-app/errors"
+	"github.com/vulcan-frame/vulcan-pkg-app/profile"
 	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+	"golang.org/x/sync/singleflight"
 )
 
 // New random a selector.
@@ -23,12 +25,20 @@ type Option func(o *options)
 
 type options struct {
 	balancerType BalancerType
-	routeTable   routetable.RouteTable
+	routeTable   routetable.ObjectRouteTable
+	lease        bool
+
+	degradePolicy DegradePolicy
+	degradeOpts   []DegradeOption
+
+	failover          bool
+	failoverAbsentFor time.Duration
+	failoverOpts      []FailoverOption
 }
 
 func WithRouteTable(rt routetable.RouteTable) Option {
 	return func(o *options) {
-		o.routeTable = rt
+		o.routeTable = routetable.Objects(rt)
 	}
 }
 func WithBalancerType(balancerType BalancerType) Option {
@@ -37,9 +47,21 @@ func WithBalancerType(balancerType BalancerType) Option {
 	}
 }
 
+// WithLease makes Pick read the routes without refreshing the ttl,
+// the routes are kept alive by the lease.Keeper of the owners and expire after the ttl of the route table when the owner is gone.
+func WithLease() Option {
+	return func(o *options) {
+		o.lease = true
+	}
+}
+
 type Builder struct {
 	balancerType BalancerType
-	routeTable   routetable.RouteTable
+	routeTable   routetable.ObjectRouteTable
+	lease        bool
+	degrader     *degrader
+	failover     *failover
+	assigns      singleflight.Group
 }
 
 // NewBuilder returns a selector builder with wrr balancer
@@ -52,16 +74,26 @@ func NewBuilder(opts ...Option) selector.Builder {
 		Balancer: &Builder{
 			balancerType: option.balancerType,
 			routeTable:   option.routeTable,
+			lease:        option.lease,
+			degrader:     newDegrader(option.degradePolicy, option.routeTable, option.degradeOpts...),
+			failover:     newFailover(option.failover, option.failoverAbsentFor, option.failoverOpts...),
 		},
 		Node: &direct.Builder{},
 	}
 }
 
+// Build is called on every discovery update, the absent nodes left for long are pruned by the failover
 func (b *Builder) Build() selector.Balancer {
+	b.failover.prune()
 	return &Balancer{
 		balancerType:  b.balancerType,
 		currentWeight: make(map[string]float64),
 		routeTable:    b.routeTable,
+		lease:         b.lease,
+		degrader:      b.degrader,
+		failover:      b.failover,
+		assigns:       &b.assigns,
+		draining:      make(map[string]drainingState),
 	}
 }
 
@@ -71,7 +103,14 @@ type Balancer struct {
 	balancerType  BalancerType
 	mu            sync.Mutex
 	currentWeight map[string]float64
-	routeTable    routetable.RouteTable
+	routeTable    routetable.ObjectRouteTable
+	lease         bool
+	degrader      *degrader // nil if the degraded mode is disabled
+	failover      *failover // nil if the failover is disabled
+	assigns       *singleflight.Group
+
+	drainMu  sync.Mutex
+	draining map[string]drainingState
 }
 
 // Pick is pick a weighted node
@@ -85,20 +124,61 @@ func (p *Balancer) Pick(ctx context.Context, nodes []selector.WeightedNode) (sel
 		return nil, nil, err
 	}
 	color := getColorFromCtx(ctx)
+	// the route table scopes the keys by the SID of the ctx, so does the degraded mode
+	key := routeKey{sid: routetable.SIDFromContext(ctx), color: color, oid: oid}
+
+	// the route table is not called in the degraded mode until the cooldown is over
+	if !p.degrader.allow() {
+		return p.pickDegraded(key, nodes)
+	}
 
 	// select node by oid from routeTable
-	addr, err := p.routeTable.LoadAndExpire(ctx, color, oid)
-	if err != nil {
+	addr, err := p.loadRoute(ctx, color, oid)
+	if err != nil && !errors.Is(err, verrors.ErrRouteTableNotFound) {
+		if p.degrader.failure(err) {
+			return p.pickDegraded(key, nodes)
+		}
+		return nil, nil, err
+	}
+	p.degrader.success()
+	if err != nil && p.balancerType != BalancerTypeMaster {
 		return nil, nil, err
 	}
+	// the master balancer assigns a new node when the route is not found
 	for _, node := range nodes {
 		if node.Address() == addr {
+			p.degrader.remember(key, addr)
+			p.failover.present(addr)
 			return node, nil, nil
 		}
 	}
 
-	// select a new node by weight from nodes
-	// the algorithm is the implement of nginx wrr, copied from https://github.com/go-kratos/kratos/blob/main/selector/wrr/wrr.go
+	// the routed node is not ready, the route is taken over only if the node is stale
+	if addr != "" && p.balancerType == BalancerTypeMaster && p.failover != nil {
+		if !p.failover.stale(ctx, addr) {
+			return nil, nil, errors.Wrapf(ErrRoutedNodeUnavailable, "oid=%s color=%s addr=%s", oid, color, addr)
+		}
+		return p.takeover(ctx, color, oid, addr, nodes)
+	}
+
+	if p.balancerType == BalancerTypeMaster {
+		return p.assign(ctx, key, nodes)
+	}
+	selected := p.pickWeighted(nodes)
+	return selected, selected.Pick(), nil
+}
+
+func (p *Balancer) pickDegraded(key routeKey, nodes []selector.WeightedNode) (selector.WeightedNode, selector.DoneFunc, error) {
+	node, err := p.degrader.pick(key, nodes, p.balancerType)
+	if err != nil {
+		return nil, nil, err
+	}
+	return node, nil, nil
+}
+
+// pickWeighted selects a new node by weight from nodes
+// the algorithm is the implement of nginx wrr, copied from https://github.com/go-kratos/kratos/blob/main/selector/wrr/wrr.go
+func (p *Balancer) pickWeighted(nodes []selector.WeightedNode) selector.WeightedNode {
 	var (
 		totalWeight  float64
 		selected     selector.WeightedNode
@@ -106,6 +186,8 @@ func (p *Balancer) Pick(ctx context.Context, nodes []selector.WeightedNode) (sel
 	)
 
 	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	for _, node := range nodes {
 		totalWeight += node.Weight()
 		cwt := p.currentWeight[node.Address()]
@@ -117,42 +199,48 @@ func (p *Balancer) Pick(ctx context.Context, nodes []selector.WeightedNode) (sel
 		}
 	}
 	p.currentWeight[selected.Address()] = selectWeight - totalWeight
-	p.mu.Unlock()
-
-	d := selected.Pick()
+	return selected
+}
 
-	if p.balancerType != BalancerTypeMaster {
-		return selected, d, nil
+// newRouteEntry returns the route entry assigned to the node
+func newRouteEntry(node selector.WeightedNode) routetable.RouteEntry {
+	return routetable.RouteEntry{
+		Addr:       node.Address(),
+		Node:       node.Metadata()[profile.NODE],
+		Version:    node.Version(),
+		AssignedAt: time.Now(),
 	}
+}
 
-	// update route table if the connector is master
-	// the route table may be set by other connections, so we need to judge it as empty before setting
-	ok, addr, err := p.routeTable.SetNx(ctx, color, oid, selected.Address())
-	if err != nil {
-		return nil, nil, err
+func findNode(nodes []selector.WeightedNode, addr string) selector.WeightedNode {
+	if addr == "" {
+		return nil
 	}
-	if ok {
-		// the route table is set by this connection
-		return selected, d, nil
-	}
-
-	log.Warnf("routeTable is set by other connections. oid=%d color=%s oldConn=%s newConn=%s", oid, color, addr, selected.Address())
 	for _, node := range nodes {
 		if node.Address() == addr {
-			return node, nil, nil
+			return node
 		}
 	}
-	return nil, nil, errors.Errorf("the existed connection in routeTable is not found. oid=%d color=%s oldConn=%s", oid, color, addr)
+	return nil
+}
+
+// loadRoute loads the route and refreshes its ttl, the ttl is left to the owner in the lease mode
+func (p *Balancer) loadRoute(ctx context.Context, color string, oid string) (string, error) {
+	if p.lease {
+		return p.routeTable.LoadObject(ctx, color, oid)
+	}
+	return p.routeTable.LoadAndExpireObject(ctx, color, oid)
 }
 
-func getOIDFromCtx(ctx context.Context) (oid int64, err error) {
+// getOIDFromCtx returns the string oid, the int64 oid is routed by its decimal string
+func getOIDFromCtx(ctx context.Context) (oid string, err error) {
 	md, ok := metadata.FromServerContext(ctx)
 	if !ok {
 		err = errors.Errorf("metadata not in context")
 		return
 	}
-	if oid, err = strconv.ParseInt(md.Get(vctx.CtxOID), 10, 64); err != nil {
-		err = errors.Wrapf(err, "oid not int64")
+	if oid = md.Get(vctx.CtxOID); oid == "" {
+		err = errors.Errorf("oid not in metadata")
 	}
 	return
 }
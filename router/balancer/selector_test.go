@@ -0,0 +1,66 @@
+package balancer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kratos/kratos/v2/metadata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	vctx "github.com/vulcan-frame/vulcan-pkg-app/context"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable/memory"
+)
+
+func TestBalancer_ObjectKey(t *testing.T) {
+	rt := newFlakyRouteTable(t)
+	p := newTestBalancer(rt, DegradeNone)
+	nodes := testNodes("a:1", "b:1")
+
+	oid := vctx.ObjectKey{Module: "guild", ID: "0b7e4c2a"}
+	md := metadata.New()
+	md.Set(vctx.CtxOID, oid.String())
+	md.Set(vctx.CtxColor, "red")
+	ctx := metadata.NewServerContext(context.Background(), md)
+
+	node, _, err := p.Pick(ctx, nodes)
+	require.NoError(t, err)
+
+	addr, err := rt.LoadObject(context.Background(), "red", "guild:0b7e4c2a")
+	require.NoError(t, err)
+	assert.Equal(t, node.Address(), addr)
+
+	again, _, err := p.Pick(ctx, nodes)
+	require.NoError(t, err)
+	assert.Equal(t, node.Address(), again.Address())
+
+	_, _, err = p.Pick(metadata.NewServerContext(context.Background(), metadata.New()), nodes)
+	assert.Error(t, err)
+}
+
+func TestBalancer_SIDScope(t *testing.T) {
+	data := memory.NewRouteTable()
+	t.Cleanup(data.Close)
+	rt := routetable.New(data, "test", routetable.WithSIDScope())
+	p := newTestBalancer(rt, DegradeNone)
+	nodes := testNodes("a:1", "b:1")
+
+	ctxOf := func(sid string) context.Context {
+		md := metadata.New()
+		md.Set(vctx.CtxOID, "7")
+		md.Set(vctx.CtxSID, sid)
+		md.Set(vctx.CtxColor, "red")
+		return metadata.NewServerContext(context.Background(), md)
+	}
+
+	// the same oid of the servers are assigned separately
+	first, _, err := p.Pick(ctxOf("1"), nodes)
+	require.NoError(t, err)
+	second, _, err := p.Pick(ctxOf("2"), nodes)
+	require.NoError(t, err)
+	assert.NotEqual(t, first.Address(), second.Address())
+
+	addr, err := rt.Load(routetable.NewSIDContext(context.Background(), 2), "red", 7)
+	require.NoError(t, err)
+	assert.Equal(t, second.Address(), addr)
+}
@@ -0,0 +1,191 @@
+package drain
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/pkg/errors"
+	verrors "github.com/vulcan-frame/vulcan-pkg-app/errors"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+)
+
+const (
+	defaultBatchSize = 100
+	defaultInterval  = time.Second
+)
+
+type Option func(*Drainer)
+
+// WithBatchSize sets the count of the routes moved in a batch
+func WithBatchSize(size int64) Option {
+	return func(d *Drainer) {
+		d.batchSize = size
+	}
+}
+
+// WithInterval sets the pause between two batches
+func WithInterval(dur time.Duration) Option {
+	return func(d *Drainer) {
+		d.interval = dur
+	}
+}
+
+// WithOnMoved sets the hook called after a route is moved to the new node, the int64 oids are in decimal
+func WithOnMoved(f func(ctx context.Context, color string, oid string, from, to string)) Option {
+	return func(d *Drainer) {
+		d.onMoved = f
+	}
+}
+
+// Result is the summary of a drain
+type Result struct {
+	Moved   int // the routes moved to the targets
+	Skipped int // the routes changed by other writers during the drain
+	Failed  int // the routes failed to move
+}
+
+// Drainer drains a node by marking it as draining and moving its routes to the other nodes of the same color.
+// A route is moved by CompareAndSwap on its generation, so the route changed by a concurrent writer is never clobbered.
+type Drainer struct {
+	rt        routetable.ObjectRouteTable
+	batchSize int64
+	interval  time.Duration
+	onMoved   func(ctx context.Context, color string, oid string, from, to string)
+}
+
+func New(rt routetable.RouteTable, opts ...Option) *Drainer {
+	d := &Drainer{
+		rt:        routetable.Objects(rt),
+		batchSize: defaultBatchSize,
+		interval:  defaultInterval,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Drain marks the addr as draining and moves its routes to the targets in round-robin.
+// The draining mark is kept after the drain, call Undrain to make the node assignable again.
+func (d *Drainer) Drain(ctx context.Context, color string, addr string, targets []string) (Result, error) {
+	var result Result
+
+	targets = excludeAddr(targets, addr)
+	if len(targets) == 0 {
+		return result, errors.Errorf("no target to drain to. color=%s addr=%s", color, addr)
+	}
+
+	if err := d.rt.SetDraining(ctx, color, addr, true); err != nil {
+		return result, errors.WithMessagef(err, "mark draining failed. color=%s addr=%s", color, addr)
+	}
+
+	next := 0
+	for {
+		// the moved routes are removed from the addr, so every pass starts from the beginning
+		// and the drain is finished when a pass can't move any route
+		moved := 0
+		var cursor string
+		for {
+			oids, nextCursor, err := d.rt.ListObjectsByAddr(ctx, color, addr, cursor, d.batchSize)
+			if err != nil {
+				return result, errors.WithMessagef(err, "list routes failed. color=%s addr=%s", color, addr)
+			}
+
+			for _, oid := range oids {
+				to := targets[next%len(targets)]
+				next++
+
+				ok, err := d.move(ctx, color, oid, addr, to)
+				switch {
+				case err != nil:
+					result.Failed++
+					log.Errorf("drain move route failed. color=%s oid=%s from=%s to=%s err=%+v", color, oid, addr, to, err)
+				case ok:
+					result.Moved++
+					moved++
+				default:
+					result.Skipped++
+				}
+			}
+
+			if nextCursor == "" {
+				break
+			}
+			cursor = nextCursor
+
+			if err := d.wait(ctx); err != nil {
+				return result, err
+			}
+		}
+
+		log.Infof("drain pass finished. color=%s addr=%s moved=%d total=%+v", color, addr, moved, result)
+		if moved == 0 {
+			return result, nil
+		}
+		if err := d.wait(ctx); err != nil {
+			return result, err
+		}
+	}
+}
+
+// Undrain unmarks the addr as draining
+func (d *Drainer) Undrain(ctx context.Context, color string, addr string) error {
+	return d.rt.SetDraining(ctx, color, addr, false)
+}
+
+// move moves the route of the oid from the addr to the target if it's still routed to the addr.
+// The route is swapped on its generation, so it's never missing during the move.
+func (d *Drainer) move(ctx context.Context, color string, oid string, from, to string) (bool, error) {
+	current, gen, err := d.rt.LoadGenObject(ctx, color, oid)
+	if err != nil {
+		if errors.Is(err, verrors.ErrRouteTableNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if !routetable.SameAddr(current, from) {
+		log.Infof("drain route is changed by other writers. color=%s oid=%s from=%s current=%s", color, oid, from, current)
+		return false, nil
+	}
+
+	ok, _, err := d.rt.CompareAndSwapObject(ctx, color, oid, gen, to)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		log.Infof("drain route is changed by other writers. color=%s oid=%s from=%s", color, oid, from)
+		return false, nil
+	}
+
+	if d.onMoved != nil {
+		d.onMoved(ctx, color, oid, from, to)
+	}
+	return true, nil
+}
+
+func (d *Drainer) wait(ctx context.Context) error {
+	if d.interval <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d.interval)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func excludeAddr(addrs []string, addr string) []string {
+	result := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		if a != addr {
+			result = append(result, a)
+		}
+	}
+	return result
+}
@@ -0,0 +1,55 @@
+package drain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable/memory"
+)
+
+func TestDrainer_Drain(t *testing.T) {
+	ctx := context.Background()
+	data := memory.NewRouteTable()
+	defer data.Close()
+	rt := routetable.New(data, "player")
+
+	for oid := int64(1); oid <= 25; oid++ {
+		require.NoError(t, rt.Store(ctx, "blue", oid, "a"))
+	}
+	require.NoError(t, rt.StoreObject(ctx, "blue", "room-1", "a"))
+	require.NoError(t, rt.Store(ctx, "blue", 100, "b"))
+
+	var moved []string
+	d := New(rt, WithBatchSize(4), WithInterval(0), WithOnMoved(func(_ context.Context, _ string, oid string, _, _ string) {
+		moved = append(moved, oid)
+	}))
+	result, err := d.Drain(ctx, "blue", "a", []string{"a", "b", "c"})
+	require.NoError(t, err)
+	assert.Equal(t, Result{Moved: 26}, result)
+	assert.Len(t, moved, 26)
+	assert.Contains(t, moved, "room-1")
+
+	oids, _, err := rt.ListObjectsByAddr(ctx, "blue", "a", "", 100)
+	require.NoError(t, err)
+	assert.Empty(t, oids)
+
+	draining, err := rt.Draining(ctx, "blue", []string{"a", "b"})
+	require.NoError(t, err)
+	assert.Equal(t, []bool{true, false}, draining)
+
+	// the drain mark is not stored as a route
+	require.NoError(t, data.Scan(ctx, "", func(keys []string) error {
+		for _, key := range keys {
+			assert.NotContains(t, key, "rd_")
+		}
+		return nil
+	}))
+
+	require.NoError(t, d.Undrain(ctx, "blue", "a"))
+	draining, err = rt.Draining(ctx, "blue", []string{"a"})
+	require.NoError(t, err)
+	assert.Equal(t, []bool{false}, draining)
+}
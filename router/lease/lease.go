@@ -0,0 +1,258 @@
+package lease
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/pkg/errors"
+	verrors "github.com/vulcan-frame/vulcan-pkg-app/errors"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+)
+
+const (
+	defaultInterval  = time.Second * 10
+	defaultBatchSize = 500
+	recoverPageSize  = 1000
+)
+
+type Option func(*Keeper)
+
+// WithInterval sets the interval of the renewal, it should be well below the ttl of the route table
+func WithInterval(dur time.Duration) Option {
+	return func(k *Keeper) {
+		k.interval = dur
+	}
+}
+
+// WithBatchSize sets the count of the routes renewed in a batch
+func WithBatchSize(size int) Option {
+	return func(k *Keeper) {
+		k.batchSize = size
+	}
+}
+
+// WithOnLost sets the hook called when a held route is found expired or taken over by another node,
+// the int64 oids are in decimal and the SID of the route is in the ctx, see routetable.SIDFromContext
+func WithOnLost(f func(ctx context.Context, color string, oid string)) Option {
+	return func(k *Keeper) {
+		k.onLost = f
+	}
+}
+
+// Keeper holds the leases of the routes owned by the node and renews them in batches in the background.
+// The ttl of the route table is the lease, create it with a short routetable.WithTTL as the grace period,
+// then the routes of a dead node expire soon instead of after the default ttl.
+// The balancers should be built with balancer.WithLease so that Pick doesn't refresh the ttl.
+type Keeper struct {
+	rt        routetable.ObjectRouteTable
+	addr      string
+	interval  time.Duration
+	batchSize int
+	onLost    func(ctx context.Context, color string, oid string)
+
+	mu    sync.Mutex
+	owned map[scope]map[string]struct{} // sid+color -> oids
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New creates the keeper of the routes owned by the addr and starts the renewal
+func New(rt routetable.RouteTable, addr string, opts ...Option) *Keeper {
+	k := &Keeper{
+		rt:        routetable.Objects(rt),
+		addr:      addr,
+		interval:  defaultInterval,
+		batchSize: defaultBatchSize,
+		owned:     make(map[scope]map[string]struct{}),
+	}
+	for _, opt := range opts {
+		opt(k)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	k.cancel = cancel
+	k.wg.Add(1)
+	go k.renewLoop(ctx)
+	return k
+}
+
+// Close stops the renewal, the held routes expire after the ttl unless they are renewed by another keeper
+func (k *Keeper) Close() {
+	k.cancel()
+	k.wg.Wait()
+}
+
+// Hold starts renewing the route of the oid, the route is in the SID scope of the ctx,
+// see routetable.WithSIDScope. The other values of the ctx are not kept.
+func (k *Keeper) Hold(ctx context.Context, color string, oid int64) {
+	k.HoldObject(ctx, color, strconv.FormatInt(oid, 10))
+}
+
+// HoldObject is Hold of the string oid
+func (k *Keeper) HoldObject(ctx context.Context, color string, oid string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	s := newScope(ctx, color)
+	oids, ok := k.owned[s]
+	if !ok {
+		oids = make(map[string]struct{})
+		k.owned[s] = oids
+	}
+	oids[oid] = struct{}{}
+}
+
+// Unhold stops renewing the route of the oid in the SID scope of the ctx, the route expires after the ttl
+func (k *Keeper) Unhold(ctx context.Context, color string, oid int64) {
+	k.UnholdObject(ctx, color, strconv.FormatInt(oid, 10))
+}
+
+// UnholdObject is Unhold of the string oid
+func (k *Keeper) UnholdObject(ctx context.Context, color string, oid string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.unholdLocked(newScope(ctx, color), oid)
+}
+
+// Release stops renewing the route of the oid and deletes it if it is still owned by the node
+func (k *Keeper) Release(ctx context.Context, color string, oid int64) error {
+	return k.ReleaseObject(ctx, color, strconv.FormatInt(oid, 10))
+}
+
+// ReleaseObject is Release of the string oid
+func (k *Keeper) ReleaseObject(ctx context.Context, color string, oid string) error {
+	k.UnholdObject(ctx, color, oid)
+	return k.rt.DelIfSameObject(ctx, color, oid, k.addr)
+}
+
+// Held returns the count of the held routes
+func (k *Keeper) Held() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	n := 0
+	for _, oids := range k.owned {
+		n += len(oids)
+	}
+	return n
+}
+
+// Recover holds all the routes of the color in the SID scope of the ctx owned by the node,
+// it is called after the node restarts
+func (k *Keeper) Recover(ctx context.Context, color string) (int, error) {
+	count := 0
+	var cursor string
+	for {
+		oids, next, err := k.rt.ListObjectsByAddr(ctx, color, k.addr, cursor, recoverPageSize)
+		if err != nil {
+			return count, errors.WithMessagef(err, "lease recover failed. color=%s addr=%s", color, k.addr)
+		}
+		for _, oid := range oids {
+			k.HoldObject(ctx, color, oid)
+		}
+		count += len(oids)
+		if next == "" {
+			return count, nil
+		}
+		cursor = next
+	}
+}
+
+func (k *Keeper) renewLoop(ctx context.Context) {
+	defer k.wg.Done()
+
+	ticker := time.NewTicker(k.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			k.renew(ctx)
+		}
+	}
+}
+
+// renew renews the snapshot of the held routes in batches, the lost routes are unheld.
+// The routes of each SID are renewed in its scope.
+func (k *Keeper) renew(ctx context.Context) {
+	for s, oids := range k.snapshot() {
+		sctx := routetable.NewSIDContext(ctx, s.sid)
+		for start := 0; start < len(oids); start += k.batchSize {
+			if ctx.Err() != nil {
+				return
+			}
+
+			batch := oids[start:min(start+k.batchSize, len(oids))]
+			errs := k.rt.RenewObjects(sctx, s.color, batch, k.addr)
+			for i, err := range errs {
+				if err == nil {
+					continue
+				}
+				if !errors.Is(err, verrors.ErrRouteTableNotFound) {
+					log.Errorf("lease renew failed. sid=%d color=%s oid=%s addr=%s err=%+v", s.sid, s.color, batch[i], k.addr, err)
+					continue
+				}
+				k.lost(sctx, s, batch[i])
+			}
+		}
+	}
+}
+
+// lost unholds the lost route and calls the hook with the ctx of its SID scope
+func (k *Keeper) lost(ctx context.Context, s scope, oid string) {
+	k.mu.Lock()
+	_, held := k.owned[s][oid]
+	k.unholdLocked(s, oid)
+	k.mu.Unlock()
+
+	if !held {
+		return
+	}
+	log.Warnf("lease lost. sid=%d color=%s oid=%s addr=%s", s.sid, s.color, oid, k.addr)
+	if k.onLost != nil {
+		k.onLost(ctx, s.color, oid)
+	}
+}
+
+func (k *Keeper) snapshot() map[scope][]string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	result := make(map[scope][]string, len(k.owned))
+	for s, oids := range k.owned {
+		list := make([]string, 0, len(oids))
+		for oid := range oids {
+			list = append(list, oid)
+		}
+		result[s] = list
+	}
+	return result
+}
+
+func (k *Keeper) unholdLocked(s scope, oid string) {
+	oids, ok := k.owned[s]
+	if !ok {
+		return
+	}
+	delete(oids, oid)
+	if len(oids) == 0 {
+		delete(k.owned, s)
+	}
+}
+
+// scope is the SID scope and the color of the held routes
+type scope struct {
+	sid   int64
+	color string
+}
+
+func newScope(ctx context.Context, color string) scope {
+	return scope{sid: routetable.SIDFromContext(ctx), color: color}
+}
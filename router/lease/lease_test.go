@@ -0,0 +1,93 @@
+package lease
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable/memory"
+)
+
+func TestKeeper(t *testing.T) {
+	ctx := context.Background()
+	data := memory.NewRouteTable()
+	defer data.Close()
+	rt := routetable.New(data, "player", routetable.WithTTL(time.Millisecond*200))
+
+	var lost atomic.Value
+	k := New(rt, "a", WithInterval(time.Millisecond*50), WithOnLost(func(ctx context.Context, color string, oid string) {
+		lost.Store(oid)
+	}))
+	defer k.Close()
+
+	require.NoError(t, rt.Store(ctx, "blue", 1, "a"))
+	require.NoError(t, rt.Store(ctx, "blue", 2, "a"))
+	require.NoError(t, rt.Store(ctx, "blue", 3, "a"))
+	require.NoError(t, rt.StoreObject(ctx, "blue", "room-1", "a"))
+	n, err := k.Recover(ctx, "blue")
+	require.NoError(t, err)
+	assert.Equal(t, 4, n)
+	k.Unhold(ctx, "blue", 2)
+
+	// the route taken over by another node is lost
+	_, err = rt.GetSet(ctx, "blue", 3, "b")
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 400)
+
+	addr, err := rt.Load(ctx, "blue", 1)
+	require.NoError(t, err)
+	assert.Equal(t, "a", addr)
+
+	_, err = rt.Load(ctx, "blue", 2)
+	assert.Error(t, err)
+
+	addr, err = rt.LoadObject(ctx, "blue", "room-1")
+	require.NoError(t, err)
+	assert.Equal(t, "a", addr)
+
+	assert.Equal(t, "3", lost.Load())
+	assert.Equal(t, 2, k.Held())
+
+	require.NoError(t, k.Release(ctx, "blue", 1))
+	_, err = rt.Load(ctx, "blue", 1)
+	assert.Error(t, err)
+	require.NoError(t, k.ReleaseObject(ctx, "blue", "room-1"))
+	assert.Equal(t, 0, k.Held())
+}
+
+func TestKeeper_SIDScope(t *testing.T) {
+	ctx := context.Background()
+	data := memory.NewRouteTable()
+	defer data.Close()
+	rt := routetable.New(data, "player", routetable.WithTTL(time.Millisecond*200), routetable.WithSIDScope())
+
+	var lostSID atomic.Int64
+	k := New(rt, "a", WithInterval(time.Millisecond*50), WithOnLost(func(ctx context.Context, color string, oid string) {
+		lostSID.Store(routetable.SIDFromContext(ctx))
+	}))
+	defer k.Close()
+
+	s1, s2 := routetable.NewSIDContext(ctx, 1), routetable.NewSIDContext(ctx, 2)
+	require.NoError(t, rt.Store(s1, "blue", 1, "a"))
+	require.NoError(t, rt.Store(s2, "blue", 1, "a"))
+	k.Hold(s1, "blue", 1)
+	k.Hold(s2, "blue", 1)
+	assert.Equal(t, 2, k.Held())
+
+	// the route of the SID 2 is taken over by another node
+	_, err := rt.GetSet(s2, "blue", 1, "b")
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 400)
+
+	addr, err := rt.Load(s1, "blue", 1)
+	require.NoError(t, err)
+	assert.Equal(t, "a", addr, "renewed in the scope of the SID 1")
+	assert.Equal(t, int64(2), lostSID.Load())
+	assert.Equal(t, 1, k.Held())
+}
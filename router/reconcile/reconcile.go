@@ -0,0 +1,442 @@
+package reconcile
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/registry"
+	"github.com/pkg/errors"
+	"github.com/vulcan-frame/vulcan-pkg-app/profile"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+const (
+	defaultGrace         = time.Minute
+	defaultSweepInterval = time.Minute * 10
+	defaultScheme        = "grpc"
+	listPageSize         = 1000
+	watchRetryInterval   = time.Second * 3
+
+	meterName = "github.com/vulcan-frame/vulcan-pkg-app/router/reconcile"
+)
+
+type Option func(*Reconciler)
+
+// WithGrace sets how long an address must be absent from the discovery before its routes are removed
+func WithGrace(dur time.Duration) Option {
+	return func(r *Reconciler) {
+		r.grace = dur
+	}
+}
+
+// WithSweepInterval sets the interval of scanning the route table for the addresses never seen in the discovery,
+// the sweep is disabled if the interval <= 0 or the route table doesn't support Range
+func WithSweepInterval(dur time.Duration) Option {
+	return func(r *Reconciler) {
+		r.sweepInterval = dur
+	}
+}
+
+// WithColors sets the colors to reconcile besides the ones found in the metadata of the instances
+func WithColors(colors ...string) Option {
+	return func(r *Reconciler) {
+		for _, color := range colors {
+			r.colors[color] = struct{}{}
+		}
+	}
+}
+
+// WithScheme sets the scheme of the endpoints which are the addresses in the route table, the default is grpc
+func WithScheme(scheme string) Option {
+	return func(r *Reconciler) {
+		r.scheme = scheme
+	}
+}
+
+// WithSIDs sets the SIDs of the route table scoped by routetable.WithSIDScope, the routes of all the SIDs are reconciled.
+// The default reconciles the routes in the SID of the ctx, which is 0 for the background jobs.
+func WithSIDs(sids ...int64) Option {
+	return func(r *Reconciler) {
+		r.sids = append(r.sids, sids...)
+	}
+}
+
+// WithOnVanished sets the hook called when an address is found absent from the discovery
+func WithOnVanished(f func(ctx context.Context, color string, addr string)) Option {
+	return func(r *Reconciler) {
+		r.onVanished = f
+	}
+}
+
+// WithOnRemoved sets the hook called after a route to a vanished address is removed
+func WithOnRemoved(f func(ctx context.Context, color string, oid string, addr string)) Option {
+	return func(r *Reconciler) {
+		r.onRemoved = f
+	}
+}
+
+type colorAddr struct {
+	color string
+	addr  string
+}
+
+// Reconciler removes the routes to the addresses which have vanished from the discovery of the service.
+// An address vanishes when its instance is removed from the discovery, or when it is found in the route table
+// by the sweep but not in the discovery. Its routes are removed by DelIfSame after the grace period,
+// so the routes reassigned in the meantime are kept.
+// The Reconciler implements the kratos transport.Server, so it can be run by the kratos app.
+type Reconciler struct {
+	rt            routetable.ObjectRouteTable
+	discovery     registry.Discovery
+	service       string
+	grace         time.Duration
+	sweepInterval time.Duration
+	scheme        string
+	sids          []int64
+	onVanished    func(ctx context.Context, color string, addr string)
+	onRemoved     func(ctx context.Context, color string, oid string, addr string)
+
+	mu         sync.Mutex
+	ready      bool                    // whether the instances have been received from the discovery
+	alive      map[string]struct{}     // addrs in the discovery
+	addrColors map[string]string       // the colors of the addrs ever seen in the discovery
+	colors     map[string]struct{}     // colors to reconcile
+	missing    map[colorAddr]time.Time // the vanished addrs and the time found vanished
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	vanishedCounter metric.Int64Counter
+	removedCounter  metric.Int64Counter
+}
+
+func New(rt routetable.RouteTable, discovery registry.Discovery, service string, opts ...Option) *Reconciler {
+	r := &Reconciler{
+		rt:            routetable.Objects(rt),
+		discovery:     discovery,
+		service:       service,
+		grace:         defaultGrace,
+		sweepInterval: defaultSweepInterval,
+		scheme:        defaultScheme,
+		alive:         make(map[string]struct{}),
+		addrColors:    make(map[string]string),
+		colors:        make(map[string]struct{}),
+		missing:       make(map[colorAddr]time.Time),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	r.initMetrics()
+	return r
+}
+
+func (r *Reconciler) initMetrics() {
+	meter := otel.Meter(meterName)
+	var err error
+	if r.vanishedCounter, err = meter.Int64Counter("router.reconcile.vanished",
+		metric.WithDescription("The count of the addresses found vanished from the discovery")); err != nil {
+		log.Errorf("create router.reconcile.vanished metric failed. err=%+v", err)
+		r.vanishedCounter = noop.Int64Counter{}
+	}
+	if r.removedCounter, err = meter.Int64Counter("router.reconcile.removed",
+		metric.WithDescription("The count of the routes to the vanished addresses removed")); err != nil {
+		log.Errorf("create router.reconcile.removed metric failed. err=%+v", err)
+		r.removedCounter = noop.Int64Counter{}
+	}
+}
+
+// Start watches the discovery and reconciles in the background
+func (r *Reconciler) Start(ctx context.Context) error {
+	w, err := r.discovery.Watch(ctx, r.service)
+	if err != nil {
+		return errors.Wrapf(err, "watch discovery failed. service=%s", r.service)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.wg.Add(2)
+	go r.watchLoop(ctx, w)
+	go r.reconcileLoop(ctx)
+	return nil
+}
+
+// Stop stops the background reconciliation
+func (r *Reconciler) Stop(ctx context.Context) error {
+	if r.cancel != nil {
+		r.cancel()
+	}
+	r.wg.Wait()
+	return nil
+}
+
+func (r *Reconciler) watchLoop(ctx context.Context, w registry.Watcher) {
+	defer r.wg.Done()
+
+	go func() {
+		// Next blocks until the watcher is stopped
+		<-ctx.Done()
+		_ = w.Stop()
+	}()
+
+	for {
+		instances, err := w.Next()
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Errorf("reconcile watch discovery failed. service=%s err=%+v", r.service, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(watchRetryInterval):
+			}
+			continue
+		}
+		r.Update(ctx, instances)
+	}
+}
+
+// Update updates the alive addresses with the instances of the discovery.
+// The empty instances are ignored, since it's more likely a failure of the discovery than all the nodes gone.
+func (r *Reconciler) Update(ctx context.Context, instances []*registry.ServiceInstance) {
+	if len(instances) == 0 {
+		log.Warnf("reconcile ignores the empty instances of the discovery. service=%s", r.service)
+		return
+	}
+
+	alive := make(map[string]struct{}, len(instances))
+	colors := make(map[string]string, len(instances)) // addr -> color
+	for _, ins := range instances {
+		color := ins.Metadata[profile.COLOR]
+		if color == "" {
+			color = profile.Color()
+		}
+		for _, ep := range ins.Endpoints {
+			u, err := url.Parse(ep)
+			if err != nil || u.Scheme != r.scheme {
+				continue
+			}
+			alive[u.Host] = struct{}{}
+			colors[u.Host] = color
+		}
+	}
+
+	var vanished []colorAddr
+	now := time.Now()
+
+	r.mu.Lock()
+	for addr := range r.alive {
+		if _, ok := alive[addr]; !ok {
+			key := colorAddr{color: r.addrColors[addr], addr: addr}
+			if _, ok := r.missing[key]; !ok {
+				r.missing[key] = now
+				vanished = append(vanished, key)
+			}
+		}
+	}
+	for key := range r.missing {
+		if _, ok := alive[key.addr]; ok {
+			// the node is back in the grace period
+			delete(r.missing, key)
+		}
+	}
+	for addr, color := range colors {
+		r.colors[color] = struct{}{}
+		r.addrColors[addr] = color
+	}
+	r.alive = alive
+	r.ready = true
+	r.mu.Unlock()
+
+	for _, key := range vanished {
+		r.vanish(ctx, key)
+	}
+}
+
+func (r *Reconciler) vanish(ctx context.Context, key colorAddr) {
+	log.Warnf("reconcile found the address vanished from the discovery, its routes will be removed after %s. service=%s color=%s addr=%s",
+		r.grace, r.service, key.color, key.addr)
+	r.vanishedCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("color", key.color)))
+	if r.onVanished != nil {
+		r.onVanished(ctx, key.color, key.addr)
+	}
+}
+
+func (r *Reconciler) reconcileLoop(ctx context.Context) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(max(r.grace/2, time.Second))
+	defer ticker.Stop()
+
+	var sweepC <-chan time.Time
+	if _, ok := r.rt.(routetable.Ranger); ok && r.sweepInterval > 0 {
+		sweepTicker := time.NewTicker(r.sweepInterval)
+		defer sweepTicker.Stop()
+		sweepC = sweepTicker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.Reconcile(ctx)
+		case <-sweepC:
+			if err := r.Sweep(ctx); err != nil {
+				log.Errorf("reconcile sweep failed. service=%s err=%+v", r.service, err)
+			}
+		}
+	}
+}
+
+// Reconcile removes the routes to the addresses vanished longer than the grace period
+func (r *Reconciler) Reconcile(ctx context.Context) {
+	now := time.Now()
+
+	var expired []colorAddr
+	r.mu.Lock()
+	for key, since := range r.missing {
+		if now.Sub(since) >= r.grace {
+			expired = append(expired, key)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, key := range expired {
+		if ctx.Err() != nil {
+			return
+		}
+		removed, err := r.remove(ctx, key)
+		if err != nil {
+			log.Errorf("reconcile remove the routes failed. service=%s color=%s addr=%s removed=%d err=%+v", r.service, key.color, key.addr, removed, err)
+			continue
+		}
+		log.Infof("reconcile removed the routes to the vanished address. service=%s color=%s addr=%s removed=%d", r.service, key.color, key.addr, removed)
+
+		r.mu.Lock()
+		delete(r.missing, key)
+		r.mu.Unlock()
+	}
+}
+
+// scopes returns the ctx of each SID to reconcile
+func (r *Reconciler) scopes(ctx context.Context) []context.Context {
+	if len(r.sids) == 0 {
+		return []context.Context{ctx}
+	}
+	ctxs := make([]context.Context, len(r.sids))
+	for i, sid := range r.sids {
+		ctxs[i] = routetable.NewSIDContext(ctx, sid)
+	}
+	return ctxs
+}
+
+// remove deletes the routes of the addr in all the SIDs if they are still routed to it
+func (r *Reconciler) remove(ctx context.Context, key colorAddr) (removed int, err error) {
+	for _, sctx := range r.scopes(ctx) {
+		n, err := r.removeScope(sctx, key)
+		removed += n
+		if err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+func (r *Reconciler) removeScope(ctx context.Context, key colorAddr) (removed int, err error) {
+	var cursor string
+	for {
+		oids, next, err := r.rt.ListObjectsByAddr(ctx, key.color, key.addr, cursor, listPageSize)
+		if err != nil {
+			return removed, err
+		}
+		for _, oid := range oids {
+			if r.isAlive(key.addr) {
+				// the node is back during the removal
+				return removed, nil
+			}
+			if err := r.rt.DelIfSameObject(ctx, key.color, oid, key.addr); err != nil {
+				return removed, err
+			}
+			removed++
+			r.removedCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("color", key.color)))
+			if r.onRemoved != nil {
+				r.onRemoved(ctx, key.color, oid, key.addr)
+			}
+		}
+		if next == "" {
+			return removed, nil
+		}
+		cursor = next
+	}
+}
+
+// Sweep scans the routes of the colors for the addresses absent from the discovery,
+// they are removed by Reconcile after the grace period
+func (r *Reconciler) Sweep(ctx context.Context) error {
+	rg, ok := r.rt.(routetable.Ranger)
+	if !ok {
+		return errors.New("the route table does not support range")
+	}
+
+	r.mu.Lock()
+	ready := r.ready
+	colors := make([]string, 0, len(r.colors))
+	for color := range r.colors {
+		colors = append(colors, color)
+	}
+	r.mu.Unlock()
+	if !ready {
+		return nil
+	}
+
+	for _, color := range colors {
+		addrs := make(map[string]struct{})
+		for _, sctx := range r.scopes(ctx) {
+			err := rg.Range(sctx, color, func(_ []string, entries []routetable.RouteEntry) error {
+				for _, entry := range entries {
+					addrs[entry.Addr] = struct{}{}
+				}
+				return nil
+			})
+			if err != nil {
+				return errors.WithMessagef(err, "reconcile sweep color=%s sid=%d", color, routetable.SIDFromContext(sctx))
+			}
+		}
+
+		var vanished []colorAddr
+		now := time.Now()
+		r.mu.Lock()
+		for addr := range addrs {
+			if _, ok := r.alive[addr]; ok {
+				continue
+			}
+			key := colorAddr{color: color, addr: addr}
+			if _, ok := r.missing[key]; !ok {
+				r.missing[key] = now
+				vanished = append(vanished, key)
+			}
+		}
+		r.mu.Unlock()
+
+		for _, key := range vanished {
+			r.vanish(ctx, key)
+		}
+	}
+	return nil
+}
+
+func (r *Reconciler) isAlive(addr string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok := r.alive[addr]
+	return ok
+}
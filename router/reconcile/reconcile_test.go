@@ -0,0 +1,162 @@
+package reconcile
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/registry"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	verrors "github.com/vulcan-frame/vulcan-pkg-app/errors"
+	"github.com/vulcan-frame/vulcan-pkg-app/profile"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable/memory"
+)
+
+type fakeDiscovery struct {
+	ch chan []*registry.ServiceInstance
+}
+
+func (d *fakeDiscovery) GetService(ctx context.Context, name string) ([]*registry.ServiceInstance, error) {
+	return nil, nil
+}
+
+func (d *fakeDiscovery) Watch(ctx context.Context, name string) (registry.Watcher, error) {
+	return &fakeWatcher{ch: d.ch, done: make(chan struct{})}, nil
+}
+
+type fakeWatcher struct {
+	ch   chan []*registry.ServiceInstance
+	done chan struct{}
+	once atomic.Bool
+}
+
+func (w *fakeWatcher) Next() ([]*registry.ServiceInstance, error) {
+	select {
+	case ins := <-w.ch:
+		return ins, nil
+	case <-w.done:
+		return nil, context.Canceled
+	}
+}
+
+func (w *fakeWatcher) Stop() error {
+	if w.once.CompareAndSwap(false, true) {
+		close(w.done)
+	}
+	return nil
+}
+
+func instances(addrs ...string) []*registry.ServiceInstance {
+	result := make([]*registry.ServiceInstance, len(addrs))
+	for i, addr := range addrs {
+		result[i] = &registry.ServiceInstance{
+			ID:        addr,
+			Metadata:  map[string]string{profile.COLOR: "red"},
+			Endpoints: []string{"http://" + addr + "0", "grpc://" + addr + "?isSecure=false"},
+		}
+	}
+	return result
+}
+
+func newRouteTable(t *testing.T) *routetable.BaseRouteTable {
+	data := memory.NewRouteTable()
+	t.Cleanup(data.Close)
+	return routetable.New(data, "test")
+}
+
+func TestReconciler_Vanished(t *testing.T) {
+	ctx := context.Background()
+	rt := newRouteTable(t)
+	require.NoError(t, rt.Store(ctx, "red", 1, "a:1"))
+	require.NoError(t, rt.Store(ctx, "red", 2, "b:1"))
+	require.NoError(t, rt.Store(ctx, "red", 3, "b:1"))
+	require.NoError(t, rt.StoreObject(ctx, "red", "room:x1", "b:1"))
+
+	var removed []string
+	r := New(rt, &fakeDiscovery{}, "test", WithGrace(time.Hour), WithOnRemoved(func(ctx context.Context, color string, oid string, addr string) {
+		removed = append(removed, oid)
+	}))
+
+	r.Update(ctx, instances("a:1", "b:1"))
+	r.Update(ctx, instances("a:1"))
+	// the empty instances are ignored
+	r.Update(ctx, nil)
+
+	r.Reconcile(ctx)
+	_, err := rt.Load(ctx, "red", 2)
+	require.NoError(t, err, "removed in the grace period")
+
+	r.mu.Lock()
+	for key := range r.missing {
+		r.missing[key] = time.Now().Add(-time.Hour)
+	}
+	r.mu.Unlock()
+
+	// the route reassigned in the grace period is kept
+	require.NoError(t, rt.Store(ctx, "red", 3, "a:1"))
+	r.Reconcile(ctx)
+
+	_, err = rt.Load(ctx, "red", 2)
+	assert.ErrorIs(t, err, verrors.ErrRouteTableNotFound)
+	addr, err := rt.Load(ctx, "red", 3)
+	require.NoError(t, err)
+	assert.Equal(t, "a:1", addr)
+	addr, err = rt.Load(ctx, "red", 1)
+	require.NoError(t, err)
+	assert.Equal(t, "a:1", addr)
+	assert.ElementsMatch(t, []string{"2", "room:x1"}, removed)
+}
+
+func TestReconciler_Sweep(t *testing.T) {
+	ctx := context.Background()
+	rt := newRouteTable(t)
+	require.NoError(t, rt.Store(ctx, "red", 1, "a:1"))
+	require.NoError(t, rt.Store(ctx, "red", 2, "c:1"))
+
+	d := &fakeDiscovery{ch: make(chan []*registry.ServiceInstance, 1)}
+	var vanished atomic.Value
+	r := New(rt, d, "test", WithGrace(time.Millisecond), WithSweepInterval(time.Millisecond*10),
+		WithOnVanished(func(ctx context.Context, color string, addr string) {
+			vanished.Store(addr)
+		}))
+	require.NoError(t, r.Start(ctx))
+	defer r.Stop(ctx)
+
+	d.ch <- instances("a:1")
+
+	assert.Eventually(t, func() bool {
+		_, err := rt.Load(ctx, "red", 2)
+		return errors.Is(err, verrors.ErrRouteTableNotFound)
+	}, time.Second*3, time.Millisecond*10)
+	assert.Equal(t, "c:1", vanished.Load())
+
+	_, err := rt.Load(ctx, "red", 1)
+	require.NoError(t, err)
+}
+
+func TestReconciler_SIDs(t *testing.T) {
+	ctx := context.Background()
+	data := memory.NewRouteTable()
+	t.Cleanup(data.Close)
+	rt := routetable.NewRouteTable("test", data, routetable.WithSIDScope())
+	s1, s2 := routetable.NewSIDContext(ctx, 1), routetable.NewSIDContext(ctx, 2)
+	require.NoError(t, rt.Store(s1, "red", 1, "a:1"))
+	require.NoError(t, rt.Store(s2, "red", 1, "c:1"))
+	require.NoError(t, rt.Store(s2, "red", 2, "a:1"))
+
+	r := New(rt, &fakeDiscovery{}, "test", WithGrace(0), WithSIDs(1, 2))
+	r.Update(ctx, instances("a:1"))
+	require.NoError(t, r.Sweep(ctx))
+	r.Reconcile(ctx)
+
+	_, err := rt.Load(s2, "red", 1)
+	assert.ErrorIs(t, err, verrors.ErrRouteTableNotFound)
+	_, err = rt.Load(s1, "red", 1)
+	require.NoError(t, err)
+	_, err = rt.Load(s2, "red", 2)
+	require.NoError(t, err)
+}
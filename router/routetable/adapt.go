@@ -0,0 +1,297 @@
+package routetable
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	verrors "github.com/vulcan-frame/vulcan-pkg-app/errors"
+)
+
+// Extended returns the ExtendedRouteTable of rt. The RouteTable which doesn't implement it is adapted,
+// the entries are stored encoded, the batches fall back to the single-key methods, no node is draining,
+// and the others fail with ErrRouteTableUnsupported.
+func Extended(rt RouteTable) ExtendedRouteTable {
+	if rt == nil {
+		return nil
+	}
+	if ext, ok := rt.(ExtendedRouteTable); ok {
+		return ext
+	}
+	return extendedAdapter{RouteTable: rt}
+}
+
+// Objects returns the ObjectRouteTable of rt. The RouteTable which doesn't implement it is adapted by Extended,
+// the oids must be the decimal int64 oids and the others fail with ErrRouteTableUnsupported.
+// A nil rt returns nil.
+func Objects(rt RouteTable) ObjectRouteTable {
+	if rt == nil {
+		return nil
+	}
+	if obj, ok := rt.(ObjectRouteTable); ok {
+		return obj
+	}
+	return objectAdapter{ext: Extended(rt)}
+}
+
+type extendedAdapter struct {
+	RouteTable
+}
+
+func (a extendedAdapter) LoadGen(context.Context, string, int64) (string, int64, error) {
+	return "", 0, unsupported("LoadGen")
+}
+
+func (a extendedAdapter) GetSetGen(context.Context, string, int64, string) (string, int64, error) {
+	return "", 0, unsupported("GetSetGen")
+}
+
+func (a extendedAdapter) SetNxGen(context.Context, string, int64, string) (bool, string, int64, error) {
+	return false, "", 0, unsupported("SetNxGen")
+}
+
+func (a extendedAdapter) CompareAndSwap(context.Context, string, int64, int64, string) (bool, int64, error) {
+	return false, 0, unsupported("CompareAndSwap")
+}
+
+func (a extendedAdapter) LoadEntry(ctx context.Context, color string, key int64) (RouteEntry, error) {
+	addr, err := a.Load(ctx, color, key)
+	if err != nil {
+		return RouteEntry{}, err
+	}
+	return DecodeEntry(addr), nil
+}
+
+func (a extendedAdapter) StoreEntry(ctx context.Context, color string, key int64, entry RouteEntry) error {
+	return a.Store(ctx, color, key, entry.Encode())
+}
+
+func (a extendedAdapter) GetSetEntry(ctx context.Context, color string, key int64, entry RouteEntry) (RouteEntry, error) {
+	old, err := a.GetSet(ctx, color, key, entry.Encode())
+	return DecodeEntry(old), err
+}
+
+func (a extendedAdapter) SetNxEntry(ctx context.Context, color string, key int64, entry RouteEntry) (bool, RouteEntry, error) {
+	ok, result, err := a.SetNx(ctx, color, key, entry.Encode())
+	return ok, DecodeEntry(result), err
+}
+
+func (a extendedAdapter) LoadMany(ctx context.Context, color string, keys []int64) ([]string, []error) {
+	addrs := make([]string, len(keys))
+	errs := make([]error, len(keys))
+	for i, key := range keys {
+		addrs[i], errs[i] = a.Load(ctx, color, key)
+	}
+	return addrs, errs
+}
+
+func (a extendedAdapter) StoreMany(ctx context.Context, color string, keys []int64, addrs []string) []error {
+	if len(keys) != len(addrs) {
+		return repeatErr(errors.Errorf("the count of keys and addrs not match. keys=%d addrs=%d", len(keys), len(addrs)), len(keys))
+	}
+	errs := make([]error, len(keys))
+	for i, key := range keys {
+		errs[i] = a.Store(ctx, color, key, addrs[i])
+	}
+	return errs
+}
+
+func (a extendedAdapter) DelMany(ctx context.Context, color string, keys []int64) []error {
+	errs := make([]error, len(keys))
+	for i, key := range keys {
+		errs[i] = a.Del(ctx, color, key)
+	}
+	return errs
+}
+
+func (a extendedAdapter) Renew(_ context.Context, _ string, keys []int64, _ string) []error {
+	return repeatErr(unsupported("Renew"), len(keys))
+}
+
+func (a extendedAdapter) Watch(context.Context, string) (<-chan Event, error) {
+	return nil, unsupported("Watch")
+}
+
+func (a extendedAdapter) ListByAddr(context.Context, string, string, string, int64) ([]int64, string, error) {
+	return nil, "", unsupported("ListByAddr")
+}
+
+func (a extendedAdapter) SetDraining(context.Context, string, string, bool) error {
+	return unsupported("SetDraining")
+}
+
+func (a extendedAdapter) Draining(_ context.Context, _ string, addrs []string) ([]bool, error) {
+	return make([]bool, len(addrs)), nil
+}
+
+type objectAdapter struct {
+	ext ExtendedRouteTable
+}
+
+func intOID(oid string) (int64, error) {
+	id, ok := parseOID(oid)
+	if !ok {
+		return 0, errors.Wrapf(verrors.ErrRouteTableUnsupported, "the route table supports only the int64 oids. oid=%s", oid)
+	}
+	return id, nil
+}
+
+func intOIDs(oids []string) ([]int64, []error, bool) {
+	ids := make([]int64, len(oids))
+	for i, oid := range oids {
+		id, err := intOID(oid)
+		if err != nil {
+			return nil, repeatErr(err, len(oids)), false
+		}
+		ids[i] = id
+	}
+	return ids, nil, true
+}
+
+func (a objectAdapter) LoadObject(ctx context.Context, color string, oid string) (string, error) {
+	id, err := intOID(oid)
+	if err != nil {
+		return "", err
+	}
+	return a.ext.Load(ctx, color, id)
+}
+
+func (a objectAdapter) LoadAndExpireObject(ctx context.Context, color string, oid string) (string, error) {
+	id, err := intOID(oid)
+	if err != nil {
+		return "", err
+	}
+	return a.ext.LoadAndExpire(ctx, color, id)
+}
+
+func (a objectAdapter) LoadGenObject(ctx context.Context, color string, oid string) (string, int64, error) {
+	id, err := intOID(oid)
+	if err != nil {
+		return "", 0, err
+	}
+	return a.ext.LoadGen(ctx, color, id)
+}
+
+func (a objectAdapter) LoadEntryObject(ctx context.Context, color string, oid string) (RouteEntry, error) {
+	id, err := intOID(oid)
+	if err != nil {
+		return RouteEntry{}, err
+	}
+	return a.ext.LoadEntry(ctx, color, id)
+}
+
+func (a objectAdapter) StoreObject(ctx context.Context, color string, oid string, addr string) error {
+	id, err := intOID(oid)
+	if err != nil {
+		return err
+	}
+	return a.ext.Store(ctx, color, id, addr)
+}
+
+func (a objectAdapter) StoreEntryObject(ctx context.Context, color string, oid string, entry RouteEntry) error {
+	id, err := intOID(oid)
+	if err != nil {
+		return err
+	}
+	return a.ext.StoreEntry(ctx, color, id, entry)
+}
+
+func (a objectAdapter) GetSetGenObject(ctx context.Context, color string, oid string, addr string) (string, int64, error) {
+	id, err := intOID(oid)
+	if err != nil {
+		return "", 0, err
+	}
+	return a.ext.GetSetGen(ctx, color, id, addr)
+}
+
+func (a objectAdapter) GetSetEntryObject(ctx context.Context, color string, oid string, entry RouteEntry) (RouteEntry, error) {
+	id, err := intOID(oid)
+	if err != nil {
+		return RouteEntry{}, err
+	}
+	return a.ext.GetSetEntry(ctx, color, id, entry)
+}
+
+func (a objectAdapter) SetNxEntryObject(ctx context.Context, color string, oid string, entry RouteEntry) (bool, RouteEntry, error) {
+	id, err := intOID(oid)
+	if err != nil {
+		return false, RouteEntry{}, err
+	}
+	return a.ext.SetNxEntry(ctx, color, id, entry)
+}
+
+func (a objectAdapter) CompareAndSwapObject(ctx context.Context, color string, oid string, expectedGen int64, addr string) (bool, int64, error) {
+	id, err := intOID(oid)
+	if err != nil {
+		return false, 0, err
+	}
+	return a.ext.CompareAndSwap(ctx, color, id, expectedGen, addr)
+}
+
+func (a objectAdapter) DelDelayObject(ctx context.Context, color string, oid string, delay time.Duration) error {
+	id, err := intOID(oid)
+	if err != nil {
+		return err
+	}
+	return a.ext.DelDelay(ctx, color, id, delay)
+}
+
+func (a objectAdapter) DelIfSameObject(ctx context.Context, color string, oid string, value string) error {
+	id, err := intOID(oid)
+	if err != nil {
+		return err
+	}
+	return a.ext.DelIfSame(ctx, color, id, value)
+}
+
+func (a objectAdapter) DelObject(ctx context.Context, color string, oid string) error {
+	id, err := intOID(oid)
+	if err != nil {
+		return err
+	}
+	return a.ext.Del(ctx, color, id)
+}
+
+func (a objectAdapter) StoreManyObjects(ctx context.Context, color string, oids []string, addrs []string) []error {
+	ids, errs, ok := intOIDs(oids)
+	if !ok {
+		return errs
+	}
+	return a.ext.StoreMany(ctx, color, ids, addrs)
+}
+
+func (a objectAdapter) DelManyObjects(ctx context.Context, color string, oids []string) []error {
+	ids, errs, ok := intOIDs(oids)
+	if !ok {
+		return errs
+	}
+	return a.ext.DelMany(ctx, color, ids)
+}
+
+func (a objectAdapter) RenewObjects(ctx context.Context, color string, oids []string, addr string) []error {
+	ids, errs, ok := intOIDs(oids)
+	if !ok {
+		return errs
+	}
+	return a.ext.Renew(ctx, color, ids, addr)
+}
+
+func (a objectAdapter) ListObjectsByAddr(ctx context.Context, color string, addr string, cursor string, count int64) ([]string, string, error) {
+	ids, next, err := a.ext.ListByAddr(ctx, color, addr, cursor, count)
+	if err != nil {
+		return nil, "", err
+	}
+	oids := make([]string, len(ids))
+	for i, id := range ids {
+		oids[i] = formatOID(id)
+	}
+	return oids, next, nil
+}
+
+func (a objectAdapter) SetDraining(ctx context.Context, color string, addr string, draining bool) error {
+	return a.ext.SetDraining(ctx, color, addr, draining)
+}
+
+func (a objectAdapter) Draining(ctx context.Context, color string, addrs []string) ([]bool, error) {
+	return a.ext.Draining(ctx, color, addrs)
+}
@@ -0,0 +1,51 @@
+package routetable_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	verrors "github.com/vulcan-frame/vulcan-pkg-app/errors"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable/memory"
+)
+
+// plainRouteTable hides the methods of BaseRouteTable out of the RouteTable interface
+type plainRouteTable struct {
+	routetable.RouteTable
+}
+
+func TestObjects_Adapter(t *testing.T) {
+	ctx := context.Background()
+	data := memory.NewRouteTable()
+	defer data.Close()
+	base := routetable.New(data, "player")
+
+	assert.Same(t, base, routetable.Objects(base))
+	assert.Nil(t, routetable.Objects(nil))
+
+	rt := routetable.Objects(plainRouteTable{RouteTable: base})
+	require.NoError(t, rt.StoreEntryObject(ctx, "red", "1", routetable.RouteEntry{Addr: "a:1", Node: "player-1"}))
+
+	addr, err := base.Load(ctx, "red", 1)
+	require.NoError(t, err)
+	assert.Equal(t, "a:1", routetable.AddrOf(addr))
+
+	entry, err := rt.LoadEntryObject(ctx, "red", "1")
+	require.NoError(t, err)
+	assert.Equal(t, "a:1", entry.Addr)
+
+	errs := rt.StoreManyObjects(ctx, "red", []string{"2", "3"}, []string{"b:1", "b:1"})
+	assert.Equal(t, []error{nil, nil}, errs)
+
+	_, err = rt.LoadObject(ctx, "red", "guild-1")
+	assert.True(t, errors.Is(err, verrors.ErrRouteTableUnsupported))
+	_, _, err = rt.LoadGenObject(ctx, "red", "1")
+	assert.True(t, errors.Is(err, verrors.ErrRouteTableUnsupported))
+
+	draining, err := rt.Draining(ctx, "red", []string{"a:1"})
+	require.NoError(t, err)
+	assert.Equal(t, []bool{false}, draining)
+}
@@ -0,0 +1,337 @@
+// Package admin is the admin service of the route table, it lets the ops inspect and fix the routes
+// without building the keys by hand. All the calls are gated to the callers in the admin status.
+package admin
+
+import (
+	"context"
+	nethttp "net/http"
+
+	kerrors "github.com/go-kratos/kratos/v2/errors"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/pkg/errors"
+	vctx "github.com/vulcan-frame/vulcan-pkg-app/context"
+	verrors "github.com/vulcan-frame/vulcan-pkg-app/errors"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+)
+
+const (
+	defaultListCount = 100
+	maxListCount     = 1000
+)
+
+var (
+	ErrNotFound        = kerrors.NotFound("route not found", "the route of the oid is not found")
+	ErrBadKeyFormat    = kerrors.BadRequest("illegal request", "the key format must be default or cluster")
+	ErrMissingArgument = kerrors.BadRequest("illegal request", "color, oid or addr is missing")
+	ErrUnsupported     = kerrors.New(nethttp.StatusNotImplemented, "unsupported", "the route table does not support the operation")
+)
+
+type Option func(*Service)
+
+// WithAdminStatus sets the status of the admin callers, which is compared with vctx.Status of the calls.
+// All the calls are rejected if it is not set.
+func WithAdminStatus(status int64) Option {
+	return func(s *Service) {
+		s.adminStatus = status
+	}
+}
+
+// WithAuditLogger sets the logger of the audit entries, the default is the global logger
+func WithAuditLogger(logger log.Logger) Option {
+	return func(s *Service) {
+		s.audit = log.NewHelper(logger)
+	}
+}
+
+// Service exposes Get, Set, Delete, ListByAddr and Migrate of the route table,
+// the name is the name of the route table in the audit entries.
+// The route table should be built as the services build it, so the keys and the ttl are the same.
+type Service struct {
+	name        string
+	rt          routetable.ObjectRouteTable
+	adminStatus int64
+	audit       *log.Helper
+}
+
+func NewService(name string, rt routetable.RouteTable, opts ...Option) *Service {
+	s := &Service{
+		name:  name,
+		rt:    routetable.Objects(rt),
+		audit: log.NewHelper(log.GetLogger()),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// the oids are the object strings of the routes, the int64 oids are in decimal.
+// The SID of the requests selects the routes of the route table scoped by routetable.WithSIDScope,
+// the SID of the ctx is used if it is not set.
+
+type GetRequest struct {
+	Color string `json:"color"`
+	OID   string `json:"oid"`
+	SID   *int64 `json:"sid,omitempty"`
+}
+
+type RouteReply struct {
+	Color      string `json:"color"`
+	OID        string `json:"oid"`
+	Addr       string `json:"addr"`
+	Node       string `json:"node,omitempty"`
+	Version    string `json:"version,omitempty"`
+	AssignedAt int64  `json:"assigned_at,omitempty"` // unix milliseconds
+	Gen        int64  `json:"gen"`
+}
+
+type SetRequest struct {
+	Color string `json:"color"`
+	OID   string `json:"oid"`
+	SID   *int64 `json:"sid,omitempty"`
+	Addr  string `json:"addr"`
+	// ExpectedGen sets the route only if the generation is not changed, 0 means no check
+	ExpectedGen int64 `json:"expected_gen,omitempty"`
+}
+
+type SetReply struct {
+	Old     string `json:"old"`
+	Gen     int64  `json:"gen"`
+	Swapped bool   `json:"swapped"`
+}
+
+type DeleteRequest struct {
+	Color string `json:"color"`
+	OID   string `json:"oid"`
+	SID   *int64 `json:"sid,omitempty"`
+	// Addr deletes the route only if it is still routed to the addr, empty means no check
+	Addr string `json:"addr,omitempty"`
+}
+
+type DeleteReply struct{}
+
+type ListByAddrRequest struct {
+	Color  string `json:"color"`
+	SID    *int64 `json:"sid,omitempty"`
+	Addr   string `json:"addr"`
+	Cursor string `json:"cursor,omitempty"`
+	Count  int64  `json:"count"`
+}
+
+type ListByAddrReply struct {
+	OIDs []string `json:"oids"`
+	// Cursor is the cursor of the next page, empty means the end
+	Cursor string `json:"cursor,omitempty"`
+}
+
+type MigrateRequest struct {
+	Color string `json:"color"`
+	SID   *int64 `json:"sid,omitempty"`
+	// From and To are the key formats, default or cluster
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type MigrateReply struct {
+	Migrated int `json:"migrated"`
+}
+
+func (s *Service) Get(ctx context.Context, req *GetRequest) (*RouteReply, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	if req.Color == "" || req.OID == "" {
+		return nil, ErrMissingArgument
+	}
+	oid := req.OID
+	ctx = scope(ctx, req.SID)
+
+	entry, err := s.rt.LoadEntryObject(ctx, req.Color, oid)
+	if err != nil {
+		return nil, convertErr(err)
+	}
+	_, gen, err := s.rt.LoadGenObject(ctx, req.Color, oid)
+	if err != nil {
+		return nil, convertErr(err)
+	}
+
+	reply := &RouteReply{
+		Color:   req.Color,
+		OID:     oid,
+		Addr:    entry.Addr,
+		Node:    entry.Node,
+		Version: entry.Version,
+		Gen:     gen,
+	}
+	if !entry.AssignedAt.IsZero() {
+		reply.AssignedAt = entry.AssignedAt.UnixMilli()
+	}
+	return reply, nil
+}
+
+func (s *Service) Set(ctx context.Context, req *SetRequest) (reply *SetReply, err error) {
+	if err = s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	if req.Color == "" || req.OID == "" || req.Addr == "" {
+		return nil, ErrMissingArgument
+	}
+	oid := req.OID
+	ctx = scope(ctx, req.SID)
+
+	reply = &SetReply{}
+	defer func() {
+		s.auditLog(ctx, "Set", req.Color, oid, "old", reply.Old, "new", req.Addr, "expected_gen", req.ExpectedGen, "gen", reply.Gen, "err", err)
+	}()
+
+	if req.ExpectedGen != 0 {
+		reply.Old, _, err = s.rt.LoadGenObject(ctx, req.Color, oid)
+		if err != nil && !errors.Is(err, verrors.ErrRouteTableNotFound) {
+			return nil, convertErr(err)
+		}
+		reply.Swapped, reply.Gen, err = s.rt.CompareAndSwapObject(ctx, req.Color, oid, req.ExpectedGen, req.Addr)
+		if err != nil {
+			return nil, convertErr(err)
+		}
+		return reply, nil
+	}
+
+	reply.Old, reply.Gen, err = s.rt.GetSetGenObject(ctx, req.Color, oid, req.Addr)
+	if err != nil && !errors.Is(err, verrors.ErrRouteTableNotFound) {
+		return nil, convertErr(err)
+	}
+	err = nil
+	reply.Swapped = true
+	return reply, nil
+}
+
+func (s *Service) Delete(ctx context.Context, req *DeleteRequest) (reply *DeleteReply, err error) {
+	if err = s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	if req.Color == "" || req.OID == "" {
+		return nil, ErrMissingArgument
+	}
+	oid := req.OID
+	ctx = scope(ctx, req.SID)
+
+	defer func() {
+		s.auditLog(ctx, "Delete", req.Color, oid, "if_addr", req.Addr, "err", err)
+	}()
+
+	if req.Addr != "" {
+		err = s.rt.DelIfSameObject(ctx, req.Color, oid, req.Addr)
+	} else {
+		err = s.rt.DelObject(ctx, req.Color, oid)
+	}
+	if err != nil {
+		return nil, convertErr(err)
+	}
+	return &DeleteReply{}, nil
+}
+
+func (s *Service) ListByAddr(ctx context.Context, req *ListByAddrRequest) (*ListByAddrReply, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	if req.Color == "" || req.Addr == "" {
+		return nil, ErrMissingArgument
+	}
+
+	count := req.Count
+	if count <= 0 {
+		count = defaultListCount
+	}
+	count = min(count, maxListCount)
+	ctx = scope(ctx, req.SID)
+
+	oids, next, err := s.rt.ListObjectsByAddr(ctx, req.Color, req.Addr, req.Cursor, count)
+	if err != nil {
+		return nil, convertErr(err)
+	}
+	return &ListByAddrReply{OIDs: oids, Cursor: next}, nil
+}
+
+func (s *Service) Migrate(ctx context.Context, req *MigrateRequest) (reply *MigrateReply, err error) {
+	if err = s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	from, ok := parseKeyFormat(req.From)
+	if !ok {
+		return nil, ErrBadKeyFormat
+	}
+	to, ok := parseKeyFormat(req.To)
+	if !ok {
+		return nil, ErrBadKeyFormat
+	}
+	ctx = scope(ctx, req.SID)
+
+	reply = &MigrateReply{}
+	defer func() {
+		s.auditLog(ctx, "Migrate", req.Color, "", "from", req.From, "to", req.To, "migrated", reply.Migrated, "err", err)
+	}()
+
+	m, ok := s.rt.(routetable.KeyMigrator)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	reply.Migrated, err = m.MigrateKeys(ctx, req.Color, from, to)
+	if err != nil {
+		return nil, convertErr(err)
+	}
+	return reply, nil
+}
+
+// auditLog writes the audit entry of the mutation with the caller
+func (s *Service) auditLog(ctx context.Context, op string, color string, oid string, keyvals ...any) {
+	uid, _ := vctx.UID(ctx)
+	kv := append([]any{
+		"audit", "routetable",
+		"op", op,
+		"name", s.name,
+		"color", color,
+		"oid", oid,
+		"sid", routetable.SIDFromContext(ctx),
+		"uid", uid,
+		"client_ip", vctx.ClientIP(ctx),
+	}, keyvals...)
+	s.audit.Infow(kv...)
+}
+
+// scope sets the SID of the route table calls if the request has it
+func scope(ctx context.Context, sid *int64) context.Context {
+	if sid == nil {
+		return ctx
+	}
+	return routetable.NewSIDContext(ctx, *sid)
+}
+
+// authorize allows the callers in the admin status only
+func (s *Service) authorize(ctx context.Context) error {
+	if s.adminStatus == 0 || vctx.Status(ctx) != s.adminStatus {
+		return verrors.ErrAPIStatusIllegal
+	}
+	return nil
+}
+
+func parseKeyFormat(name string) (routetable.KeyFormat, bool) {
+	switch name {
+	case "", "default":
+		return routetable.DefaultKeyFormat, true
+	case "cluster":
+		return routetable.ClusterKeyFormat, true
+	default:
+		return nil, false
+	}
+}
+
+func convertErr(err error) error {
+	if errors.Is(err, verrors.ErrRouteTableNotFound) {
+		return ErrNotFound
+	}
+	if errors.Is(err, verrors.ErrRouteTableUnsupported) {
+		return ErrUnsupported
+	}
+	log.Errorf("route table admin failed. err=%+v", err)
+	return verrors.ErrAPIServerErr
+}
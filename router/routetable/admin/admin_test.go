@@ -0,0 +1,222 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	kerrors "github.com/go-kratos/kratos/v2/errors"
+	"github.com/go-kratos/kratos/v2/metadata"
+	mmd "github.com/go-kratos/kratos/v2/middleware/metadata"
+	"github.com/go-kratos/kratos/v2/transport/grpc"
+	"github.com/go-kratos/kratos/v2/transport/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	vctx "github.com/vulcan-frame/vulcan-pkg-app/context"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable/memory"
+	ggrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	gmd "google.golang.org/grpc/metadata"
+)
+
+const (
+	adminStatus  = 2
+	clientStatus = 1
+)
+
+func statusCtx(status int64) context.Context {
+	md := metadata.New()
+	md.Set(vctx.CtxStatus, strconv.FormatInt(status, 10))
+	return metadata.NewServerContext(context.Background(), md)
+}
+
+func adminCtx() context.Context {
+	return statusCtx(adminStatus)
+}
+
+func newTestService(t *testing.T) *Service {
+	data := memory.NewRouteTable()
+	t.Cleanup(data.Close)
+	return NewService("test", routetable.New(data, "test"), WithAdminStatus(adminStatus))
+}
+
+func TestService(t *testing.T) {
+	ctx := adminCtx()
+	s := newTestService(t)
+
+	_, err := s.Get(ctx, &GetRequest{Color: "red", OID: "1"})
+	assert.True(t, kerrors.IsNotFound(err))
+
+	set, err := s.Set(ctx, &SetRequest{Color: "red", OID: "1", Addr: "a"})
+	require.NoError(t, err)
+	assert.True(t, set.Swapped)
+
+	route, err := s.Get(ctx, &GetRequest{Color: "red", OID: "1"})
+	require.NoError(t, err)
+	assert.Equal(t, "a", route.Addr)
+	assert.Equal(t, set.Gen, route.Gen)
+
+	set, err = s.Set(ctx, &SetRequest{Color: "red", OID: "1", Addr: "b", ExpectedGen: route.Gen + 1})
+	require.NoError(t, err)
+	assert.False(t, set.Swapped)
+
+	list, err := s.ListByAddr(ctx, &ListByAddrRequest{Color: "red", Addr: "a"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1"}, list.OIDs)
+
+	_, err = s.Delete(ctx, &DeleteRequest{Color: "red", OID: "1", Addr: "b"})
+	require.NoError(t, err)
+	_, err = s.Get(ctx, &GetRequest{Color: "red", OID: "1"})
+	require.NoError(t, err)
+
+	_, err = s.Delete(ctx, &DeleteRequest{Color: "red", OID: "1"})
+	require.NoError(t, err)
+	_, err = s.Get(ctx, &GetRequest{Color: "red", OID: "1"})
+	assert.True(t, kerrors.IsNotFound(err))
+}
+
+func TestService_OID(t *testing.T) {
+	ctx := adminCtx()
+	s := newTestService(t)
+
+	_, err := s.Get(ctx, &GetRequest{Color: "red"})
+	assert.True(t, kerrors.IsBadRequest(err), "the oid is missing")
+
+	// 0 is a valid oid
+	_, err = s.Set(ctx, &SetRequest{Color: "red", OID: "0", Addr: "a"})
+	require.NoError(t, err)
+	route, err := s.Get(ctx, &GetRequest{Color: "red", OID: "0"})
+	require.NoError(t, err)
+	assert.Equal(t, "a", route.Addr)
+
+	// the string oids are routed as well
+	_, err = s.Set(ctx, &SetRequest{Color: "red", OID: "room-1", Addr: "b"})
+	require.NoError(t, err)
+	list, err := s.ListByAddr(ctx, &ListByAddrRequest{Color: "red", Addr: "b"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"room-1"}, list.OIDs)
+}
+
+func TestService_Migrate(t *testing.T) {
+	ctx := adminCtx()
+	data := memory.NewRouteTable()
+	t.Cleanup(data.Close)
+	s := NewService("test", routetable.New(data, "test", routetable.WithKeyFormat(routetable.ClusterKeyFormat)), WithAdminStatus(adminStatus))
+
+	old := routetable.New(data, "test")
+	require.NoError(t, old.Store(context.Background(), "red", 1, "a"))
+
+	reply, err := s.Migrate(ctx, &MigrateRequest{Color: "red", From: "default", To: "cluster"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, reply.Migrated)
+	route, err := s.Get(ctx, &GetRequest{Color: "red", OID: "1"})
+	require.NoError(t, err)
+	assert.Equal(t, "a", route.Addr)
+}
+
+func TestService_SID(t *testing.T) {
+	ctx := adminCtx()
+	data := memory.NewRouteTable()
+	t.Cleanup(data.Close)
+	s := NewService("test", routetable.New(data, "test", routetable.WithSIDScope()), WithAdminStatus(adminStatus))
+
+	sid := int64(2)
+	_, err := s.Set(ctx, &SetRequest{Color: "red", OID: "1", SID: &sid, Addr: "a"})
+	require.NoError(t, err)
+	_, err = s.Get(ctx, &GetRequest{Color: "red", OID: "1"})
+	assert.True(t, kerrors.IsNotFound(err), "the route is in sid 2")
+
+	srv := http.NewServer(http.Middleware(mmd.Server()))
+	RegisterHTTPServer(srv, s)
+	req := httptest.NewRequest(nethttp.MethodGet, "/routetable/v1/routes/red/1?sid=2", nil)
+	req.Header.Set(vctx.CtxStatus, strconv.Itoa(adminStatus))
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	require.Equal(t, nethttp.StatusOK, w.Code, w.Body.String())
+	var route RouteReply
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &route))
+	assert.Equal(t, "a", route.Addr)
+}
+
+func TestService_Forbidden(t *testing.T) {
+	s := newTestService(t)
+
+	_, err := s.Get(context.Background(), &GetRequest{Color: "red", OID: "1"})
+	assert.True(t, kerrors.IsForbidden(err))
+
+	// the calls are rejected if the admin status is not set
+	_, err = NewService("test", s.rt.(*routetable.BaseRouteTable)).Get(statusCtx(0), &GetRequest{Color: "red", OID: "1"})
+	assert.True(t, kerrors.IsForbidden(err))
+
+	_, err = s.Set(statusCtx(clientStatus), &SetRequest{Color: "red", OID: "1", Addr: "a"})
+	assert.True(t, kerrors.IsForbidden(err))
+}
+
+func TestService_HTTP(t *testing.T) {
+	srv := http.NewServer(http.Middleware(mmd.Server()))
+	RegisterHTTPServer(srv, newTestService(t))
+
+	do := func(method, path, body string, admin bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if admin {
+			req.Header.Set(vctx.CtxStatus, strconv.Itoa(adminStatus))
+		}
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(nethttp.MethodPut, "/routetable/v1/routes/red/1", `{"addr":"a"}`, false)
+	assert.Equal(t, nethttp.StatusForbidden, w.Code)
+
+	w = do(nethttp.MethodPut, "/routetable/v1/routes/red/1", `{"addr":"a"}`, true)
+	require.Equal(t, nethttp.StatusOK, w.Code, w.Body.String())
+
+	w = do(nethttp.MethodGet, "/routetable/v1/routes/red/1", "", true)
+	require.Equal(t, nethttp.StatusOK, w.Code, w.Body.String())
+	var route RouteReply
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &route))
+	assert.Equal(t, "a", route.Addr)
+	assert.Equal(t, "1", route.OID)
+
+	w = do(nethttp.MethodGet, "/routetable/v1/addrs/red/a/routes?count=10", "", true)
+	require.Equal(t, nethttp.StatusOK, w.Code, w.Body.String())
+	var list ListByAddrReply
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Equal(t, []string{"1"}, list.OIDs)
+}
+
+func TestService_GRPC(t *testing.T) {
+	srv := grpc.NewServer(grpc.Address("127.0.0.1:0"), grpc.Middleware(mmd.Server()))
+	RegisterGRPCServer(srv, newTestService(t))
+	endpoint, err := srv.Endpoint()
+	require.NoError(t, err)
+	go func() {
+		_ = srv.Start(context.Background())
+	}()
+	t.Cleanup(func() {
+		_ = srv.Stop(context.Background())
+	})
+
+	cc, err := ggrpc.NewClient(endpoint.Host, ggrpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer cc.Close()
+
+	client := NewClient(cc)
+	ctx := context.Background()
+	_, err = client.Set(ctx, &SetRequest{Color: "red", OID: "1", Addr: "a"})
+	assert.True(t, kerrors.IsForbidden(err))
+
+	ctx = gmd.AppendToOutgoingContext(ctx, vctx.CtxStatus, strconv.Itoa(adminStatus))
+	_, err = client.Set(ctx, &SetRequest{Color: "red", OID: "1", Addr: "a"})
+	require.NoError(t, err)
+	route, err := client.Get(ctx, &GetRequest{Color: "red", OID: "1"})
+	require.NoError(t, err)
+	assert.Equal(t, "a", route.Addr)
+}
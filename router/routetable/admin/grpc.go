@@ -0,0 +1,123 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/go-kratos/kratos/v2/transport/grpc"
+	ggrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+)
+
+const (
+	serviceName     = "vulcan.routetable.admin.v1.RouteTableAdmin"
+	operationPrefix = "/" + serviceName + "/"
+
+	// CodecName is the content subtype of the grpc calls, the messages are in json since they are not protobuf.
+	// The clients call with grpc.CallContentSubtype(CodecName).
+	CodecName = "json"
+)
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) Name() string {
+	return CodecName
+}
+
+// RouteTableAdminServer is the server API of the service
+type RouteTableAdminServer interface {
+	Get(context.Context, *GetRequest) (*RouteReply, error)
+	Set(context.Context, *SetRequest) (*SetReply, error)
+	Delete(context.Context, *DeleteRequest) (*DeleteReply, error)
+	ListByAddr(context.Context, *ListByAddrRequest) (*ListByAddrReply, error)
+	Migrate(context.Context, *MigrateRequest) (*MigrateReply, error)
+}
+
+var _ RouteTableAdminServer = (*Service)(nil)
+
+// RegisterGRPCServer registers the service on the grpc server, the middlewares of the server are applied
+func RegisterGRPCServer(srv *grpc.Server, s RouteTableAdminServer) {
+	srv.RegisterService(&serviceDesc, s)
+}
+
+var serviceDesc = ggrpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*RouteTableAdminServer)(nil),
+	Methods: []ggrpc.MethodDesc{
+		{MethodName: "Get", Handler: grpcHandler(RouteTableAdminServer.Get, "Get")},
+		{MethodName: "Set", Handler: grpcHandler(RouteTableAdminServer.Set, "Set")},
+		{MethodName: "Delete", Handler: grpcHandler(RouteTableAdminServer.Delete, "Delete")},
+		{MethodName: "ListByAddr", Handler: grpcHandler(RouteTableAdminServer.ListByAddr, "ListByAddr")},
+		{MethodName: "Migrate", Handler: grpcHandler(RouteTableAdminServer.Migrate, "Migrate")},
+	},
+}
+
+func grpcHandler[Req any, Reply any](f func(RouteTableAdminServer, context.Context, *Req) (*Reply, error), method string) ggrpc.MethodHandler {
+	return func(srv any, ctx context.Context, dec func(any) error, interceptor ggrpc.UnaryServerInterceptor) (any, error) {
+		in := new(Req)
+		if err := dec(in); err != nil {
+			return nil, err
+		}
+		s := srv.(RouteTableAdminServer)
+		if interceptor == nil {
+			return f(s, ctx, in)
+		}
+		info := &ggrpc.UnaryServerInfo{
+			Server:     srv,
+			FullMethod: operationPrefix + method,
+		}
+		return interceptor(ctx, in, info, func(ctx context.Context, req any) (any, error) {
+			return f(s, ctx, req.(*Req))
+		})
+	}
+}
+
+// Client is the grpc client of the service
+type Client struct {
+	cc ggrpc.ClientConnInterface
+}
+
+func NewClient(cc ggrpc.ClientConnInterface) *Client {
+	return &Client{cc: cc}
+}
+
+func (c *Client) Get(ctx context.Context, in *GetRequest, opts ...ggrpc.CallOption) (*RouteReply, error) {
+	return invoke[RouteReply](ctx, c.cc, "Get", in, opts)
+}
+
+func (c *Client) Set(ctx context.Context, in *SetRequest, opts ...ggrpc.CallOption) (*SetReply, error) {
+	return invoke[SetReply](ctx, c.cc, "Set", in, opts)
+}
+
+func (c *Client) Delete(ctx context.Context, in *DeleteRequest, opts ...ggrpc.CallOption) (*DeleteReply, error) {
+	return invoke[DeleteReply](ctx, c.cc, "Delete", in, opts)
+}
+
+func (c *Client) ListByAddr(ctx context.Context, in *ListByAddrRequest, opts ...ggrpc.CallOption) (*ListByAddrReply, error) {
+	return invoke[ListByAddrReply](ctx, c.cc, "ListByAddr", in, opts)
+}
+
+func (c *Client) Migrate(ctx context.Context, in *MigrateRequest, opts ...ggrpc.CallOption) (*MigrateReply, error) {
+	return invoke[MigrateReply](ctx, c.cc, "Migrate", in, opts)
+}
+
+func invoke[Reply any](ctx context.Context, cc ggrpc.ClientConnInterface, method string, in any, opts []ggrpc.CallOption) (*Reply, error) {
+	out := new(Reply)
+	opts = append([]ggrpc.CallOption{ggrpc.CallContentSubtype(CodecName)}, opts...)
+	if err := cc.Invoke(ctx, operationPrefix+method, in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,54 @@
+package admin
+
+import (
+	"context"
+
+	"github.com/go-kratos/kratos/v2/transport/http"
+)
+
+const httpPrefix = "/routetable/v1"
+
+// RegisterHTTPServer registers the service on the http server, the middlewares of the server are applied.
+//
+//	GET    /routetable/v1/routes/{color}/{oid}
+//	PUT    /routetable/v1/routes/{color}/{oid}    body: {"addr": "...", "expected_gen": 0}
+//	DELETE /routetable/v1/routes/{color}/{oid}?addr=...
+//	GET    /routetable/v1/addrs/{color}/{addr}/routes?cursor=&count=100
+//	POST   /routetable/v1/migrate                 body: {"color": "...", "from": "default", "to": "cluster"}
+//
+// The route table scoped by sid takes the sid in the query, or in the body of PUT and POST.
+func RegisterHTTPServer(srv *http.Server, s *Service) {
+	r := srv.Route(httpPrefix)
+	r.GET("/routes/{color}/{oid}", httpHandler(s.Get, "Get", false))
+	r.PUT("/routes/{color}/{oid}", httpHandler(s.Set, "Set", true))
+	r.DELETE("/routes/{color}/{oid}", httpHandler(s.Delete, "Delete", false))
+	r.GET("/addrs/{color}/{addr}/routes", httpHandler(s.ListByAddr, "ListByAddr", false))
+	r.POST("/migrate", httpHandler(s.Migrate, "Migrate", true))
+}
+
+// httpHandler binds the request from the body or the query, and the path variables
+func httpHandler[Req any, Reply any](f func(context.Context, *Req) (*Reply, error), method string, body bool) http.HandlerFunc {
+	return func(ctx http.Context) error {
+		var in Req
+		if body {
+			if err := ctx.Bind(&in); err != nil {
+				return err
+			}
+		} else if err := ctx.BindQuery(&in); err != nil {
+			return err
+		}
+		if err := ctx.BindVars(&in); err != nil {
+			return err
+		}
+
+		http.SetOperation(ctx, operationPrefix+method)
+		h := ctx.Middleware(func(ctx context.Context, req any) (any, error) {
+			return f(ctx, req.(*Req))
+		})
+		out, err := h(ctx, &in)
+		if err != nil {
+			return err
+		}
+		return ctx.Result(200, out)
+	}
+}
@@ -0,0 +1,423 @@
+package async
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/pkg/errors"
+	verrors "github.com/vulcan-frame/vulcan-pkg-app/errors"
+	"github.com/vulcan-frame/vulcan-pkg-app/router"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+)
+
+const (
+	defaultDelay      = time.Millisecond * 50
+	defaultBatchSize  = 500
+	defaultMaxPending = 100000
+	defaultTimeout    = router.AsyncRouteTableTimeout
+)
+
+var (
+	// ErrWriterClosed is returned by the writes after the Writer is closed
+	ErrWriterClosed = errors.New("the async route table writer is closed")
+	// ErrQueueFull is returned when the pending keys reach the max, the caller may write synchronously instead
+	ErrQueueFull = errors.New("the async route table writer queue is full")
+	// ErrConflict is returned by the Renew when the key has a pending Renew of another addr, the caller may retry it later
+	ErrConflict = errors.New("the async route table renewal conflicts with the pending one")
+)
+
+type Option func(*Writer)
+
+// WithDelay sets the max delay of a write before it is flushed, it must be positive
+func WithDelay(dur time.Duration) Option {
+	return func(w *Writer) {
+		w.delay = dur
+	}
+}
+
+// WithBatchSize sets the count of the pending keys which triggers the flush before the delay,
+// it is also the max count of the keys written by a batch method. It must be positive.
+func WithBatchSize(size int) Option {
+	return func(w *Writer) {
+		w.batchSize = size
+	}
+}
+
+// WithMaxPending sets the max count of the pending keys, the writes to the new keys fail with ErrQueueFull beyond it.
+// It must be positive.
+func WithMaxPending(size int) Option {
+	return func(w *Writer) {
+		w.maxPending = size
+	}
+}
+
+// WithTimeout sets the timeout of a flush, the default is router.AsyncRouteTableTimeout. It must be positive.
+func WithTimeout(dur time.Duration) Option {
+	return func(w *Writer) {
+		w.timeout = dur
+	}
+}
+
+// Future is the result of an async write, it can be ignored if the caller doesn't care
+type Future struct {
+	done chan struct{}
+	err  error
+}
+
+func newFuture() *Future {
+	return &Future{done: make(chan struct{})}
+}
+
+func failedFuture(err error) *Future {
+	f := newFuture()
+	f.complete(err)
+	return f
+}
+
+func (f *Future) complete(err error) {
+	f.err = err
+	close(f.done)
+}
+
+// Done is closed when the write is flushed
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Err returns the result of the write, it's valid after Done is closed
+func (f *Future) Err() error {
+	return f.err
+}
+
+// Wait waits for the result of the write
+func (f *Future) Wait(ctx context.Context) error {
+	select {
+	case <-f.done:
+		return f.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type opKind int
+
+const (
+	opStore opKind = iota
+	opRenew
+	opDel
+)
+
+// routeKey is the key of the route in the SID scope of the enqueuing ctx
+type routeKey struct {
+	sid   int64
+	color string
+	oid   string
+}
+
+func newRouteKey(ctx context.Context, color string, oid string) routeKey {
+	return routeKey{sid: routetable.SIDFromContext(ctx), color: color, oid: oid}
+}
+
+// group is the keys of a SID scope and a color, the renewals are grouped by the addr too
+type group struct {
+	sid   int64
+	color string
+	addr  string
+}
+
+// op is the pending write of a key, the futures of the coalesced writes complete with its result
+type op struct {
+	kind    opKind
+	addr    string
+	futures []*Future
+}
+
+// merge coalesces the incoming write into the pending one. The Store and the Del overwrite the pending write,
+// the Renew never does: it's merged into the pending write of the same addr, and it fails otherwise.
+func (o *op) merge(kind opKind, addr string) error {
+	switch {
+	case kind != opRenew:
+		o.kind, o.addr = kind, addr
+		return nil
+	case o.kind != opDel && o.addr == addr:
+		// the Store sets the ttl too
+		return nil
+	case o.kind == opRenew:
+		return ErrConflict
+	default:
+		// the route is replaced or deleted by the pending write before the renewal
+		return errors.Wrapf(verrors.ErrRouteTableNotFound, "the route is overwritten by the pending write. addr=%s", addr)
+	}
+}
+
+// Writer queues the route table writes out of the hot path, such as the Store after login and the ttl refreshes.
+// The writes to the same key are coalesced, the last Store or Del wins and the Renew is merged into them, see op.merge.
+// Then they are flushed by the pipelined batch methods of RouteTable within the delay. The pending writes are flushed by Close.
+type Writer struct {
+	rt         routetable.ObjectRouteTable
+	delay      time.Duration
+	batchSize  int
+	maxPending int
+	timeout    time.Duration
+
+	mu      sync.Mutex
+	pending map[routeKey]*op
+	closed  bool
+
+	full   chan struct{}
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New creates the writer and starts the flush, it fails if the options are not positive
+func New(rt routetable.RouteTable, opts ...Option) (*Writer, error) {
+	w := &Writer{
+		rt:         routetable.Objects(rt),
+		delay:      defaultDelay,
+		batchSize:  defaultBatchSize,
+		maxPending: defaultMaxPending,
+		timeout:    defaultTimeout,
+		pending:    make(map[routeKey]*op),
+		full:       make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	switch {
+	case w.delay <= 0:
+		return nil, errors.Errorf("the delay must be positive. delay=%s", w.delay)
+	case w.batchSize <= 0:
+		return nil, errors.Errorf("the batch size must be positive. batch_size=%d", w.batchSize)
+	case w.maxPending <= 0:
+		return nil, errors.Errorf("the max pending must be positive. max_pending=%d", w.maxPending)
+	case w.timeout <= 0:
+		return nil, errors.Errorf("the timeout must be positive. timeout=%s", w.timeout)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	w.wg.Add(1)
+	go w.flushLoop(ctx)
+	return w, nil
+}
+
+// Store queues the Store of the route. The SID of the ctx is kept for the route table scoped by
+// routetable.WithSIDScope, the other values of the ctx are not, the write is flushed with the ctx of the Writer.
+func (w *Writer) Store(ctx context.Context, color string, oid int64, addr string) *Future {
+	return w.StoreObject(ctx, color, formatOID(oid), addr)
+}
+
+// Renew queues the ttl refresh of the route owned by the addr,
+// the result is ErrRouteTableNotFound if the route is lost or overwritten by a pending write,
+// and ErrConflict if the route has a pending renewal of another addr.
+func (w *Writer) Renew(ctx context.Context, color string, oid int64, addr string) *Future {
+	return w.RenewObject(ctx, color, formatOID(oid), addr)
+}
+
+// Del queues the Del of the route
+func (w *Writer) Del(ctx context.Context, color string, oid int64) *Future {
+	return w.DelObject(ctx, color, formatOID(oid))
+}
+
+// StoreObject is Store of the string oid, the int64 oids are the same routes as their decimal strings
+func (w *Writer) StoreObject(ctx context.Context, color string, oid string, addr string) *Future {
+	return w.enqueue(newRouteKey(ctx, color, oid), opStore, addr)
+}
+
+// RenewObject is Renew of the string oid
+func (w *Writer) RenewObject(ctx context.Context, color string, oid string, addr string) *Future {
+	return w.enqueue(newRouteKey(ctx, color, oid), opRenew, addr)
+}
+
+// DelObject is Del of the string oid
+func (w *Writer) DelObject(ctx context.Context, color string, oid string) *Future {
+	return w.enqueue(newRouteKey(ctx, color, oid), opDel, "")
+}
+
+// Pending returns the count of the pending keys
+func (w *Writer) Pending() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return len(w.pending)
+}
+
+// Close stops the writer and flushes the pending writes until the ctx is done
+func (w *Writer) Close(ctx context.Context) error {
+	w.mu.Lock()
+	w.closed = true
+	w.mu.Unlock()
+
+	w.cancel()
+	w.wg.Wait()
+
+	w.flush(ctx)
+	return ctx.Err()
+}
+
+func (w *Writer) enqueue(key routeKey, kind opKind, addr string) *Future {
+	f := newFuture()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return failedFuture(ErrWriterClosed)
+	}
+
+	if o, ok := w.pending[key]; ok {
+		if err := o.merge(kind, addr); err != nil {
+			return failedFuture(err)
+		}
+		o.futures = append(o.futures, f)
+		return f
+	}
+
+	if len(w.pending) >= w.maxPending {
+		return failedFuture(ErrQueueFull)
+	}
+	w.pending[key] = &op{kind: kind, addr: addr, futures: []*Future{f}}
+	if len(w.pending) >= w.batchSize {
+		select {
+		case w.full <- struct{}{}:
+		default:
+		}
+	}
+	return f
+}
+
+func (w *Writer) flushLoop(ctx context.Context) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.delay)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.full:
+		}
+
+		fctx, cancel := context.WithTimeout(context.Background(), w.timeout)
+		w.flush(fctx)
+		cancel()
+	}
+}
+
+// batch is the keys of a group flushed by one batch method
+type batch struct {
+	oids  []string
+	addrs []string
+	ops   []*op
+}
+
+func (b *batch) add(oid string, o *op) {
+	b.oids = append(b.oids, oid)
+	b.addrs = append(b.addrs, o.addr)
+	b.ops = append(b.ops, o)
+}
+
+func (b *batch) complete(errs []error) {
+	for i, o := range b.ops {
+		for _, f := range o.futures {
+			f.complete(errs[i])
+		}
+	}
+}
+
+// flush writes the pending writes in batches
+func (w *Writer) flush(ctx context.Context) {
+	w.mu.Lock()
+	pending := w.pending
+	w.pending = make(map[routeKey]*op, len(pending))
+	w.mu.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+
+	var (
+		stores = make(map[group]*batch)
+		dels   = make(map[group]*batch)
+		renews = make(map[group]*batch)
+	)
+	add := func(m map[group]*batch, g group, oid string, o *op) {
+		b, ok := m[g]
+		if !ok {
+			b = &batch{}
+			m[g] = b
+		}
+		b.add(oid, o)
+	}
+	for key, o := range pending {
+		g := group{sid: key.sid, color: key.color}
+		switch o.kind {
+		case opStore:
+			add(stores, g, key.oid, o)
+		case opDel:
+			add(dels, g, key.oid, o)
+		case opRenew:
+			g.addr = o.addr
+			add(renews, g, key.oid, o)
+		}
+	}
+
+	for g, b := range stores {
+		sctx := routetable.NewSIDContext(ctx, g.sid)
+		w.write(ctx, "store", g.color, b, func(oids []string, addrs []string) []error {
+			return w.rt.StoreManyObjects(sctx, g.color, oids, addrs)
+		})
+	}
+	for g, b := range dels {
+		sctx := routetable.NewSIDContext(ctx, g.sid)
+		w.write(ctx, "del", g.color, b, func(oids []string, _ []string) []error {
+			return w.rt.DelManyObjects(sctx, g.color, oids)
+		})
+	}
+	for g, b := range renews {
+		sctx := routetable.NewSIDContext(ctx, g.sid)
+		w.write(ctx, "renew", g.color, b, func(oids []string, _ []string) []error {
+			return w.rt.RenewObjects(sctx, g.color, oids, g.addr)
+		})
+	}
+}
+
+// write calls fn with the batch in the chunks of the batch size and completes the futures
+func (w *Writer) write(ctx context.Context, name, color string, b *batch, fn func(oids []string, addrs []string) []error) {
+	for start := 0; start < len(b.oids); start += w.batchSize {
+		end := min(start+w.batchSize, len(b.oids))
+		chunk := &batch{oids: b.oids[start:end], addrs: b.addrs[start:end], ops: b.ops[start:end]}
+
+		var errs []error
+		if err := ctx.Err(); err != nil {
+			errs = make([]error, len(chunk.oids))
+			for i := range errs {
+				errs[i] = err
+			}
+		} else {
+			errs = fn(chunk.oids, chunk.addrs)
+		}
+
+		failed := 0
+		for _, err := range errs {
+			// the lost route of the renewal is expected
+			if err != nil && !errors.Is(err, verrors.ErrRouteTableNotFound) {
+				failed++
+			}
+		}
+		if failed > 0 {
+			log.Errorf("async route table %s failed. color=%s failed=%d total=%d", name, color, failed, len(chunk.oids))
+		}
+		chunk.complete(errs)
+	}
+}
+
+func formatOID(oid int64) string {
+	return strconv.FormatInt(oid, 10)
+}
@@ -0,0 +1,196 @@
+package async
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	verrors "github.com/vulcan-frame/vulcan-pkg-app/errors"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable/memory"
+)
+
+func newRouteTable(t *testing.T) *routetable.BaseRouteTable {
+	data := memory.NewRouteTable()
+	t.Cleanup(data.Close)
+	return routetable.New(data, "test")
+}
+
+func TestWriter_Coalesce(t *testing.T) {
+	ctx := context.Background()
+	rt := newRouteTable(t)
+	require.NoError(t, rt.Store(ctx, "red", 3, "a:1"))
+
+	w, err := New(rt, WithDelay(time.Millisecond*10))
+	require.NoError(t, err)
+	defer w.Close(ctx)
+
+	first := w.Store(ctx, "red", 1, "a:1")
+	second := w.Store(ctx, "red", 1, "b:1")
+	stored := w.Store(ctx, "red", 2, "a:1")
+	deleted := w.Del(ctx, "red", 2)
+	lost := w.Renew(ctx, "red", 4, "a:1")
+	renewed := w.Renew(ctx, "red", 3, "a:1")
+	// the int64 oid is the same key as its decimal string
+	object := w.StoreObject(ctx, "red", "room-1", "c:1")
+	coalesced := w.StoreObject(ctx, "red", "1", "b:1")
+
+	for _, f := range []*Future{first, second, stored, deleted, renewed, object, coalesced} {
+		require.NoError(t, f.Wait(ctx))
+	}
+	assert.ErrorIs(t, lost.Wait(ctx), verrors.ErrRouteTableNotFound)
+
+	addr, err := rt.Load(ctx, "red", 1)
+	require.NoError(t, err)
+	assert.Equal(t, "b:1", addr)
+	addr, err = rt.LoadObject(ctx, "red", "room-1")
+	require.NoError(t, err)
+	assert.Equal(t, "c:1", addr)
+	_, err = rt.Load(ctx, "red", 2)
+	assert.ErrorIs(t, err, verrors.ErrRouteTableNotFound)
+}
+
+func TestOp_Merge(t *testing.T) {
+	tests := []struct {
+		name     string
+		pending  op
+		kind     opKind
+		addr     string
+		want     op
+		notFound bool
+		conflict bool
+	}{
+		{name: "store store", pending: op{kind: opStore, addr: "a"}, kind: opStore, addr: "b", want: op{kind: opStore, addr: "b"}},
+		{name: "store del", pending: op{kind: opStore, addr: "a"}, kind: opDel, want: op{kind: opDel}},
+		{name: "store renew same addr", pending: op{kind: opStore, addr: "a"}, kind: opRenew, addr: "a", want: op{kind: opStore, addr: "a"}},
+		{name: "store renew other addr", pending: op{kind: opStore, addr: "a"}, kind: opRenew, addr: "b", want: op{kind: opStore, addr: "a"}, notFound: true},
+		{name: "del store", pending: op{kind: opDel}, kind: opStore, addr: "a", want: op{kind: opStore, addr: "a"}},
+		{name: "del del", pending: op{kind: opDel}, kind: opDel, want: op{kind: opDel}},
+		{name: "del renew", pending: op{kind: opDel}, kind: opRenew, addr: "a", want: op{kind: opDel}, notFound: true},
+		{name: "renew store", pending: op{kind: opRenew, addr: "a"}, kind: opStore, addr: "b", want: op{kind: opStore, addr: "b"}},
+		{name: "renew del", pending: op{kind: opRenew, addr: "a"}, kind: opDel, want: op{kind: opDel}},
+		{name: "renew renew same addr", pending: op{kind: opRenew, addr: "a"}, kind: opRenew, addr: "a", want: op{kind: opRenew, addr: "a"}},
+		{name: "renew renew other addr", pending: op{kind: opRenew, addr: "a"}, kind: opRenew, addr: "b", want: op{kind: opRenew, addr: "a"}, conflict: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := tt.pending
+			err := o.merge(tt.kind, tt.addr)
+			switch {
+			case tt.notFound:
+				assert.ErrorIs(t, err, verrors.ErrRouteTableNotFound)
+			case tt.conflict:
+				assert.ErrorIs(t, err, ErrConflict)
+			default:
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, o)
+		})
+	}
+}
+
+func TestWriter_RenewAfterStore(t *testing.T) {
+	ctx := context.Background()
+	rt := newRouteTable(t)
+	require.NoError(t, rt.Store(ctx, "red", 1, "a:1"))
+
+	w, err := New(rt, WithDelay(time.Hour))
+	require.NoError(t, err)
+
+	stored := w.Store(ctx, "red", 1, "b:1")
+	// the renewal of the old owner never replaces the pending Store
+	assert.ErrorIs(t, w.Renew(ctx, "red", 1, "a:1").Err(), verrors.ErrRouteTableNotFound)
+	deleted := w.Del(ctx, "red", 2)
+	assert.ErrorIs(t, w.Renew(ctx, "red", 2, "a:1").Err(), verrors.ErrRouteTableNotFound)
+
+	require.NoError(t, w.Close(ctx))
+	require.NoError(t, stored.Err())
+	require.NoError(t, deleted.Err())
+
+	addr, err := rt.Load(ctx, "red", 1)
+	require.NoError(t, err)
+	assert.Equal(t, "b:1", addr)
+}
+
+func TestWriter_SIDScope(t *testing.T) {
+	ctx := context.Background()
+	data := memory.NewRouteTable()
+	t.Cleanup(data.Close)
+	rt := routetable.New(data, "test", routetable.WithSIDScope())
+
+	w, err := New(rt, WithDelay(time.Hour))
+	require.NoError(t, err)
+
+	// the same oid in the different SID scopes are the different keys
+	first := w.Store(routetable.NewSIDContext(ctx, 1), "red", 1, "a:1")
+	second := w.Store(routetable.NewSIDContext(ctx, 2), "red", 1, "b:1")
+	assert.Equal(t, 2, w.Pending())
+
+	require.NoError(t, w.Close(ctx))
+	require.NoError(t, first.Err())
+	require.NoError(t, second.Err())
+
+	addr, err := rt.Load(routetable.NewSIDContext(ctx, 1), "red", 1)
+	require.NoError(t, err)
+	assert.Equal(t, "a:1", addr)
+	addr, err = rt.Load(routetable.NewSIDContext(ctx, 2), "red", 1)
+	require.NoError(t, err)
+	assert.Equal(t, "b:1", addr)
+	_, err = rt.Load(ctx, "red", 1)
+	assert.ErrorIs(t, err, verrors.ErrRouteTableNotFound)
+}
+
+func TestWriter_Close(t *testing.T) {
+	ctx := context.Background()
+	rt := newRouteTable(t)
+
+	w, err := New(rt, WithDelay(time.Hour), WithMaxPending(2))
+	require.NoError(t, err)
+	f := w.Store(ctx, "red", 1, "a:1")
+	w.Store(ctx, "red", 2, "a:1")
+	assert.ErrorIs(t, w.Store(ctx, "red", 3, "a:1").Err(), ErrQueueFull)
+	// the write to the pending key is coalesced when the queue is full
+	assert.NotErrorIs(t, w.Store(ctx, "red", 2, "b:1").Err(), ErrQueueFull)
+
+	require.NoError(t, w.Close(ctx))
+	require.NoError(t, f.Err())
+	assert.Zero(t, w.Pending())
+
+	addrs, errs := rt.LoadMany(ctx, "red", []int64{1, 2})
+	assert.Equal(t, []error{nil, nil}, errs)
+	assert.Equal(t, []string{"a:1", "b:1"}, addrs)
+
+	assert.ErrorIs(t, w.Store(ctx, "red", 1, "a:1").Err(), ErrWriterClosed)
+}
+
+func TestWriter_BatchSize(t *testing.T) {
+	ctx := context.Background()
+	rt := newRouteTable(t)
+
+	w, err := New(rt, WithDelay(time.Hour), WithBatchSize(2))
+	require.NoError(t, err)
+	defer w.Close(ctx)
+
+	w.Store(ctx, "red", 1, "a:1")
+	f := w.Store(ctx, "red", 2, "a:1")
+
+	waitCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	require.NoError(t, f.Wait(waitCtx), "flushed before the delay")
+}
+
+func TestNew_InvalidOptions(t *testing.T) {
+	rt := newRouteTable(t)
+
+	for name, opt := range map[string]Option{
+		"delay":       WithDelay(0),
+		"batch size":  WithBatchSize(0),
+		"max pending": WithMaxPending(-1),
+		"timeout":     WithTimeout(0),
+	} {
+		_, err := New(rt, opt)
+		assert.Error(t, err, name)
+	}
+}
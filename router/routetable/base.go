@@ -3,15 +3,26 @@ package routetable
 import (
 	"context"
 	"time"
+
+	"github.com/pkg/errors"
+	verrors "github.com/vulcan-frame/vulcan-pkg-app/errors"
 )
 
 const (
 	defaultTTL = time.Hour * 24 * 7
 )
 
-var _ RouteTable = (*BaseRouteTable)(nil)
-
-type getKeyFunc func(name, color string, oid int64) string
+var (
+	_ ExtendedRouteTable = (*BaseRouteTable)(nil)
+	_ ObjectRouteTable   = (*BaseRouteTable)(nil)
+	_ Ranger             = (*BaseRouteTable)(nil)
+	_ TTLReader          = (*BaseRouteTable)(nil)
+	_ Exporter           = (*BaseRouteTable)(nil)
+	_ Importer           = (*BaseRouteTable)(nil)
+	_ SIDMerger          = (*BaseRouteTable)(nil)
+	_ KeyMigrator        = (*BaseRouteTable)(nil)
+	_ ScopeMigrator      = (*BaseRouteTable)(nil)
+)
 
 type Option func(*BaseRouteTable)
 
@@ -21,19 +32,29 @@ func WithTTL(dur time.Duration) Option {
 	}
 }
 
+// WithKeyFormat sets the format of the keys, the default is DefaultKeyFormat
+func WithKeyFormat(format KeyFormat) Option {
+	return func(r *BaseRouteTable) {
+		r.keyFormat = format
+	}
+}
+
 type BaseRouteTable struct {
 	RouteTableData
 
-	name   string
-	getKey getKeyFunc
-	ttl    time.Duration
+	name      string
+	keyFormat KeyFormat
+	ttl       time.Duration
+	sidScope  bool          // the keys are scoped by the SID of the ctx
+	zone      func() uint32 // the keys are scoped by the zone if it's not nil
 }
 
-func NewBaseRouteTable(rtd RouteTableData, name string, getKey getKeyFunc, opts ...Option) *BaseRouteTable {
+// New creates the BaseRouteTable, NewRouteTable returns the same one as the RouteTable interface
+func New(rtd RouteTableData, name string, opts ...Option) *BaseRouteTable {
 	rt := &BaseRouteTable{
 		RouteTableData: rtd,
 		name:           name,
-		getKey:         getKey,
+		keyFormat:      DefaultKeyFormat,
 		ttl:            defaultTTL,
 	}
 	for _, opt := range opts {
@@ -42,34 +63,324 @@ func NewBaseRouteTable(rtd RouteTableData, name string, getKey getKeyFunc, opts
 	return rt
 }
 
+// NewBaseRouteTable creates the BaseRouteTable with the key function of the int64 oids.
+//
+// Deprecated: use New with WithKeyFormat, the getKey is adapted by KeyFunc.
+func NewBaseRouteTable(rtd RouteTableData, name string, getKey func(name, color string, oid int64) string, opts ...Option) *BaseRouteTable {
+	return New(rtd, name, append([]Option{WithKeyFormat(KeyFunc(getKey))}, opts...)...)
+}
+
 func (r *BaseRouteTable) Store(ctx context.Context, color string, uid int64, addr string) error {
-	return r.RouteTableData.Set(ctx, r.getKey(r.name, color, uid), addr, r.ttl)
+	return r.StoreObject(ctx, color, formatOID(uid), addr)
 }
 
 func (r *BaseRouteTable) GetSet(ctx context.Context, color string, uid int64, addr string) (old string, err error) {
-	return r.RouteTableData.GetSet(ctx, r.getKey(r.name, color, uid), addr, r.ttl)
+	old, err = r.RouteTableData.GetSet(ctx, r.intKey(ctx, color, uid), addr, r.ttl)
+	return AddrOf(old), err
 }
 
 func (r *BaseRouteTable) SetNx(ctx context.Context, color string, uid int64, addr string) (ok bool, result string, err error) {
-	return r.RouteTableData.SetNx(ctx, r.getKey(r.name, color, uid), addr, r.ttl)
+	ok, result, err = r.RouteTableData.SetNx(ctx, r.intKey(ctx, color, uid), addr, r.ttl)
+	return ok, AddrOf(result), err
 }
 
 func (r *BaseRouteTable) Load(ctx context.Context, color string, uid int64) (addr string, err error) {
-	return r.RouteTableData.Load(ctx, r.getKey(r.name, color, uid))
+	return r.LoadObject(ctx, color, formatOID(uid))
 }
 
 func (r *BaseRouteTable) LoadAndExpire(ctx context.Context, color string, uid int64) (addr string, err error) {
-	return r.RouteTableData.LoadAndExpire(ctx, r.getKey(r.name, color, uid), r.ttl)
+	return r.LoadAndExpireObject(ctx, color, formatOID(uid))
 }
 
 func (r *BaseRouteTable) Del(ctx context.Context, color string, uid int64) error {
-	return r.RouteTableData.Del(ctx, r.getKey(r.name, color, uid))
+	return r.DelObject(ctx, color, formatOID(uid))
 }
 
 func (r *BaseRouteTable) DelDelay(ctx context.Context, color string, uid int64, expiration time.Duration) error {
-	return r.RouteTableData.Expire(ctx, r.getKey(r.name, color, uid), expiration)
+	return r.DelDelayObject(ctx, color, formatOID(uid), expiration)
 }
 
 func (r *BaseRouteTable) DelIfSame(ctx context.Context, color string, uid int64, value string) error {
-	return r.RouteTableData.DelIfSame(ctx, r.getKey(r.name, color, uid), value)
+	return r.DelIfSameObject(ctx, color, formatOID(uid), value)
+}
+
+func (r *BaseRouteTable) LoadGen(ctx context.Context, color string, uid int64) (addr string, gen int64, err error) {
+	return r.LoadGenObject(ctx, color, formatOID(uid))
+}
+
+func (r *BaseRouteTable) GetSetGen(ctx context.Context, color string, uid int64, addr string) (old string, gen int64, err error) {
+	return r.GetSetGenObject(ctx, color, formatOID(uid), addr)
+}
+
+func (r *BaseRouteTable) SetNxGen(ctx context.Context, color string, uid int64, addr string) (ok bool, result string, gen int64, err error) {
+	ok, result, gen, err = SetNxGen(ctx, r.RouteTableData, r.intKey(ctx, color, uid), addr, r.ttl)
+	return ok, AddrOf(result), gen, err
+}
+
+func (r *BaseRouteTable) CompareAndSwap(ctx context.Context, color string, uid int64, expectedGen int64, addr string) (ok bool, gen int64, err error) {
+	return r.CompareAndSwapObject(ctx, color, formatOID(uid), expectedGen, addr)
+}
+
+func (r *BaseRouteTable) LoadMany(ctx context.Context, color string, uids []int64) (addrs []string, errs []error) {
+	addrs, errs = LoadMany(ctx, r.RouteTableData, r.getKeys(ctx, color, uids))
+	for i, addr := range addrs {
+		addrs[i] = AddrOf(addr)
+	}
+	return addrs, errs
+}
+
+func (r *BaseRouteTable) LoadEntry(ctx context.Context, color string, uid int64) (RouteEntry, error) {
+	return r.LoadEntryObject(ctx, color, formatOID(uid))
+}
+
+func (r *BaseRouteTable) StoreEntry(ctx context.Context, color string, uid int64, entry RouteEntry) error {
+	return r.StoreEntryObject(ctx, color, formatOID(uid), entry)
+}
+
+func (r *BaseRouteTable) GetSetEntry(ctx context.Context, color string, uid int64, entry RouteEntry) (RouteEntry, error) {
+	return r.GetSetEntryObject(ctx, color, formatOID(uid), entry)
+}
+
+func (r *BaseRouteTable) SetNxEntry(ctx context.Context, color string, uid int64, entry RouteEntry) (bool, RouteEntry, error) {
+	return r.SetNxEntryObject(ctx, color, formatOID(uid), entry)
+}
+
+func (r *BaseRouteTable) LoadObject(ctx context.Context, color string, oid string) (addr string, err error) {
+	addr, err = r.RouteTableData.Load(ctx, r.key(ctx, color, oid))
+	return AddrOf(addr), err
+}
+
+func (r *BaseRouteTable) LoadAndExpireObject(ctx context.Context, color string, oid string) (addr string, err error) {
+	addr, err = r.RouteTableData.LoadAndExpire(ctx, r.key(ctx, color, oid), r.ttl)
+	return AddrOf(addr), err
+}
+
+func (r *BaseRouteTable) LoadGenObject(ctx context.Context, color string, oid string) (addr string, gen int64, err error) {
+	addr, gen, err = LoadGen(ctx, r.RouteTableData, r.key(ctx, color, oid))
+	return AddrOf(addr), gen, err
+}
+
+func (r *BaseRouteTable) GetSetGenObject(ctx context.Context, color string, oid string, addr string) (old string, gen int64, err error) {
+	old, gen, err = GetSetGen(ctx, r.RouteTableData, r.key(ctx, color, oid), addr, r.ttl)
+	return AddrOf(old), gen, err
+}
+
+func (r *BaseRouteTable) LoadEntryObject(ctx context.Context, color string, oid string) (RouteEntry, error) {
+	value, err := r.RouteTableData.Load(ctx, r.key(ctx, color, oid))
+	if err != nil {
+		return RouteEntry{}, err
+	}
+	return DecodeEntry(value), nil
+}
+
+func (r *BaseRouteTable) StoreObject(ctx context.Context, color string, oid string, addr string) error {
+	return r.RouteTableData.Set(ctx, r.key(ctx, color, oid), addr, r.ttl)
+}
+
+func (r *BaseRouteTable) StoreEntryObject(ctx context.Context, color string, oid string, entry RouteEntry) error {
+	return r.RouteTableData.Set(ctx, r.key(ctx, color, oid), entry.Encode(), r.ttl)
+}
+
+func (r *BaseRouteTable) GetSetEntryObject(ctx context.Context, color string, oid string, entry RouteEntry) (RouteEntry, error) {
+	old, err := r.RouteTableData.GetSet(ctx, r.key(ctx, color, oid), entry.Encode(), r.ttl)
+	return DecodeEntry(old), err
+}
+
+func (r *BaseRouteTable) SetNxEntryObject(ctx context.Context, color string, oid string, entry RouteEntry) (bool, RouteEntry, error) {
+	ok, result, err := r.RouteTableData.SetNx(ctx, r.key(ctx, color, oid), entry.Encode(), r.ttl)
+	return ok, DecodeEntry(result), err
+}
+
+func (r *BaseRouteTable) CompareAndSwapObject(ctx context.Context, color string, oid string, expectedGen int64, addr string) (ok bool, gen int64, err error) {
+	return CompareAndSwap(ctx, r.RouteTableData, r.key(ctx, color, oid), expectedGen, addr, r.ttl)
+}
+
+func (r *BaseRouteTable) DelDelayObject(ctx context.Context, color string, oid string, expiration time.Duration) error {
+	return r.RouteTableData.Expire(ctx, r.key(ctx, color, oid), expiration)
+}
+
+func (r *BaseRouteTable) DelIfSameObject(ctx context.Context, color string, oid string, value string) error {
+	return r.RouteTableData.DelIfSame(ctx, r.key(ctx, color, oid), value)
+}
+
+func (r *BaseRouteTable) DelObject(ctx context.Context, color string, oid string) error {
+	return r.RouteTableData.Del(ctx, r.key(ctx, color, oid))
+}
+
+func (r *BaseRouteTable) StoreMany(ctx context.Context, color string, uids []int64, addrs []string) (errs []error) {
+	if len(uids) != len(addrs) {
+		return repeatErr(errors.Errorf("the count of keys and addrs not match. keys=%d addrs=%d", len(uids), len(addrs)), len(uids))
+	}
+	return SetMany(ctx, r.RouteTableData, r.getKeys(ctx, color, uids), addrs, r.ttl)
+}
+
+func (r *BaseRouteTable) DelMany(ctx context.Context, color string, uids []int64) (errs []error) {
+	return DelMany(ctx, r.RouteTableData, r.getKeys(ctx, color, uids))
+}
+
+func (r *BaseRouteTable) StoreManyObjects(ctx context.Context, color string, oids []string, addrs []string) (errs []error) {
+	if len(oids) != len(addrs) {
+		return repeatErr(errors.Errorf("the count of keys and addrs not match. keys=%d addrs=%d", len(oids), len(addrs)), len(oids))
+	}
+	return SetMany(ctx, r.RouteTableData, r.objectKeys(ctx, color, oids), addrs, r.ttl)
+}
+
+func (r *BaseRouteTable) DelManyObjects(ctx context.Context, color string, oids []string) (errs []error) {
+	return DelMany(ctx, r.RouteTableData, r.objectKeys(ctx, color, oids))
+}
+
+func (r *BaseRouteTable) Renew(ctx context.Context, color string, uids []int64, addr string) (errs []error) {
+	return RenewMany(ctx, r.RouteTableData, r.getKeys(ctx, color, uids), addr, r.ttl)
+}
+
+func (r *BaseRouteTable) RenewObjects(ctx context.Context, color string, oids []string, addr string) (errs []error) {
+	return RenewMany(ctx, r.RouteTableData, r.objectKeys(ctx, color, oids), addr, r.ttl)
+}
+
+// TTL returns the remaining ttl of the route, the backend must implement TTLRouteTableData
+func (r *BaseRouteTable) TTL(ctx context.Context, color string, uid int64) (time.Duration, error) {
+	return r.TTLObject(ctx, color, formatOID(uid))
+}
+
+// TTLObject is TTL of the string oid
+func (r *BaseRouteTable) TTLObject(ctx context.Context, color string, oid string) (time.Duration, error) {
+	rtd, ok := asData[TTLRouteTableData](r.RouteTableData, true)
+	if !ok {
+		return 0, errors.Errorf("the route table backend does not support reading the ttl")
+	}
+	return rtd.TTL(ctx, r.key(ctx, color, oid))
+}
+
+// Range calls fn with the routes of the color in batches, the routes changed during the range may be missed or repeated.
+// The range stops when fn returns an error. The oids are the object strings, the int64 oids are in decimal.
+func (r *BaseRouteTable) Range(ctx context.Context, color string, fn func(oids []string, entries []RouteEntry) error) error {
+	prefix := r.keyFormat.Prefix(r.scopedName(ctx), color)
+	return Scan(ctx, r.RouteTableData, prefix, func(keys []string) error {
+		values, errs := LoadMany(ctx, r.RouteTableData, keys)
+		oids := make([]string, 0, len(keys))
+		entries := make([]RouteEntry, 0, len(keys))
+		for i, key := range keys {
+			if errs[i] != nil {
+				if errors.Is(errs[i], verrors.ErrRouteTableNotFound) {
+					continue
+				}
+				return errs[i]
+			}
+			oid, ok := r.keyFormat.Parse(prefix, key)
+			if !ok {
+				continue
+			}
+			oids = append(oids, oid)
+			entries = append(entries, DecodeEntry(values[i]))
+		}
+		if len(oids) == 0 {
+			return nil
+		}
+		return fn(oids, entries)
+	})
+}
+
+func (r *BaseRouteTable) key(ctx context.Context, color string, oid string) string {
+	return r.keyFormat.Key(r.scopedName(ctx), color, oid)
+}
+
+func (r *BaseRouteTable) intKey(ctx context.Context, color string, uid int64) string {
+	return r.keyFormat.Key(r.scopedName(ctx), color, formatOID(uid))
+}
+
+func (r *BaseRouteTable) parseIntKey(prefix, key string) (int64, bool) {
+	oid, ok := r.keyFormat.Parse(prefix, key)
+	if !ok {
+		return 0, false
+	}
+	return parseOID(oid)
+}
+
+func (r *BaseRouteTable) getKeys(ctx context.Context, color string, uids []int64) []string {
+	keys := make([]string, len(uids))
+	for i, uid := range uids {
+		keys[i] = r.intKey(ctx, color, uid)
+	}
+	return keys
+}
+
+func (r *BaseRouteTable) objectKeys(ctx context.Context, color string, oids []string) []string {
+	keys := make([]string, len(oids))
+	for i, oid := range oids {
+		keys[i] = r.key(ctx, color, oid)
+	}
+	return keys
+}
+
+func repeatErr(err error, n int) []error {
+	errs := make([]error, n)
+	for i := range errs {
+		errs[i] = err
+	}
+	return errs
+}
+
+func (r *BaseRouteTable) Watch(ctx context.Context, color string) (<-chan Event, error) {
+	prefix := r.keyFormat.Prefix(r.scopedName(ctx), color)
+	dataCh, err := Watch(ctx, r.RouteTableData, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan Event, cap(dataCh))
+	go func() {
+		defer close(ch)
+		for ev := range dataCh {
+			if ev.Kind == EventError {
+				select {
+				case ch <- Event{Kind: EventError, Err: ev.Err}:
+				case <-ctx.Done():
+				}
+				return
+			}
+			object, ok := r.keyFormat.Parse(prefix, ev.Key)
+			if !ok {
+				continue
+			}
+			oid, _ := parseOID(object)
+			select {
+			case ch <- Event{OID: oid, Object: object, Kind: ev.Kind, OldAddr: AddrOf(ev.Old), NewAddr: AddrOf(ev.New)}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func (r *BaseRouteTable) ListByAddr(ctx context.Context, color string, addr string, cursor string, count int64) ([]int64, string, error) {
+	prefix := r.keyFormat.Prefix(r.scopedName(ctx), color)
+	keys, next, err := ListByAddr(ctx, r.RouteTableData, addr, prefix, cursor, count)
+	if err != nil {
+		return nil, "", err
+	}
+
+	oids := make([]int64, 0, len(keys))
+	for _, key := range keys {
+		if oid, ok := r.parseIntKey(prefix, key); ok {
+			oids = append(oids, oid)
+		}
+	}
+	return oids, next, nil
+}
+
+func (r *BaseRouteTable) ListObjectsByAddr(ctx context.Context, color string, addr string, cursor string, count int64) ([]string, string, error) {
+	prefix := r.keyFormat.Prefix(r.scopedName(ctx), color)
+	keys, next, err := ListByAddr(ctx, r.RouteTableData, addr, prefix, cursor, count)
+	if err != nil {
+		return nil, "", err
+	}
+
+	oids := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if oid, ok := r.keyFormat.Parse(prefix, key); ok {
+			oids = append(oids, oid)
+		}
+	}
+	return oids, next, nil
 }
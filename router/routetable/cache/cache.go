@@ -0,0 +1,526 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/pkg/errors"
+	verrors "github.com/vulcan-frame/vulcan-pkg-app/errors"
+	"github.com/vulcan-frame/vulcan-pkg-app/router"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+)
+
+const (
+	defaultSize            = 100000
+	defaultTTL             = router.HolderCacheTimeout
+	defaultRefreshInterval = time.Second * 10
+	// maxPublishKeys is the max count of the keys in an invalidation message
+	maxPublishKeys = 256
+)
+
+// Invalidation is the message of the keys changed by the node
+type Invalidation struct {
+	Node string   `json:"n"`
+	Keys []string `json:"k"`
+}
+
+// Broadcaster broadcasts the changed keys to the route table caches of all the nodes, including the publisher.
+// The channel returned by Subscribe is closed when the ctx is done.
+type Broadcaster interface {
+	Publish(ctx context.Context, msg Invalidation) error
+	Subscribe(ctx context.Context) (<-chan Invalidation, error)
+}
+
+var (
+	_ routetable.RouteTableData      = (*RouteTable)(nil)
+	_ routetable.GenRouteTableData   = (*RouteTable)(nil)
+	_ routetable.BatchRouteTableData = (*RouteTable)(nil)
+)
+
+type Option func(*RouteTable)
+
+// WithSize sets the max count of the cached keys
+func WithSize(size int) Option {
+	return func(r *RouteTable) {
+		r.size = size
+	}
+}
+
+// WithTTL sets how long a key can be served from the local cache
+func WithTTL(dur time.Duration) Option {
+	return func(r *RouteTable) {
+		r.ttl = dur
+	}
+}
+
+// WithRefreshInterval sets the interval of flushing the batched expiration refresh to the backend
+func WithRefreshInterval(dur time.Duration) Option {
+	return func(r *RouteTable) {
+		r.refreshInterval = dur
+	}
+}
+
+// WithBroadcaster enables the invalidation across nodes
+func WithBroadcaster(b Broadcaster) Option {
+	return func(r *RouteTable) {
+		r.broadcaster = b
+	}
+}
+
+// WithNodeID sets the id of the node in the invalidation messages, the node skips the messages of its own id.
+// The default is a random id, it must be unique among the nodes sharing the broadcaster.
+func WithNodeID(id string) Option {
+	return func(r *RouteTable) {
+		r.node = id
+	}
+}
+
+// RouteTable is a RouteTableData decorator which keeps a bounded local lru of key->addr in front of the backend.
+// The cached keys are invalidated across nodes by the Broadcaster when they are changed. The invalidations are
+// published in the background in batches, so the other nodes may serve the old value until the batch arrives.
+// The expiration refreshes of LoadAndExpire are batched and flushed in the background.
+type RouteTable struct {
+	routetable.RouteTableData
+
+	size            int
+	ttl             time.Duration
+	refreshInterval time.Duration
+	broadcaster     Broadcaster
+	node            string
+
+	mu      sync.Mutex
+	lru     *lru
+	epoch   uint64 // increased on every change, the loaded value is not cached if the epoch has changed
+	pending map[string]time.Duration
+	outbox  map[string]struct{} // the changed keys to publish
+	notify  chan struct{}
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewRouteTable(rtd routetable.RouteTableData, opts ...Option) (*RouteTable, error) {
+	rt := &RouteTable{
+		RouteTableData:  rtd,
+		size:            defaultSize,
+		ttl:             defaultTTL,
+		refreshInterval: defaultRefreshInterval,
+		pending:         make(map[string]time.Duration),
+		outbox:          make(map[string]struct{}),
+		notify:          make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(rt)
+	}
+	rt.lru = newLRU(rt.size)
+	if rt.node == "" {
+		rt.node = newNodeID()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rt.cancel = cancel
+
+	if rt.broadcaster != nil {
+		ch, err := rt.broadcaster.Subscribe(ctx)
+		if err != nil {
+			cancel()
+			return nil, errors.Wrapf(err, "route table cache subscribe failed")
+		}
+		rt.wg.Add(2)
+		go rt.invalidateLoop(ch)
+		go rt.publishLoop(ctx)
+	}
+
+	rt.wg.Add(1)
+	go rt.refreshLoop(ctx)
+	return rt, nil
+}
+
+// Close stops the background goroutines and flushes the pending expiration refreshes and invalidations
+func (rt *RouteTable) Close() {
+	rt.cancel()
+	rt.wg.Wait()
+	rt.flush(context.Background())
+	rt.flushPublish(context.Background())
+}
+
+func (rt *RouteTable) Load(ctx context.Context, key string) (string, error) {
+	if addr, ok := rt.get(key); ok {
+		return addr, nil
+	}
+
+	epoch := rt.currentEpoch()
+	addr, err := rt.RouteTableData.Load(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	rt.fill(key, addr, epoch)
+	return addr, nil
+}
+
+// LoadAndExpire serves the value from the local cache when possible,
+// the expiration is refreshed in the next batch instead of on every call.
+// The call with dur == 0 always goes to the backend to persist the key.
+func (rt *RouteTable) LoadAndExpire(ctx context.Context, key string, dur time.Duration) (string, error) {
+	if dur != 0 {
+		if addr, ok := rt.get(key); ok {
+			if dur > 0 {
+				rt.mu.Lock()
+				rt.pending[key] = dur
+				rt.mu.Unlock()
+			}
+			return addr, nil
+		}
+	}
+
+	epoch := rt.currentEpoch()
+	addr, err := rt.RouteTableData.LoadAndExpire(ctx, key, dur)
+	if err != nil {
+		return "", err
+	}
+	rt.fill(key, addr, epoch)
+	return addr, nil
+}
+
+func (rt *RouteTable) Set(ctx context.Context, key string, addr string, dur time.Duration) error {
+	if err := rt.RouteTableData.Set(ctx, key, addr, dur); err != nil {
+		rt.invalidate(key)
+		return err
+	}
+	rt.update(key, addr)
+	rt.publish(key)
+	return nil
+}
+
+func (rt *RouteTable) GetSet(ctx context.Context, key string, addr string, dur time.Duration) (string, error) {
+	old, err := rt.RouteTableData.GetSet(ctx, key, addr, dur)
+	if err != nil && !errors.Is(err, verrors.ErrRouteTableNotFound) {
+		rt.invalidate(key)
+		return "", err
+	}
+	// the value is stored even if the old one is not found
+	rt.update(key, addr)
+	rt.publish(key)
+	return old, err
+}
+
+func (rt *RouteTable) SetNx(ctx context.Context, key string, addr string, dur time.Duration) (bool, string, error) {
+	epoch := rt.currentEpoch()
+	ok, result, err := rt.RouteTableData.SetNx(ctx, key, addr, dur)
+	if err != nil {
+		return false, "", err
+	}
+	// the key was not existed before, so it can not be cached by other nodes
+	rt.fill(key, result, epoch)
+	return ok, result, nil
+}
+
+func (rt *RouteTable) LoadGen(ctx context.Context, key string) (string, int64, error) {
+	return routetable.LoadGen(ctx, rt.RouteTableData, key)
+}
+
+func (rt *RouteTable) GetSetGen(ctx context.Context, key string, addr string, dur time.Duration) (string, int64, error) {
+	old, gen, err := routetable.GetSetGen(ctx, rt.RouteTableData, key, addr, dur)
+	if err != nil && !errors.Is(err, verrors.ErrRouteTableNotFound) {
+		rt.invalidate(key)
+		return "", 0, err
+	}
+	rt.update(key, addr)
+	rt.publish(key)
+	return old, gen, err
+}
+
+func (rt *RouteTable) SetNxGen(ctx context.Context, key string, addr string, dur time.Duration) (bool, string, int64, error) {
+	epoch := rt.currentEpoch()
+	ok, result, gen, err := routetable.SetNxGen(ctx, rt.RouteTableData, key, addr, dur)
+	if err != nil {
+		return false, "", 0, err
+	}
+	rt.fill(key, result, epoch)
+	return ok, result, gen, nil
+}
+
+func (rt *RouteTable) CompareAndSwap(ctx context.Context, key string, expectedGen int64, addr string, dur time.Duration) (bool, int64, error) {
+	ok, gen, err := routetable.CompareAndSwap(ctx, rt.RouteTableData, key, expectedGen, addr, dur)
+	if err != nil || !ok {
+		rt.invalidate(key)
+		return ok, gen, err
+	}
+	rt.update(key, addr)
+	rt.publish(key)
+	return true, gen, nil
+}
+
+// Expire invalidates the key on all the nodes, so the delayed delete is not undone by the refresh of the cached key
+func (rt *RouteTable) Expire(ctx context.Context, key string, expiration time.Duration) error {
+	err := rt.RouteTableData.Expire(ctx, key, expiration)
+	rt.invalidate(key)
+	if err != nil {
+		return err
+	}
+	rt.publish(key)
+	return nil
+}
+
+func (rt *RouteTable) DelIfSame(ctx context.Context, key string, value string) error {
+	err := rt.RouteTableData.DelIfSame(ctx, key, value)
+	rt.invalidate(key)
+	if err != nil {
+		return err
+	}
+	rt.publish(key)
+	return nil
+}
+
+func (rt *RouteTable) Del(ctx context.Context, key string) error {
+	err := rt.RouteTableData.Del(ctx, key)
+	rt.invalidate(key)
+	if err != nil {
+		return err
+	}
+	rt.publish(key)
+	return nil
+}
+
+func (rt *RouteTable) LoadMany(ctx context.Context, keys []string) ([]string, []error) {
+	addrs := make([]string, len(keys))
+	errs := make([]error, len(keys))
+
+	missed := make([]int, 0, len(keys))
+	for i, key := range keys {
+		if addr, ok := rt.get(key); ok {
+			addrs[i] = addr
+			continue
+		}
+		missed = append(missed, i)
+	}
+	if len(missed) == 0 {
+		return addrs, errs
+	}
+
+	missedKeys := make([]string, len(missed))
+	for i, idx := range missed {
+		missedKeys[i] = keys[idx]
+	}
+
+	epoch := rt.currentEpoch()
+	loaded, loadErrs := routetable.LoadMany(ctx, rt.RouteTableData, missedKeys)
+	for i, idx := range missed {
+		if loadErrs[i] != nil {
+			errs[idx] = loadErrs[i]
+			continue
+		}
+		addrs[idx] = loaded[i]
+		rt.fill(keys[idx], loaded[i], epoch)
+	}
+	return addrs, errs
+}
+
+func (rt *RouteTable) SetMany(ctx context.Context, keys []string, addrs []string, dur time.Duration) []error {
+	errs := routetable.SetMany(ctx, rt.RouteTableData, keys, addrs, dur)
+	for i, key := range keys {
+		if errs[i] != nil {
+			rt.invalidate(key)
+			continue
+		}
+		rt.update(key, addrs[i])
+		rt.publish(key)
+	}
+	return errs
+}
+
+func (rt *RouteTable) DelMany(ctx context.Context, keys []string) []error {
+	errs := routetable.DelMany(ctx, rt.RouteTableData, keys)
+	for i, key := range keys {
+		rt.invalidate(key)
+		if errs[i] == nil {
+			rt.publish(key)
+		}
+	}
+	return errs
+}
+
+// RenewMany only resets the expiration, so the cached values are kept
+func (rt *RouteTable) RenewMany(ctx context.Context, keys []string, addr string, dur time.Duration) []error {
+	return routetable.RenewMany(ctx, rt.RouteTableData, keys, addr, dur)
+}
+
+func (rt *RouteTable) get(key string) (string, bool) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	return rt.lru.get(key, time.Now())
+}
+
+func (rt *RouteTable) currentEpoch() uint64 {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	return rt.epoch
+}
+
+// fill caches the loaded value if nothing has changed since the load started
+func (rt *RouteTable) fill(key, addr string, epoch uint64) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	if rt.epoch != epoch {
+		return
+	}
+	rt.lru.add(key, addr, time.Now().Add(rt.ttl))
+}
+
+func (rt *RouteTable) update(key, addr string) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	rt.epoch++
+	rt.lru.add(key, addr, time.Now().Add(rt.ttl))
+}
+
+func (rt *RouteTable) invalidate(key string) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	rt.epoch++
+	rt.lru.remove(key)
+	delete(rt.pending, key)
+}
+
+// publish queues the key for the next invalidation message, the write doesn't wait for the broadcaster
+func (rt *RouteTable) publish(key string) {
+	if rt.broadcaster == nil {
+		return
+	}
+
+	rt.mu.Lock()
+	rt.outbox[key] = struct{}{}
+	rt.mu.Unlock()
+
+	select {
+	case rt.notify <- struct{}{}:
+	default:
+	}
+}
+
+// publishLoop publishes the queued keys, the keys changed during a publish are sent together in the next one
+func (rt *RouteTable) publishLoop(ctx context.Context) {
+	defer rt.wg.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-rt.notify:
+			rt.flushPublish(ctx)
+		}
+	}
+}
+
+func (rt *RouteTable) flushPublish(ctx context.Context) {
+	if rt.broadcaster == nil {
+		return
+	}
+
+	rt.mu.Lock()
+	if len(rt.outbox) == 0 {
+		rt.mu.Unlock()
+		return
+	}
+	outbox := rt.outbox
+	rt.outbox = make(map[string]struct{}, len(outbox))
+	rt.mu.Unlock()
+
+	keys := make([]string, 0, len(outbox))
+	for key := range outbox {
+		keys = append(keys, key)
+	}
+	for start := 0; start < len(keys); start += maxPublishKeys {
+		batch := keys[start:min(start+maxPublishKeys, len(keys))]
+		if err := rt.broadcaster.Publish(ctx, Invalidation{Node: rt.node, Keys: batch}); err != nil {
+			log.Errorf("route table cache publish invalidation failed. keys=%v err=%+v", batch, err)
+		}
+	}
+}
+
+// invalidateLoop invalidates the keys changed by the other nodes, the changes of this node are already applied
+func (rt *RouteTable) invalidateLoop(ch <-chan Invalidation) {
+	defer rt.wg.Done()
+
+	for msg := range ch {
+		if msg.Node == rt.node {
+			continue
+		}
+		for _, key := range msg.Keys {
+			rt.invalidate(key)
+		}
+	}
+}
+
+func (rt *RouteTable) refreshLoop(ctx context.Context) {
+	defer rt.wg.Done()
+
+	ticker := time.NewTicker(rt.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			rt.flush(ctx)
+		}
+	}
+}
+
+// flush refreshes the expiration of the keys loaded from the cache since the last flush,
+// the keys are expired in batches if the backend implements routetable.ExpireManyRouteTableData
+func (rt *RouteTable) flush(ctx context.Context) {
+	rt.mu.Lock()
+	if len(rt.pending) == 0 {
+		rt.mu.Unlock()
+		return
+	}
+	pending := rt.pending
+	rt.pending = make(map[string]time.Duration, len(pending))
+	rt.mu.Unlock()
+
+	batches := make(map[time.Duration][]string)
+	for key, dur := range pending {
+		batches[dur] = append(batches[dur], key)
+	}
+	for dur, keys := range batches {
+		for i, err := range rt.expireMany(ctx, keys, dur) {
+			if err != nil {
+				log.Errorf("route table cache refresh expiration failed. key=%s err=%+v", keys[i], err)
+			}
+		}
+	}
+}
+
+func (rt *RouteTable) expireMany(ctx context.Context, keys []string, dur time.Duration) []error {
+	if em, ok := rt.RouteTableData.(routetable.ExpireManyRouteTableData); ok {
+		return em.ExpireMany(ctx, keys, dur)
+	}
+
+	errs := make([]error, len(keys))
+	for i, key := range keys {
+		errs[i] = rt.RouteTableData.Expire(ctx, key, dur)
+	}
+	return errs
+}
+
+// Unwrap returns the backend, the marks of the nodes are not cached
+func (rt *RouteTable) Unwrap() routetable.RouteTableData {
+	return rt.RouteTableData
+}
+
+func newNodeID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
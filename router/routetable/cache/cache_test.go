@@ -0,0 +1,136 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable/memory"
+)
+
+type chanBroadcaster struct {
+	ch chan Invalidation
+
+	mu        sync.Mutex
+	published []string
+}
+
+func (b *chanBroadcaster) Publish(ctx context.Context, msg Invalidation) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.published = append(b.published, msg.Keys...)
+	return nil
+}
+
+func (b *chanBroadcaster) Subscribe(ctx context.Context) (<-chan Invalidation, error) {
+	out := make(chan Invalidation)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg := <-b.ch:
+				out <- msg
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (b *chanBroadcaster) keys() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]string(nil), b.published...)
+}
+
+func TestRouteTable_Invalidate(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.NewRouteTable()
+	defer backend.Close()
+
+	b := &chanBroadcaster{ch: make(chan Invalidation)}
+	rt, err := NewRouteTable(backend, WithBroadcaster(b), WithTTL(time.Minute))
+	require.NoError(t, err)
+	defer rt.Close()
+
+	require.NoError(t, rt.Set(ctx, "k", "a", time.Hour))
+
+	// another node changes the backend directly
+	require.NoError(t, backend.Set(ctx, "k", "b", time.Hour))
+	addr, err := rt.Load(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, "a", addr)
+
+	// the own invalidation is skipped, the value written by this node is kept
+	b.ch <- Invalidation{Node: rt.node, Keys: []string{"k"}}
+	// the channels are unbuffered, so the own one is handled when the second of these is sent
+	b.ch <- Invalidation{Node: "other", Keys: []string{"x"}}
+	b.ch <- Invalidation{Node: "other", Keys: []string{"x"}}
+	addr, err = rt.Load(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, "a", addr)
+
+	b.ch <- Invalidation{Node: "other", Keys: []string{"k"}}
+	assert.Eventually(t, func() bool {
+		addr, err := rt.Load(ctx, "k")
+		return err == nil && addr == "b"
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestRouteTable_RefreshExpiration(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.NewRouteTable()
+	defer backend.Close()
+
+	rt, err := NewRouteTable(backend, WithRefreshInterval(10*time.Millisecond))
+	require.NoError(t, err)
+	defer rt.Close()
+
+	require.NoError(t, rt.Set(ctx, "k", "a", 50*time.Millisecond))
+	for i := 0; i < 10; i++ {
+		addr, err := rt.LoadAndExpire(ctx, "k", 50*time.Millisecond)
+		require.NoError(t, err)
+		assert.Equal(t, "a", addr)
+		time.Sleep(15 * time.Millisecond)
+	}
+
+	// the expiration is refreshed in batches, so the key is still alive in the backend
+	addr, err := backend.Load(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, "a", addr)
+}
+
+func TestRouteTable_DelDelay(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.NewRouteTable()
+	defer backend.Close()
+
+	b := &chanBroadcaster{ch: make(chan Invalidation)}
+	rt, err := NewRouteTable(backend, WithBroadcaster(b), WithRefreshInterval(10*time.Millisecond))
+	require.NoError(t, err)
+	defer rt.Close()
+
+	require.NoError(t, rt.Set(ctx, "k", "a", time.Hour))
+	_, err = rt.LoadAndExpire(ctx, "k", time.Hour)
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool { return len(b.keys()) == 1 }, time.Second, 5*time.Millisecond)
+	require.NoError(t, rt.Expire(ctx, "k", 50*time.Millisecond))
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual([]string{"k", "k"}, b.keys())
+	}, time.Second, 5*time.Millisecond)
+
+	// the key is not served from the cache, so the refresh doesn't undo the delayed delete
+	_, ok := rt.get("k")
+	assert.False(t, ok)
+	assert.Eventually(t, func() bool {
+		_, err := backend.Load(ctx, "k")
+		return err != nil
+	}, time.Second, 5*time.Millisecond)
+}
@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"container/list"
+	"time"
+)
+
+// lru is a bounded lru of key->addr, each entry expires after the ttl. It is not goroutine-safe.
+type lru struct {
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type lruEntry struct {
+	key      string
+	value    string
+	expireAt time.Time
+}
+
+func newLRU(size int) *lru {
+	return &lru{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[string]*list.Element, size),
+	}
+}
+
+func (c *lru) get(key string, now time.Time) (string, bool) {
+	e, ok := c.items[key]
+	if !ok {
+		return "", false
+	}
+	ent := e.Value.(*lruEntry)
+	if !now.Before(ent.expireAt) {
+		c.removeElement(e)
+		return "", false
+	}
+	c.ll.MoveToFront(e)
+	return ent.value, true
+}
+
+func (c *lru) add(key, value string, expireAt time.Time) {
+	if e, ok := c.items[key]; ok {
+		ent := e.Value.(*lruEntry)
+		ent.value = value
+		ent.expireAt = expireAt
+		c.ll.MoveToFront(e)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&lruEntry{key: key, value: value, expireAt: expireAt})
+	for c.ll.Len() > c.size {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+func (c *lru) remove(key string) {
+	if e, ok := c.items[key]; ok {
+		c.removeElement(e)
+	}
+}
+
+func (c *lru) len() int {
+	return c.ll.Len()
+}
+
+func (c *lru) removeElement(e *list.Element) {
+	c.ll.Remove(e)
+	delete(c.items, e.Value.(*lruEntry).key)
+}
@@ -0,0 +1,149 @@
+package routetable
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	verrors "github.com/vulcan-frame/vulcan-pkg-app/errors"
+)
+
+// The functions below call the optional interfaces of RouteTableData, the batches fall back to the single-key methods
+// and the others fail with ErrRouteTableUnsupported if the backend doesn't implement them.
+// The decorators of RouteTableData, such as the cache, expose the backend by Unwrap() RouteTableData,
+// which is followed by the reads. The decorators implement the optional interfaces of the writes they intercept.
+
+// asData returns the optional interface of the backend, the decorators are unwrapped if unwrap is true
+func asData[T any](d RouteTableData, unwrap bool) (T, bool) {
+	for {
+		if t, ok := d.(T); ok {
+			return t, true
+		}
+		u, ok := d.(interface{ Unwrap() RouteTableData })
+		if !unwrap || !ok {
+			var zero T
+			return zero, false
+		}
+		d = u.Unwrap()
+	}
+}
+
+func unsupported(operation string) error {
+	return errors.Wrapf(verrors.ErrRouteTableUnsupported, "the route table backend does not support %s", operation)
+}
+
+func LoadGen(ctx context.Context, d RouteTableData, key string) (string, int64, error) {
+	gd, ok := asData[GenRouteTableData](d, true)
+	if !ok {
+		return "", 0, unsupported("LoadGen")
+	}
+	return gd.LoadGen(ctx, key)
+}
+
+func GetSetGen(ctx context.Context, d RouteTableData, key string, addr string, dur time.Duration) (string, int64, error) {
+	gd, ok := asData[GenRouteTableData](d, false)
+	if !ok {
+		return "", 0, unsupported("GetSetGen")
+	}
+	return gd.GetSetGen(ctx, key, addr, dur)
+}
+
+func SetNxGen(ctx context.Context, d RouteTableData, key string, addr string, dur time.Duration) (bool, string, int64, error) {
+	gd, ok := asData[GenRouteTableData](d, false)
+	if !ok {
+		return false, "", 0, unsupported("SetNxGen")
+	}
+	return gd.SetNxGen(ctx, key, addr, dur)
+}
+
+func CompareAndSwap(ctx context.Context, d RouteTableData, key string, expectedGen int64, addr string, dur time.Duration) (bool, int64, error) {
+	gd, ok := asData[GenRouteTableData](d, false)
+	if !ok {
+		return false, 0, unsupported("CompareAndSwap")
+	}
+	return gd.CompareAndSwap(ctx, key, expectedGen, addr, dur)
+}
+
+func LoadMany(ctx context.Context, d RouteTableData, keys []string) ([]string, []error) {
+	if bd, ok := asData[BatchRouteTableData](d, true); ok {
+		return bd.LoadMany(ctx, keys)
+	}
+
+	addrs := make([]string, len(keys))
+	errs := make([]error, len(keys))
+	for i, key := range keys {
+		addrs[i], errs[i] = d.Load(ctx, key)
+	}
+	return addrs, errs
+}
+
+func SetMany(ctx context.Context, d RouteTableData, keys []string, addrs []string, dur time.Duration) []error {
+	if bd, ok := asData[BatchRouteTableData](d, false); ok {
+		return bd.SetMany(ctx, keys, addrs, dur)
+	}
+
+	errs := make([]error, len(keys))
+	for i, key := range keys {
+		errs[i] = d.Set(ctx, key, addrs[i], dur)
+	}
+	return errs
+}
+
+func DelMany(ctx context.Context, d RouteTableData, keys []string) []error {
+	if bd, ok := asData[BatchRouteTableData](d, false); ok {
+		return bd.DelMany(ctx, keys)
+	}
+
+	errs := make([]error, len(keys))
+	for i, key := range keys {
+		errs[i] = d.Del(ctx, key)
+	}
+	return errs
+}
+
+// RenewMany falls back to Load and Expire of each key, which is not atomic,
+// so the route changed between them gets the ttl of the renewal.
+func RenewMany(ctx context.Context, d RouteTableData, keys []string, addr string, dur time.Duration) []error {
+	if bd, ok := asData[BatchRouteTableData](d, false); ok {
+		return bd.RenewMany(ctx, keys, addr, dur)
+	}
+
+	errs := make([]error, len(keys))
+	for i, key := range keys {
+		value, err := d.Load(ctx, key)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		if !SameAddr(value, addr) {
+			errs[i] = errors.Wrapf(verrors.ErrRouteTableNotFound, "the route is owned by another addr. key=%s", key)
+			continue
+		}
+		errs[i] = d.Expire(ctx, key, dur)
+	}
+	return errs
+}
+
+func Watch(ctx context.Context, d RouteTableData, prefix string) (<-chan DataEvent, error) {
+	wd, ok := asData[WatchRouteTableData](d, true)
+	if !ok {
+		return nil, unsupported("Watch")
+	}
+	return wd.Watch(ctx, prefix)
+}
+
+func ListByAddr(ctx context.Context, d RouteTableData, addr string, prefix string, cursor string, count int64) ([]string, string, error) {
+	id, ok := asData[IndexRouteTableData](d, true)
+	if !ok {
+		return nil, "", unsupported("ListByAddr")
+	}
+	return id.ListByAddr(ctx, addr, prefix, cursor, count)
+}
+
+func Scan(ctx context.Context, d RouteTableData, prefix string, fn func(keys []string) error) error {
+	sd, ok := asData[ScanRouteTableData](d, true)
+	if !ok {
+		return unsupported("Scan")
+	}
+	return sd.Scan(ctx, prefix, fn)
+}
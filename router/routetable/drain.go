@@ -0,0 +1,67 @@
+package routetable
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	verrors "github.com/vulcan-frame/vulcan-pkg-app/errors"
+)
+
+// MarkRouteTableData is implemented by the backends which store the marks of the nodes apart from the routes,
+// so the marks are not indexed by the address, versioned, watched or scanned as the routes.
+// The marks are stored as the routes if the backend doesn't implement it.
+type MarkRouteTableData interface {
+	// SetMark sets the mark with the expiration
+	SetMark(ctx context.Context, key string, dur time.Duration) error
+	// DelMark removes the mark, it's not an error if the mark not exists
+	DelMark(ctx context.Context, key string) error
+	// LoadMarks returns whether the marks exist, the result is in the same order as the keys
+	LoadMarks(ctx context.Context, keys []string) (marked []bool, err error)
+}
+
+// drainKey is the mark of the draining node, the node will not be assigned new routes by the master balancer
+func drainKey(name, color, addr string) string {
+	return fmt.Sprintf("rd_%s_{%s}_%s", name, color, addr)
+}
+
+// SetDraining marks or unmarks the node as draining, the mark expires after the ttl of the route table
+func (r *BaseRouteTable) SetDraining(ctx context.Context, color string, addr string, draining bool) error {
+	key := drainKey(r.name, color, addr)
+	if m, ok := asData[MarkRouteTableData](r.RouteTableData, true); ok {
+		if !draining {
+			return m.DelMark(ctx, key)
+		}
+		return m.SetMark(ctx, key, r.ttl)
+	}
+
+	if !draining {
+		return r.RouteTableData.Del(ctx, key)
+	}
+	return r.RouteTableData.Set(ctx, key, addr, r.ttl)
+}
+
+// Draining returns whether the nodes are draining, the result is in the same order as the addrs
+func (r *BaseRouteTable) Draining(ctx context.Context, color string, addrs []string) ([]bool, error) {
+	keys := make([]string, len(addrs))
+	for i, addr := range addrs {
+		keys[i] = drainKey(r.name, color, addr)
+	}
+	if m, ok := asData[MarkRouteTableData](r.RouteTableData, true); ok {
+		return m.LoadMarks(ctx, keys)
+	}
+
+	draining := make([]bool, len(addrs))
+	_, errs := LoadMany(ctx, r.RouteTableData, keys)
+	for i, err := range errs {
+		if err == nil {
+			draining[i] = true
+			continue
+		}
+		if !errors.Is(err, verrors.ErrRouteTableNotFound) {
+			return nil, err
+		}
+	}
+	return draining, nil
+}
@@ -0,0 +1,71 @@
+package routetable
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// EntrySep separates the fields of the encoded entry, it never appears in the addresses
+	EntrySep = "\x1f"
+)
+
+// RouteEntry is the value of the route with the metadata of the assignment
+type RouteEntry struct {
+	Addr       string
+	Node       string    // the name of the node
+	Version    string    // the version of the service on the node
+	AssignedAt time.Time // the time when the route was assigned
+	Module     string    // optional, the module of the object
+}
+
+// Encode encodes the entry compactly as "addr\x1fnode\x1fversion\x1fassignedAt\x1fmodule",
+// the assignedAt is the unix milliseconds in base 36. The entry without metadata is encoded as the bare addr,
+// so the values written by the string-address API are valid entries.
+func (e RouteEntry) Encode() string {
+	if e.Node == "" && e.Version == "" && e.AssignedAt.IsZero() && e.Module == "" {
+		return e.Addr
+	}
+
+	assignedAt := ""
+	if !e.AssignedAt.IsZero() {
+		assignedAt = strconv.FormatInt(e.AssignedAt.UnixMilli(), 36)
+	}
+	return strings.Join([]string{e.Addr, e.Node, e.Version, assignedAt, e.Module}, EntrySep)
+}
+
+// DecodeEntry decodes the value stored in the route table, the unknown fields are ignored
+func DecodeEntry(value string) RouteEntry {
+	fields := strings.Split(value, EntrySep)
+	e := RouteEntry{Addr: fields[0]}
+	if len(fields) > 1 {
+		e.Node = fields[1]
+	}
+	if len(fields) > 2 {
+		e.Version = fields[2]
+	}
+	if len(fields) > 3 && fields[3] != "" {
+		if ms, err := strconv.ParseInt(fields[3], 36, 64); err == nil {
+			e.AssignedAt = time.UnixMilli(ms)
+		}
+	}
+	if len(fields) > 4 {
+		e.Module = fields[4]
+	}
+	return e
+}
+
+// AddrOf returns the address of the value stored in the route table.
+// The backends index and compare the values by the address, so the metadata never affects the ownership.
+func AddrOf(value string) string {
+	if i := strings.Index(value, EntrySep); i >= 0 {
+		return value[:i]
+	}
+	return value
+}
+
+// SameAddr reports whether the values are routed to the same address
+func SameAddr(a, b string) bool {
+	return AddrOf(a) == AddrOf(b)
+}
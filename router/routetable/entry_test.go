@@ -0,0 +1,68 @@
+package routetable_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable/memory"
+)
+
+func TestRouteEntry_Encode(t *testing.T) {
+	e := routetable.RouteEntry{
+		Addr:       "10.0.2.31:9000",
+		Node:       "player-1",
+		Version:    "1.2.0",
+		AssignedAt: time.UnixMilli(1700000000123),
+		Module:     "guild",
+	}
+	v := e.Encode()
+	assert.Equal(t, "10.0.2.31:9000", routetable.AddrOf(v))
+
+	d := routetable.DecodeEntry(v)
+	assert.Equal(t, e.Addr, d.Addr)
+	assert.Equal(t, e.Node, d.Node)
+	assert.Equal(t, e.Version, d.Version)
+	assert.True(t, e.AssignedAt.Equal(d.AssignedAt))
+	assert.Equal(t, e.Module, d.Module)
+
+	// the bare addr is a valid entry
+	assert.Equal(t, "a", routetable.RouteEntry{Addr: "a"}.Encode())
+	assert.Equal(t, routetable.RouteEntry{Addr: "a"}, routetable.DecodeEntry("a"))
+}
+
+func TestBaseRouteTable_Entry(t *testing.T) {
+	ctx := context.Background()
+	data := memory.NewRouteTable()
+	defer data.Close()
+	rt := routetable.New(data, "player")
+
+	e := routetable.RouteEntry{Addr: "a", Node: "n1", Version: "v1", AssignedAt: time.Now()}
+	require.NoError(t, rt.StoreEntry(ctx, "blue", 1, e))
+
+	addr, err := rt.Load(ctx, "blue", 1)
+	require.NoError(t, err)
+	assert.Equal(t, "a", addr)
+
+	loaded, err := rt.LoadEntry(ctx, "blue", 1)
+	require.NoError(t, err)
+	assert.Equal(t, "n1", loaded.Node)
+	assert.Equal(t, "v1", loaded.Version)
+
+	ok, result, err := rt.SetNx(ctx, "blue", 1, "b")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, "a", result)
+
+	oids, _, err := rt.ListByAddr(ctx, "blue", "a", "", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1}, oids)
+
+	// the entry is deleted by its address
+	require.NoError(t, rt.DelIfSame(ctx, "blue", 1, "a"))
+	_, err = rt.Load(ctx, "blue", 1)
+	assert.Error(t, err)
+}
@@ -0,0 +1,696 @@
+package etcd
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	verrors "github.com/vulcan-frame/vulcan-pkg-app/errors"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const (
+	defaultTimeout     = 2 * time.Second
+	defaultNamespace   = "/vulcan/rt/"
+	defaultLeaseWindow = time.Second
+	errPrefix          = "etcd routeTable"
+
+	routePrefix = "k/"
+	indexPrefix = "i/"
+	markPrefix  = "m/"
+
+	// genMark encloses the generation stored before the value, the route values never start with it
+	genMark = "\x00"
+
+	// maxTxnOps is the default limit of the operations in a transaction of the etcd server
+	maxTxnOps = 128
+	// maxRetries is the max count of the retries when the key is changed during a conditional write
+	maxRetries = 16
+)
+
+var (
+	_ routetable.RouteTableData         = (*RouteTable)(nil)
+	_ routetable.GenRouteTableData      = (*RouteTable)(nil)
+	_ routetable.BatchRouteTableData    = (*RouteTable)(nil)
+	_ routetable.WatchRouteTableData    = (*RouteTable)(nil)
+	_ routetable.IndexRouteTableData    = (*RouteTable)(nil)
+	_ routetable.ScanRouteTableData     = (*RouteTable)(nil)
+	_ routetable.TTLRouteTableData      = (*RouteTable)(nil)
+	_ routetable.SnapshotRouteTableData = (*RouteTable)(nil)
+	_ routetable.MarkRouteTableData     = (*RouteTable)(nil)
+)
+
+type Option func(*RouteTable)
+
+func WithTimeout(dur time.Duration) Option {
+	return func(r *RouteTable) {
+		r.timeout = dur
+	}
+}
+
+// WithNamespace sets the prefix of all the keys written to etcd, the default is "/vulcan/rt/"
+func WithNamespace(ns string) Option {
+	return func(r *RouteTable) {
+		r.namespace = ns
+	}
+}
+
+// WithLeaseWindow sets the window in which the routes with the same ttl share a lease, the default is 1s.
+// The routes may live up to the window longer than their ttl.
+func WithLeaseWindow(dur time.Duration) Option {
+	return func(r *RouteTable) {
+		r.leaseWindow = dur
+	}
+}
+
+// RouteTable is the RouteTableData on etcd.
+// The routes with the same ttl written in the same lease window share a lease, and every route is written with
+// its addr index in a transaction. The lease of the window is granted with the ttl plus the window, so the route lives
+// at least its ttl, and the refresh within the window is free. The leases are not revoked because they are shared,
+// they expire with their routes.
+// The generation is the revision of the write of the value, it is stored before the value,
+// so the changes of the ttl keep the generation.
+// The ttl is rounded up to seconds, and the etcd server may extend the short ttl to its minimum lease ttl.
+type RouteTable struct {
+	cli         *clientv3.Client
+	timeout     time.Duration
+	namespace   string
+	leaseWindow time.Duration
+
+	mu     sync.Mutex
+	leases map[int64]windowLease // by the ttl seconds
+}
+
+// windowLease is the lease shared by the routes with the same ttl in the window starting from granted
+type windowLease struct {
+	id      clientv3.LeaseID
+	granted time.Time
+}
+
+// route is the decoded kv of the route
+type route struct {
+	value string
+	gen   int64
+	lease clientv3.LeaseID
+	rev   int64 // the mod revision compared by the conditional writes
+}
+
+func NewRouteTable(cli *clientv3.Client, opts ...Option) *RouteTable {
+	rt := &RouteTable{
+		cli:         cli,
+		timeout:     defaultTimeout,
+		namespace:   defaultNamespace,
+		leaseWindow: defaultLeaseWindow,
+		leases:      make(map[int64]windowLease),
+	}
+	for _, opt := range opts {
+		opt(rt)
+	}
+	return rt
+}
+
+func wrapErr(err error, operation string, args ...interface{}) error {
+	return errors.Wrapf(err, "%s %s failed %v", errPrefix, operation, args)
+}
+
+func notFound(operation string, args ...interface{}) error {
+	return wrapErr(errors.Wrapf(verrors.ErrRouteTableNotFound, "%s data not found", operation), operation, args...)
+}
+
+func (rt *RouteTable) routeKey(key string) string {
+	return rt.namespace + routePrefix + key
+}
+
+// indexKey is the key in the index of the keys routed to the addr of the value, it is attached to the lease of the route
+func (rt *RouteTable) indexKey(value string, key string) string {
+	return rt.indexPrefix(routetable.AddrOf(value)) + key
+}
+
+func (rt *RouteTable) indexPrefix(addr string) string {
+	return rt.namespace + indexPrefix + addr + "\x00"
+}
+
+func ttlSeconds(dur time.Duration) int64 {
+	return max(int64(math.Ceil(dur.Seconds())), 1)
+}
+
+// encode stores the generation before the value
+func encode(value string, gen int64) string {
+	return genMark + strconv.FormatInt(gen, 10) + genMark + value
+}
+
+// decode returns the route of the kv, the generation of the value written without it is the mod revision
+func decode(kv *mvccpb.KeyValue) *route {
+	r := &route{value: string(kv.Value), gen: kv.ModRevision, lease: clientv3.LeaseID(kv.Lease), rev: kv.ModRevision}
+	if rest, ok := strings.CutPrefix(r.value, genMark); ok {
+		if gen, value, ok := strings.Cut(rest, genMark); ok {
+			if g, err := strconv.ParseInt(gen, 10, 64); err == nil {
+				r.value, r.gen = value, g
+			}
+		}
+	}
+	return r
+}
+
+func (rt *RouteTable) Set(ctx context.Context, key string, addr string, dur time.Duration) error {
+	if dur <= 0 {
+		return wrapErr(errors.Errorf("invalid expire time %s", dur), "Set", "key", key, "addr", addr)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
+	defer cancel()
+
+	if _, _, _, err := rt.put(ctx, key, addr, dur, nil); err != nil {
+		return wrapErr(err, "Set", "key", key, "addr", addr)
+	}
+	return nil
+}
+
+func (rt *RouteTable) GetSet(ctx context.Context, key string, addr string, dur time.Duration) (string, error) {
+	old, _, err := rt.GetSetGen(ctx, key, addr, dur)
+	return old, err
+}
+
+// GetSetGen sets the value with expiration, returns the old value and the new generation.
+// The value is stored even if the old one not exists, in which case ErrRouteTableNotFound is returned.
+func (rt *RouteTable) GetSetGen(ctx context.Context, key string, addr string, dur time.Duration) (string, int64, error) {
+	if dur <= 0 {
+		return "", 0, wrapErr(errors.Errorf("invalid expire time %s", dur), "GetSet", "key", key, "addr", addr)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
+	defer cancel()
+
+	prev, _, gen, err := rt.put(ctx, key, addr, dur, nil)
+	if err != nil {
+		return "", 0, wrapErr(err, "GetSet", "key", key, "addr", addr)
+	}
+	if prev == nil {
+		return "", gen, notFound("GetSet", "key", key, "addr", addr)
+	}
+	return prev.value, gen, nil
+}
+
+// SetNx sets the value if not exists with expiration, returns:
+// ok - true when key was set
+// result - current value (new value when ok=true)
+// err - operation error
+func (rt *RouteTable) SetNx(ctx context.Context, key string, addr string, dur time.Duration) (bool, string, error) {
+	ok, result, _, err := rt.SetNxGen(ctx, key, addr, dur)
+	return ok, result, err
+}
+
+// SetNxGen is SetNx which also returns the generation of the current value
+func (rt *RouteTable) SetNxGen(ctx context.Context, key string, addr string, dur time.Duration) (bool, string, int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
+	defer cancel()
+
+	prev, ok, gen, err := rt.put(ctx, key, addr, dur, func(prev *route) bool {
+		return prev == nil
+	})
+	if err != nil {
+		return false, "", 0, wrapErr(err, "SetNx", "key", key, "addr", addr)
+	}
+	if !ok {
+		return false, prev.value, prev.gen, nil
+	}
+	return true, addr, gen, nil
+}
+
+// CompareAndSwap sets the value only if the current generation is expectedGen, the generation of the missing key is 0.
+// It returns the new generation when swapped, otherwise the current generation.
+func (rt *RouteTable) CompareAndSwap(ctx context.Context, key string, expectedGen int64, addr string, dur time.Duration) (bool, int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
+	defer cancel()
+
+	prev, ok, gen, err := rt.put(ctx, key, addr, dur, func(prev *route) bool {
+		return generation(prev) == expectedGen
+	})
+	if err != nil {
+		return false, 0, wrapErr(err, "CompareAndSwap", "key", key, "addr", addr, "gen", expectedGen)
+	}
+	if !ok {
+		return false, generation(prev), nil
+	}
+	return true, gen, nil
+}
+
+func generation(r *route) int64 {
+	if r == nil {
+		return 0
+	}
+	return r.gen
+}
+
+func (rt *RouteTable) Load(ctx context.Context, key string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
+	defer cancel()
+
+	r, _, err := rt.get(ctx, key)
+	if err != nil {
+		return "", wrapErr(err, "Load", "key", key)
+	}
+	if r == nil {
+		return "", notFound("Load", "key", key)
+	}
+	return r.value, nil
+}
+
+func (rt *RouteTable) LoadGen(ctx context.Context, key string) (string, int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
+	defer cancel()
+
+	r, _, err := rt.get(ctx, key)
+	if err != nil {
+		return "", 0, wrapErr(err, "LoadGen", "key", key)
+	}
+	if r == nil {
+		return "", 0, notFound("LoadGen", "key", key)
+	}
+	return r.value, r.gen, nil
+}
+
+// LoadAndExpire loads the value and resets the expiration like redis GETEX:
+// dur > 0 sets the expiration, dur == 0 removes it and dur < 0 keeps it unchanged.
+func (rt *RouteTable) LoadAndExpire(ctx context.Context, key string, dur time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
+	defer cancel()
+
+	r, _, err := rt.get(ctx, key)
+	if err != nil {
+		return "", wrapErr(err, "LoadAndExpire", "key", key)
+	}
+	if r == nil {
+		return "", notFound("LoadAndExpire", "key", key)
+	}
+	if dur >= 0 {
+		if err := rt.refresh(ctx, key, r, dur); err != nil {
+			return "", wrapErr(err, "LoadAndExpire", "key", key)
+		}
+	}
+	return r.value, nil
+}
+
+func (rt *RouteTable) Del(ctx context.Context, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
+	defer cancel()
+
+	if _, err := rt.delete(ctx, key, nil); err != nil {
+		return wrapErr(err, "Del", "key", key)
+	}
+	return nil
+}
+
+// DelIfSame deletes the key if its value has the same address as the value
+func (rt *RouteTable) DelIfSame(ctx context.Context, key string, value string) error {
+	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
+	defer cancel()
+
+	_, err := rt.delete(ctx, key, func(prev *route) bool {
+		return routetable.SameAddr(prev.value, value)
+	})
+	if err != nil {
+		return wrapErr(err, "DelIfSame", "key", key, "value", value)
+	}
+	return nil
+}
+
+// Expire sets the expiration of the key, the key is deleted immediately when expiration <= 0.
+func (rt *RouteTable) Expire(ctx context.Context, key string, expiration time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
+	defer cancel()
+
+	if expiration <= 0 {
+		if _, err := rt.delete(ctx, key, nil); err != nil {
+			return wrapErr(err, "Expire", "key", key)
+		}
+		return nil
+	}
+
+	r, _, err := rt.get(ctx, key)
+	if err != nil {
+		return wrapErr(err, "Expire", "key", key)
+	}
+	if r == nil {
+		return nil
+	}
+	if err := rt.refresh(ctx, key, r, expiration); err != nil {
+		return wrapErr(err, "Expire", "key", key)
+	}
+	return nil
+}
+
+func (rt *RouteTable) LoadMany(ctx context.Context, keys []string) ([]string, []error) {
+	addrs := make([]string, len(keys))
+	errs := make([]error, len(keys))
+
+	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
+	defer cancel()
+
+	for start := 0; start < len(keys); start += maxTxnOps {
+		batch := keys[start:min(start+maxTxnOps, len(keys))]
+		routes, err := rt.getMany(ctx, batch)
+		for i, key := range batch {
+			switch {
+			case err != nil:
+				errs[start+i] = wrapErr(err, "LoadMany", "key", key)
+			case routes[i] == nil:
+				errs[start+i] = notFound("LoadMany", "key", key)
+			default:
+				addrs[start+i] = routes[i].value
+			}
+		}
+	}
+	return addrs, errs
+}
+
+func (rt *RouteTable) SetMany(ctx context.Context, keys []string, addrs []string, dur time.Duration) []error {
+	errs := make([]error, len(keys))
+	if len(keys) != len(addrs) || dur <= 0 {
+		for i, key := range keys {
+			errs[i] = wrapErr(errors.Errorf("invalid arguments. addrs=%d dur=%s", len(addrs), dur), "SetMany", "key", key)
+		}
+		return errs
+	}
+
+	for i, key := range keys {
+		errs[i] = rt.Set(ctx, key, addrs[i], dur)
+	}
+	return errs
+}
+
+func (rt *RouteTable) DelMany(ctx context.Context, keys []string) []error {
+	errs := make([]error, len(keys))
+	for i, key := range keys {
+		errs[i] = rt.Del(ctx, key)
+	}
+	return errs
+}
+
+// RenewMany resets the expiration of the keys owned by the addr,
+// ErrRouteTableNotFound is returned for the keys missing or owned by another addr.
+// The keys are moved to the lease of the window in a transaction per batch, the keys which already have it are skipped.
+func (rt *RouteTable) RenewMany(ctx context.Context, keys []string, addr string, dur time.Duration) []error {
+	errs := make([]error, len(keys))
+	if dur <= 0 {
+		for i, key := range keys {
+			errs[i] = wrapErr(errors.Errorf("invalid expire time %s", dur), "RenewMany", "key", key)
+		}
+		return errs
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
+	defer cancel()
+
+	// every route is renewed with its index by 2 operations
+	batchSize := maxTxnOps / 2
+	for start := 0; start < len(keys); start += batchSize {
+		batch := keys[start:min(start+batchSize, len(keys))]
+		routes, err := rt.getMany(ctx, batch)
+		if err != nil {
+			for i, key := range batch {
+				errs[start+i] = wrapErr(err, "RenewMany", "key", key, "addr", addr)
+			}
+			continue
+		}
+
+		stale := make([]int, 0, len(batch))
+		for i, key := range batch {
+			switch {
+			case routes[i] == nil || !routetable.SameAddr(routes[i].value, addr):
+				errs[start+i] = notFound("RenewMany", "key", key, "addr", addr)
+			case !rt.fresh(routes[i].lease, dur):
+				stale = append(stale, i)
+			}
+		}
+		if len(stale) == 0 {
+			continue
+		}
+
+		ok, err := rt.renewBatch(ctx, batch, routes, stale, dur)
+		if err == nil && ok {
+			continue
+		}
+		// some routes are changed or the lease is lost, they are renewed one by one
+		for _, i := range stale {
+			errs[start+i] = rt.renew(ctx, batch[i], addr, dur)
+		}
+	}
+	return errs
+}
+
+// renewBatch moves the stale routes to the lease of the window if none of them is changed
+func (rt *RouteTable) renewBatch(ctx context.Context, keys []string, routes []*route, stale []int, dur time.Duration) (bool, error) {
+	lease, err := rt.acquire(ctx, dur)
+	if err != nil {
+		return false, err
+	}
+
+	cmps := make([]clientv3.Cmp, 0, len(stale))
+	ops := make([]clientv3.Op, 0, len(stale)*2)
+	for _, i := range stale {
+		routeKey := rt.routeKey(keys[i])
+		cmps = append(cmps, unchanged(routeKey, routes[i]))
+		ops = append(ops,
+			clientv3.OpPut(routeKey, "", clientv3.WithIgnoreValue(), clientv3.WithLease(lease)),
+			clientv3.OpPut(rt.indexKey(routes[i].value, keys[i]), "", clientv3.WithIgnoreValue(), clientv3.WithLease(lease)),
+		)
+	}
+
+	resp, err := rt.cli.Txn(ctx).If(cmps...).Then(ops...).Commit()
+	if err != nil {
+		rt.lost(lease, err)
+		return false, err
+	}
+	return resp.Succeeded, nil
+}
+
+// renew renews the route if it's still owned by the addr
+func (rt *RouteTable) renew(ctx context.Context, key string, addr string, dur time.Duration) error {
+	r, _, err := rt.get(ctx, key)
+	if err != nil {
+		return wrapErr(err, "RenewMany", "key", key, "addr", addr)
+	}
+	if r == nil || !routetable.SameAddr(r.value, addr) {
+		return notFound("RenewMany", "key", key, "addr", addr)
+	}
+	if err := rt.refresh(ctx, key, r, dur); err != nil {
+		return wrapErr(err, "RenewMany", "key", key, "addr", addr)
+	}
+	return nil
+}
+
+// get returns the route and the revision of the store, the route is nil if not exists
+func (rt *RouteTable) get(ctx context.Context, key string) (*route, int64, error) {
+	resp, err := rt.cli.Get(ctx, rt.routeKey(key))
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, resp.Header.Revision, nil
+	}
+	return decode(resp.Kvs[0]), resp.Header.Revision, nil
+}
+
+// getMany returns the routes of the keys in a transaction, the route is nil if not exists
+func (rt *RouteTable) getMany(ctx context.Context, keys []string) ([]*route, error) {
+	ops := make([]clientv3.Op, len(keys))
+	for i, key := range keys {
+		ops[i] = clientv3.OpGet(rt.routeKey(key))
+	}
+
+	resp, err := rt.cli.Txn(ctx).Then(ops...).Commit()
+	if err != nil {
+		return nil, err
+	}
+	routes := make([]*route, len(keys))
+	for i := range keys {
+		if kvs := resp.Responses[i].GetResponseRange().Kvs; len(kvs) > 0 {
+			routes[i] = decode(kvs[0])
+		}
+	}
+	return routes, nil
+}
+
+// put writes the value with its index if cond accepts the current route, the nil cond accepts any route.
+// The write is retried when the key is changed concurrently. It returns the previous route,
+// whether the value is written and the new generation.
+// The generation is the revision read before the write plus one, which is greater than the generations of all the
+// previous writes of the key and not greater than the revision of this write.
+func (rt *RouteTable) put(ctx context.Context, key string, value string, dur time.Duration, cond func(prev *route) bool) (*route, bool, int64, error) {
+	routeKey := rt.routeKey(key)
+	for range maxRetries {
+		prev, rev, err := rt.get(ctx, key)
+		if err != nil {
+			return nil, false, 0, err
+		}
+		if cond != nil && !cond(prev) {
+			return prev, false, 0, nil
+		}
+
+		lease, err := rt.acquire(ctx, dur)
+		if err != nil {
+			return nil, false, 0, err
+		}
+
+		gen := rev + 1
+		ops := []clientv3.Op{
+			clientv3.OpPut(routeKey, encode(value, gen), clientv3.WithLease(lease)),
+			clientv3.OpPut(rt.indexKey(value, key), "", clientv3.WithLease(lease)),
+		}
+		if prev != nil && !routetable.SameAddr(prev.value, value) {
+			ops = append(ops, clientv3.OpDelete(rt.indexKey(prev.value, key)))
+		}
+
+		resp, err := rt.cli.Txn(ctx).If(unchanged(routeKey, prev)).Then(ops...).Commit()
+		if err != nil {
+			if rt.lost(lease, err) {
+				continue
+			}
+			return nil, false, 0, err
+		}
+		if !resp.Succeeded {
+			continue
+		}
+		return prev, true, gen, nil
+	}
+	return nil, false, 0, errors.Errorf("too many conflicts")
+}
+
+// delete deletes the key with its index if cond accepts the current route, the nil cond accepts any route.
+// It returns the deleted route, nil if nothing deleted.
+func (rt *RouteTable) delete(ctx context.Context, key string, cond func(prev *route) bool) (*route, error) {
+	routeKey := rt.routeKey(key)
+	for range maxRetries {
+		prev, _, err := rt.get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if prev == nil || (cond != nil && !cond(prev)) {
+			return nil, nil
+		}
+
+		resp, err := rt.cli.Txn(ctx).If(unchanged(routeKey, prev)).Then(
+			clientv3.OpDelete(routeKey),
+			clientv3.OpDelete(rt.indexKey(prev.value, key)),
+		).Commit()
+		if err != nil {
+			return nil, err
+		}
+		if !resp.Succeeded {
+			continue
+		}
+		return prev, nil
+	}
+	return nil, errors.Errorf("too many conflicts")
+}
+
+// refresh resets the ttl of the route, dur == 0 removes the ttl.
+// The route is moved to the lease of the window if it doesn't have it, which keeps the value and the generation.
+func (rt *RouteTable) refresh(ctx context.Context, key string, r *route, dur time.Duration) error {
+	if (dur == 0 && r.lease == clientv3.NoLease) || (dur > 0 && rt.fresh(r.lease, dur)) {
+		return nil
+	}
+
+	for range 2 {
+		lease, err := rt.acquire(ctx, dur)
+		if err != nil {
+			return err
+		}
+
+		routeKey := rt.routeKey(key)
+		_, err = rt.cli.Txn(ctx).If(unchanged(routeKey, r)).Then(
+			clientv3.OpPut(routeKey, "", clientv3.WithIgnoreValue(), clientv3.WithLease(lease)),
+			clientv3.OpPut(rt.indexKey(r.value, key), "", clientv3.WithIgnoreValue(), clientv3.WithLease(lease)),
+		).Commit()
+		// the route changed by another writer has its own ttl
+		if err == nil || !rt.lost(lease, err) {
+			return err
+		}
+	}
+	return errors.Errorf("the lease is lost")
+}
+
+// acquire returns the lease shared by the routes of the ttl in the current window, NoLease if dur <= 0.
+// The lease is granted outside the lock, so the writers are not serialized behind the grant. When several writers
+// grant at the same time the first one stored wins, and the other leases expire unused.
+func (rt *RouteTable) acquire(ctx context.Context, dur time.Duration) (clientv3.LeaseID, error) {
+	if dur <= 0 {
+		return clientv3.NoLease, nil
+	}
+
+	ttl := ttlSeconds(dur)
+	if id, ok := rt.current(ttl); ok {
+		return id, nil
+	}
+
+	granted := time.Now()
+	resp, err := rt.cli.Grant(ctx, ttlSeconds(dur+rt.leaseWindow))
+	if err != nil {
+		return clientv3.NoLease, errors.Wrapf(err, "grant lease failed")
+	}
+
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	if l, ok := rt.leases[ttl]; ok && time.Since(l.granted) < rt.leaseWindow {
+		return l.id, nil
+	}
+	rt.leases[ttl] = windowLease{id: resp.ID, granted: granted}
+	return resp.ID, nil
+}
+
+// current returns the lease of the ttl if it's in the current window
+func (rt *RouteTable) current(ttl int64) (clientv3.LeaseID, bool) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	l, ok := rt.leases[ttl]
+	if !ok || time.Since(l.granted) >= rt.leaseWindow {
+		return clientv3.NoLease, false
+	}
+	return l.id, true
+}
+
+// fresh reports whether the lease is the one of the ttl in the current window, the route with it lives at least the ttl
+func (rt *RouteTable) fresh(lease clientv3.LeaseID, dur time.Duration) bool {
+	if lease == clientv3.NoLease {
+		return false
+	}
+
+	id, ok := rt.current(ttlSeconds(dur))
+	return ok && id == lease
+}
+
+// lost forgets the lease if the write fails because the lease is not found, such as revoked by the operator.
+// It returns true if the write can be retried with a new lease.
+func (rt *RouteTable) lost(lease clientv3.LeaseID, err error) bool {
+	if lease == clientv3.NoLease || !errors.Is(err, rpctypes.ErrLeaseNotFound) {
+		return false
+	}
+
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	for ttl, l := range rt.leases {
+		if l.id == lease {
+			delete(rt.leases, ttl)
+		}
+	}
+	return true
+}
+
+// unchanged compares the key with the route loaded before, the nil route means the key not exists
+func unchanged(routeKey string, r *route) clientv3.Cmp {
+	if r == nil {
+		return clientv3.Compare(clientv3.CreateRevision(routeKey), "=", 0)
+	}
+	return clientv3.Compare(clientv3.ModRevision(routeKey), "=", r.rev)
+}
@@ -0,0 +1,318 @@
+package etcd
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	verrors "github.com/vulcan-frame/vulcan-pkg-app/errors"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable/routetabletest"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/server/v3/embed"
+)
+
+func newTestRouteTable(t *testing.T) *RouteTable {
+	return NewRouteTable(newTestClient(t))
+}
+
+func newTestClient(t *testing.T) *clientv3.Client {
+	cfg := embed.NewConfig()
+	cfg.Dir = t.TempDir()
+	cfg.LogLevel = "error"
+	cfg.TickMs = 10
+	cfg.ElectionMs = 100
+
+	cu, pu := freeURL(t), freeURL(t)
+	cfg.ListenClientUrls = []url.URL{cu}
+	cfg.AdvertiseClientUrls = []url.URL{cu}
+	cfg.ListenPeerUrls = []url.URL{pu}
+	cfg.AdvertisePeerUrls = []url.URL{pu}
+	cfg.InitialCluster = cfg.InitialClusterFromName(cfg.Name)
+
+	e, err := embed.StartEtcd(cfg)
+	require.NoError(t, err)
+	t.Cleanup(e.Close)
+
+	select {
+	case <-e.Server.ReadyNotify():
+	case <-time.After(10 * time.Second):
+		t.Fatal("embedded etcd is not ready")
+	}
+
+	cli, err := clientv3.New(clientv3.Config{Endpoints: []string{cu.Host}, DialTimeout: 5 * time.Second})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = cli.Close() })
+	return cli
+}
+
+func freeURL(t *testing.T) url.URL {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	return url.URL{Scheme: "http", Host: l.Addr().String()}
+}
+
+func TestRouteTable(t *testing.T) {
+	ctx := context.Background()
+	rt := newTestRouteTable(t)
+
+	ch, err := rt.Watch(ctx, "r_")
+	require.NoError(t, err)
+
+	_, err = rt.Load(ctx, "r_1")
+	assert.ErrorIs(t, err, verrors.ErrRouteTableNotFound)
+
+	ok, result, gen1, err := rt.SetNxGen(ctx, "r_1", "a", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "a", result)
+
+	ok, result, gen, err := rt.SetNxGen(ctx, "r_1", "b", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, "a", result)
+	assert.Equal(t, gen1, gen)
+
+	// the refreshed ttl keeps the generation, even if the route is moved to another lease
+	addr, err := rt.LoadAndExpire(ctx, "r_1", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "a", addr)
+	require.NoError(t, rt.Expire(ctx, "r_1", time.Hour))
+	_, gen, err = rt.LoadGen(ctx, "r_1")
+	require.NoError(t, err)
+	assert.Equal(t, gen1, gen)
+
+	old, gen2, err := rt.GetSetGen(ctx, "r_1", "b", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "a", old)
+	assert.Greater(t, gen2, gen1)
+
+	ok, _, err = rt.CompareAndSwap(ctx, "r_1", gen1, "c", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, _, err = rt.CompareAndSwap(ctx, "r_1", gen2, "c", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	_, err = rt.GetSet(ctx, "r_2", "c", time.Minute)
+	assert.ErrorIs(t, err, verrors.ErrRouteTableNotFound)
+
+	keys, next, err := rt.ListByAddr(ctx, "c", "r_", "", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"r_1", "r_2"}, keys)
+	assert.Empty(t, next)
+	keys, _, err = rt.ListByAddr(ctx, "a", "r_", "", 10)
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	require.NoError(t, rt.DelIfSame(ctx, "r_1", "a"))
+	addr, err = rt.Load(ctx, "r_1")
+	require.NoError(t, err)
+	assert.Equal(t, "c", addr)
+	require.NoError(t, rt.DelIfSame(ctx, "r_1", "c"))
+	_, err = rt.Load(ctx, "r_1")
+	assert.ErrorIs(t, err, verrors.ErrRouteTableNotFound)
+
+	errs := rt.SetMany(ctx, []string{"r_3", "r_4"}, []string{"d", "d"}, time.Minute)
+	assert.Equal(t, []error{nil, nil}, errs)
+	addrs, errs := rt.LoadMany(ctx, []string{"r_3", "r_5"})
+	assert.Equal(t, "d", addrs[0])
+	assert.NoError(t, errs[0])
+	assert.ErrorIs(t, errs[1], verrors.ErrRouteTableNotFound)
+
+	var scanned []string
+	require.NoError(t, rt.Scan(ctx, "r_", func(keys []string) error {
+		scanned = append(scanned, keys...)
+		return nil
+	}))
+	assert.Equal(t, []string{"r_2", "r_3", "r_4"}, scanned)
+
+	kinds := make([]routetable.EventKind, 0)
+	for len(kinds) < 7 {
+		select {
+		case ev := <-ch:
+			kinds = append(kinds, ev.Kind)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("watch events timeout. got=%v", kinds)
+		}
+	}
+	assert.Equal(t, []routetable.EventKind{
+		routetable.EventSet, routetable.EventSet, routetable.EventSet, routetable.EventSet,
+		routetable.EventDelete, routetable.EventSet, routetable.EventSet,
+	}, kinds)
+}
+
+func TestRouteTable_Expire(t *testing.T) {
+	ctx := context.Background()
+	rt := newTestRouteTable(t)
+
+	require.NoError(t, rt.Set(ctx, "r_1", "a", time.Second))
+	require.NoError(t, rt.Set(ctx, "r_2", "a", time.Second))
+	errs := rt.RenewMany(ctx, []string{"r_1", "r_3"}, "a", time.Minute)
+	assert.NoError(t, errs[0])
+	assert.ErrorIs(t, errs[1], verrors.ErrRouteTableNotFound)
+
+	assert.Eventually(t, func() bool {
+		_, err := rt.Load(ctx, "r_2")
+		return err != nil
+	}, 5*time.Second, 100*time.Millisecond)
+
+	_, err := rt.Load(ctx, "r_1")
+	require.NoError(t, err)
+	keys, _, err := rt.ListByAddr(ctx, "a", "r_", "", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"r_1"}, keys)
+
+	require.NoError(t, rt.Expire(ctx, "r_1", 0))
+	_, err = rt.Load(ctx, "r_1")
+	assert.ErrorIs(t, err, verrors.ErrRouteTableNotFound)
+}
+
+func TestRouteTable_Lease(t *testing.T) {
+	ctx := context.Background()
+	cli := newTestClient(t)
+	rt := NewRouteTable(cli, WithLeaseWindow(time.Minute))
+
+	require.NoError(t, rt.Set(ctx, "r_1", "a", time.Minute))
+	require.NoError(t, rt.Set(ctx, "r_2", "b", time.Minute))
+	require.NoError(t, rt.Set(ctx, "r_3", "a", time.Hour))
+
+	lease := func(key string) int64 {
+		resp, err := cli.Get(ctx, rt.routeKey(key))
+		require.NoError(t, err)
+		require.Len(t, resp.Kvs, 1)
+		return resp.Kvs[0].Lease
+	}
+	assert.Equal(t, lease("r_1"), lease("r_2"), "the routes of the same ttl share the lease")
+	assert.NotEqual(t, lease("r_1"), lease("r_3"))
+
+	errs := rt.RenewMany(ctx, []string{"r_1", "r_3"}, "a", time.Hour)
+	assert.Equal(t, []error{nil, nil}, errs)
+	assert.Equal(t, lease("r_3"), lease("r_1"), "the renewed route is moved to the lease of the ttl")
+
+	leases, err := cli.Leases(ctx)
+	require.NoError(t, err)
+	assert.Len(t, leases.Leases, 2)
+}
+
+func TestRouteTable_TTLAndMarks(t *testing.T) {
+	ctx := context.Background()
+	rt := newTestRouteTable(t)
+
+	require.NoError(t, rt.Set(ctx, "r_1", "a", time.Minute))
+	require.NoError(t, rt.Set(ctx, "r_2", "a", time.Hour))
+	ttl, err := rt.TTL(ctx, "r_1")
+	require.NoError(t, err)
+	assert.InDelta(t, time.Minute.Seconds(), ttl.Seconds(), 2)
+	_, err = rt.TTL(ctx, "r_3")
+	assert.ErrorIs(t, err, verrors.ErrRouteTableNotFound)
+
+	values, ttls, errs := rt.LoadManyTTL(ctx, []string{"r_1", "r_2", "r_3"})
+	assert.Equal(t, []string{"a", "a", ""}, values)
+	assert.InDelta(t, time.Hour.Seconds(), ttls[1].Seconds(), 2)
+	assert.NoError(t, errs[0])
+	assert.NoError(t, errs[1])
+	assert.ErrorIs(t, errs[2], verrors.ErrRouteTableNotFound)
+
+	require.NoError(t, rt.SetMark(ctx, "rd_a", time.Minute))
+	marked, err := rt.LoadMarks(ctx, []string{"rd_a", "rd_b"})
+	require.NoError(t, err)
+	assert.Equal(t, []bool{true, false}, marked)
+
+	// the marks are not routes
+	_, err = rt.Load(ctx, "rd_a")
+	assert.ErrorIs(t, err, verrors.ErrRouteTableNotFound)
+	var scanned []string
+	require.NoError(t, rt.Scan(ctx, "", func(keys []string) error {
+		scanned = append(scanned, keys...)
+		return nil
+	}))
+	assert.Equal(t, []string{"r_1", "r_2"}, scanned)
+
+	require.NoError(t, rt.DelMark(ctx, "rd_a"))
+	marked, err = rt.LoadMarks(ctx, []string{"rd_a"})
+	require.NoError(t, err)
+	assert.Equal(t, []bool{false}, marked)
+}
+
+func TestRouteTable_AcquireConcurrent(t *testing.T) {
+	ctx := context.Background()
+	rt := NewRouteTable(newTestClient(t), WithLeaseWindow(time.Minute))
+
+	var wg sync.WaitGroup
+	leases := make([]clientv3.LeaseID, 8)
+	for i := range leases {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var err error
+			leases[i], err = rt.acquire(ctx, time.Minute)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	// the writers racing on the grant share the lease stored first
+	for _, l := range leases {
+		assert.Equal(t, leases[0], l)
+	}
+}
+
+func TestRouteTable_ListByAddr(t *testing.T) {
+	ctx := context.Background()
+	rt := newTestRouteTable(t)
+
+	for _, key := range []string{"r_1", "r_2", "r_3"} {
+		require.NoError(t, rt.Set(ctx, key, "a", time.Minute))
+	}
+
+	keys, next, err := rt.ListByAddr(ctx, "a", "r_", "", 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"r_1"}, keys)
+
+	// the page after the cursor is not shifted by the deletion before it
+	require.NoError(t, rt.Del(ctx, "r_1"))
+	keys, next, err = rt.ListByAddr(ctx, "a", "r_", next, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"r_2"}, keys)
+	keys, next, err = rt.ListByAddr(ctx, "a", "r_", next, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"r_3"}, keys)
+	assert.Empty(t, next)
+}
+
+func TestRouteTable_WatchError(t *testing.T) {
+	cli := newTestClient(t)
+	rt := NewRouteTable(cli)
+
+	ch, err := rt.Watch(context.Background(), "r_")
+	require.NoError(t, err)
+	require.NoError(t, cli.Close())
+
+	select {
+	case ev, ok := <-ch:
+		require.True(t, ok)
+		assert.Equal(t, routetable.EventError, ev.Kind)
+		assert.Error(t, ev.Err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the watch error is not delivered")
+	}
+	_, ok := <-ch
+	assert.False(t, ok, "the channel is closed after the error")
+}
+
+func TestConformance(t *testing.T) {
+	// the embedded etcd is shared by the cases and cleared before each of them
+	cli := newTestClient(t)
+	routetabletest.RunConformance(t, func(t *testing.T) routetable.RouteTableData {
+		_, err := cli.Delete(context.Background(), "", clientv3.WithPrefix())
+		require.NoError(t, err)
+		return NewRouteTable(cli)
+	}, routetabletest.WithShortTTL(time.Second))
+}
@@ -0,0 +1,78 @@
+package etcd
+
+import (
+	"context"
+	"strings"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const (
+	defaultListCount = 10
+	scanBatchSize    = 100
+)
+
+// ListByAddr pages through the keys with the prefix routed to the addr in the key order,
+// the cursor is the last key of the previous page, so the page starts right after it.
+func (rt *RouteTable) ListByAddr(ctx context.Context, addr string, prefix string, cursor string, count int64) ([]string, string, error) {
+	if count <= 0 {
+		count = defaultListCount
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
+	defer cancel()
+
+	idx := rt.indexPrefix(addr)
+	start, end := idx+prefix, clientv3.GetPrefixRangeEnd(idx+prefix)
+	if cursor != "" {
+		start = idx + cursor + "\x00"
+	}
+	resp, err := rt.cli.Get(ctx, start, clientv3.WithRange(end), clientv3.WithKeysOnly(),
+		clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend), clientv3.WithLimit(count))
+	if err != nil {
+		return nil, "", wrapErr(err, "ListByAddr", "addr", addr, "prefix", prefix, "cursor", cursor)
+	}
+
+	keys := make([]string, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		keys = append(keys, strings.TrimPrefix(string(kv.Key), idx))
+	}
+	if !resp.More || len(keys) == 0 {
+		return keys, "", nil
+	}
+	return keys, keys[len(keys)-1], nil
+}
+
+// Scan iterates the keys with the prefix in the key order by the pages of the range requests
+func (rt *RouteTable) Scan(ctx context.Context, prefix string, fn func(keys []string) error) error {
+	start := rt.routeKey(prefix)
+	end := clientv3.GetPrefixRangeEnd(start)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		reqCtx, cancel := context.WithTimeout(ctx, rt.timeout)
+		resp, err := rt.cli.Get(reqCtx, start, clientv3.WithRange(end), clientv3.WithKeysOnly(),
+			clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend), clientv3.WithLimit(scanBatchSize))
+		cancel()
+		if err != nil {
+			return wrapErr(err, "Scan", "prefix", prefix)
+		}
+		if len(resp.Kvs) == 0 {
+			return nil
+		}
+
+		keys := make([]string, len(resp.Kvs))
+		for i, kv := range resp.Kvs {
+			keys[i] = strings.TrimPrefix(string(kv.Key), rt.routeKey(""))
+		}
+		if err := fn(keys); err != nil {
+			return err
+		}
+		if !resp.More {
+			return nil
+		}
+		start = string(resp.Kvs[len(resp.Kvs)-1].Key) + "\x00"
+	}
+}
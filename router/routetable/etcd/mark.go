@@ -0,0 +1,75 @@
+package etcd
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// markKey is the key of the mark, it's apart from the routes and their index,
+// so the marks are not listed, scanned or watched as the routes
+func (rt *RouteTable) markKey(key string) string {
+	return rt.namespace + markPrefix + key
+}
+
+// SetMark sets the mark on the lease of the window, it's not indexed or versioned like the routes
+func (rt *RouteTable) SetMark(ctx context.Context, key string, dur time.Duration) error {
+	if dur <= 0 {
+		return wrapErr(errors.Errorf("invalid expire time %s", dur), "SetMark", "key", key)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
+	defer cancel()
+
+	for range 2 {
+		lease, err := rt.acquire(ctx, dur)
+		if err != nil {
+			return wrapErr(err, "SetMark", "key", key)
+		}
+		_, err = rt.cli.Put(ctx, rt.markKey(key), "1", clientv3.WithLease(lease))
+		if err == nil {
+			return nil
+		}
+		if !rt.lost(lease, err) {
+			return wrapErr(err, "SetMark", "key", key)
+		}
+	}
+	return wrapErr(errors.Errorf("the lease is lost"), "SetMark", "key", key)
+}
+
+func (rt *RouteTable) DelMark(ctx context.Context, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
+	defer cancel()
+
+	if _, err := rt.cli.Delete(ctx, rt.markKey(key)); err != nil {
+		return wrapErr(err, "DelMark", "key", key)
+	}
+	return nil
+}
+
+// LoadMarks checks the marks in a transaction per batch
+func (rt *RouteTable) LoadMarks(ctx context.Context, keys []string) ([]bool, error) {
+	marked := make([]bool, len(keys))
+
+	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
+	defer cancel()
+
+	for start := 0; start < len(keys); start += maxTxnOps {
+		batch := keys[start:min(start+maxTxnOps, len(keys))]
+		ops := make([]clientv3.Op, len(batch))
+		for i, key := range batch {
+			ops[i] = clientv3.OpGet(rt.markKey(key), clientv3.WithCountOnly())
+		}
+
+		resp, err := rt.cli.Txn(ctx).Then(ops...).Commit()
+		if err != nil {
+			return nil, wrapErr(err, "LoadMarks", "keys", batch)
+		}
+		for i := range batch {
+			marked[start+i] = resp.Responses[i].GetResponseRange().Count > 0
+		}
+	}
+	return marked, nil
+}
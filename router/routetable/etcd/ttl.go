@@ -0,0 +1,85 @@
+package etcd
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// TTL returns the remaining ttl of the lease of the key, 0 means no expiration.
+// The lease is shared by the routes of the window, so the ttl may be up to the lease window longer than the one set.
+func (rt *RouteTable) TTL(ctx context.Context, key string) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
+	defer cancel()
+
+	r, _, err := rt.get(ctx, key)
+	if err != nil {
+		return 0, wrapErr(err, "TTL", "key", key)
+	}
+	if r == nil {
+		return 0, notFound("TTL", "key", key)
+	}
+	ttl, err := rt.leaseTTL(ctx, r.lease)
+	if err != nil {
+		return 0, wrapErr(err, "TTL", "key", key)
+	}
+	return ttl, nil
+}
+
+// LoadManyTTL loads the values with the remaining ttl in a transaction per batch, the ttl is 0 if the key has no expiration.
+// The ttl is read once per lease, and the routes of a window share a lease, so it takes a few requests per batch.
+func (rt *RouteTable) LoadManyTTL(ctx context.Context, keys []string) ([]string, []time.Duration, []error) {
+	values := make([]string, len(keys))
+	ttls := make([]time.Duration, len(keys))
+	errs := make([]error, len(keys))
+
+	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
+	defer cancel()
+
+	leases := make(map[clientv3.LeaseID]time.Duration)
+	for start := 0; start < len(keys); start += maxTxnOps {
+		batch := keys[start:min(start+maxTxnOps, len(keys))]
+		routes, err := rt.getMany(ctx, batch)
+		for i, key := range batch {
+			if err != nil {
+				errs[start+i] = wrapErr(err, "LoadManyTTL", "key", key)
+				continue
+			}
+			if routes[i] == nil {
+				errs[start+i] = notFound("LoadManyTTL", "key", key)
+				continue
+			}
+
+			ttl, ok := leases[routes[i].lease]
+			if !ok {
+				var lerr error
+				if ttl, lerr = rt.leaseTTL(ctx, routes[i].lease); lerr != nil {
+					errs[start+i] = wrapErr(lerr, "LoadManyTTL", "key", key)
+					continue
+				}
+				leases[routes[i].lease] = ttl
+			}
+			values[start+i], ttls[start+i] = routes[i].value, ttl
+		}
+	}
+	return values, ttls, errs
+}
+
+// leaseTTL returns the remaining ttl of the lease, 0 for NoLease
+func (rt *RouteTable) leaseTTL(ctx context.Context, lease clientv3.LeaseID) (time.Duration, error) {
+	if lease == clientv3.NoLease {
+		return 0, nil
+	}
+
+	resp, err := rt.cli.TimeToLive(ctx, lease)
+	if err != nil {
+		return 0, err
+	}
+	// the ttl is -1 if the lease is expired, the route is about to be deleted with it
+	if resp.TTL < 0 {
+		return 0, errors.Errorf("lease %x expired", int64(lease))
+	}
+	return time.Duration(resp.TTL) * time.Second, nil
+}
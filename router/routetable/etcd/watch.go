@@ -0,0 +1,85 @@
+package etcd
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/pkg/errors"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const (
+	watchBufferSize = 1024
+)
+
+// Watch watches the keys with the prefix by the etcd watch.
+// The etcd server doesn't tell the expired keys from the deleted ones, so the expirations are reported as deletions,
+// and the ttl changes which keep the value are not reported.
+// The failure of the watch, such as the compaction of the revisions not received yet or the loss of the leader,
+// is delivered as the EventError event before the channel is closed.
+func (rt *RouteTable) Watch(ctx context.Context, prefix string) (<-chan routetable.DataEvent, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	wch := rt.cli.Watch(clientv3.WithRequireLeader(ctx), rt.routeKey(prefix), clientv3.WithPrefix(), clientv3.WithPrevKV())
+
+	ch := make(chan routetable.DataEvent, watchBufferSize)
+	go func() {
+		defer close(ch)
+		defer cancel()
+
+		fail := func(err error) {
+			log.Errorf("%s watch failed. prefix=%s err=%+v", errPrefix, prefix, err)
+			select {
+			case ch <- routetable.DataEvent{Kind: routetable.EventError, Err: err}:
+			case <-ctx.Done():
+			}
+		}
+
+		for resp := range wch {
+			if err := resp.Err(); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				fail(wrapErr(err, "Watch", "prefix", prefix, "compactRevision", resp.CompactRevision))
+				return
+			}
+			for _, e := range resp.Events {
+				ev, ok := rt.toDataEvent(e)
+				if !ok {
+					continue
+				}
+				select {
+				case ch <- ev:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+		// the watch channel is closed without an error when the client is closed
+		if ctx.Err() == nil {
+			fail(wrapErr(errors.New("the watch channel is closed"), "Watch", "prefix", prefix))
+		}
+	}()
+	return ch, nil
+}
+
+func (rt *RouteTable) toDataEvent(e *clientv3.Event) (routetable.DataEvent, bool) {
+	ev := routetable.DataEvent{Key: strings.TrimPrefix(string(e.Kv.Key), rt.routeKey(""))}
+	if e.PrevKv != nil {
+		ev.Old = decode(e.PrevKv).value
+	}
+
+	if e.Type == mvccpb.DELETE {
+		ev.Kind = routetable.EventDelete
+		return ev, true
+	}
+	// the refresh moves the route to another lease and keeps the value with its generation
+	if e.PrevKv != nil && e.PrevKv.Lease != e.Kv.Lease && string(e.PrevKv.Value) == string(e.Kv.Value) {
+		return ev, false
+	}
+	ev.Kind = routetable.EventSet
+	ev.New = decode(e.Kv).value
+	return ev, true
+}
@@ -0,0 +1,32 @@
+package routetable
+
+// EventKind is the kind of the route table change
+type EventKind string
+
+const (
+	EventSet    EventKind = "set"
+	EventExpire EventKind = "expire"
+	EventDelete EventKind = "delete"
+	// EventError is the last event before the channel is closed by the failure of the watch, such as the compaction of etcd.
+	// The changes after the failure are lost, so the watcher should reload the routes and watch again.
+	EventError EventKind = "error"
+)
+
+// Event is the change of a route, the OldAddr may be empty if the backend can't provide it
+type Event struct {
+	OID     int64  // the int64 oid, 0 if the oid is not int64
+	Object  string // the string oid, see ObjectRouteTable
+	Kind    EventKind
+	OldAddr string
+	NewAddr string
+	Err     error // the failure of the watch, only for EventError
+}
+
+// DataEvent is the change of a key in RouteTableData
+type DataEvent struct {
+	Key  string    `json:"k"`
+	Kind EventKind `json:"t"`
+	Old  string    `json:"o,omitempty"`
+	New  string    `json:"n,omitempty"`
+	Err  error     `json:"-"` // the failure of the watch, only for EventError
+}
@@ -0,0 +1,592 @@
+package instrument
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	verrors "github.com/vulcan-frame/vulcan-pkg-app/errors"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentationName = "github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+
+	ResultHit      = "hit"      // the route is found
+	ResultMiss     = "miss"     // the route is not found
+	ResultOK       = "ok"       // the write is done
+	ResultConflict = "conflict" // the conditional write is rejected because the route is set by others
+	ResultError    = "error"
+)
+
+var (
+	attrOp     = attribute.Key("routetable.op")
+	attrColor  = attribute.Key("routetable.color")
+	attrResult = attribute.Key("routetable.result")
+	attrOID    = attribute.Key("routetable.oid")
+	attrCount  = attribute.Key("routetable.count")
+)
+
+var (
+	_ routetable.ExtendedRouteTable = (*RouteTable)(nil)
+	_ routetable.ObjectRouteTable   = (*RouteTable)(nil)
+	_ routetable.Ranger             = (*RouteTable)(nil)
+	_ routetable.TTLReader          = (*RouteTable)(nil)
+	_ routetable.Exporter           = (*RouteTable)(nil)
+	_ routetable.Importer           = (*RouteTable)(nil)
+	_ routetable.SIDMerger          = (*RouteTable)(nil)
+	_ routetable.KeyMigrator        = (*RouteTable)(nil)
+	_ routetable.ScopeMigrator      = (*RouteTable)(nil)
+)
+
+type Option func(*options)
+
+type options struct {
+	meterProvider  metric.MeterProvider
+	tracerProvider trace.TracerProvider
+}
+
+// WithMeterProvider sets the meter provider, the default is the global one
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(o *options) {
+		o.meterProvider = mp
+	}
+}
+
+// WithTracerProvider sets the tracer provider, the default is the global one
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(o *options) {
+		o.tracerProvider = tp
+	}
+}
+
+// RouteTable is a RouteTable decorator which records the OpenTelemetry metrics and spans of the operations.
+// The metrics are labeled by op, color and result. The spans are the children of the span in the ctx,
+// which is the RPC span when called by the balancer.
+// It implements ExtendedRouteTable and ObjectRouteTable, the decorated RouteTable which doesn't implement them
+// is adapted by routetable.Extended and routetable.Objects. The methods of BaseRouteTable out of the interfaces,
+// such as Range and Export, are forwarded too, they fail with ErrRouteTableUnsupported if the decorated RouteTable
+// doesn't implement them.
+type RouteTable struct {
+	base routetable.RouteTable
+	ext  routetable.ExtendedRouteTable
+	obj  routetable.ObjectRouteTable
+
+	tracer   trace.Tracer
+	ops      metric.Int64Counter
+	duration metric.Float64Histogram
+}
+
+func NewRouteTable(rt routetable.RouteTable, opts ...Option) (*RouteTable, error) {
+	o := options{
+		meterProvider:  otel.GetMeterProvider(),
+		tracerProvider: otel.GetTracerProvider(),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	meter := o.meterProvider.Meter(instrumentationName)
+	ops, err := meter.Int64Counter("routetable.operations",
+		metric.WithDescription("The count of the route table operations, the batch operations are counted per key"),
+		metric.WithUnit("{operation}"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "create routetable.operations counter failed")
+	}
+	duration, err := meter.Float64Histogram("routetable.operation.duration",
+		metric.WithDescription("The duration of the route table operations"),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "create routetable.operation.duration histogram failed")
+	}
+
+	return &RouteTable{
+		base:     rt,
+		ext:      routetable.Extended(rt),
+		obj:      routetable.Objects(rt),
+		tracer:   o.tracerProvider.Tracer(instrumentationName),
+		ops:      ops,
+		duration: duration,
+	}, nil
+}
+
+// operation is an observed call of the route table
+type operation struct {
+	rt    *RouteTable
+	ctx   context.Context
+	span  trace.Span
+	op    string
+	color string
+	start time.Time
+}
+
+func (rt *RouteTable) start(ctx context.Context, op string, color string, attrs ...attribute.KeyValue) (context.Context, *operation) {
+	attrs = append(attrs, attrOp.String(op), attrColor.String(color))
+	ctx, span := rt.tracer.Start(ctx, "routetable."+op, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	return ctx, &operation{rt: rt, ctx: ctx, span: span, op: op, color: color, start: time.Now()}
+}
+
+func (o *operation) end(result string, err error) {
+	o.record(result, 1)
+	o.finish(result, err)
+}
+
+// endMany records the per key results of the batch operation, the call result is error if any key failed
+func (o *operation) endMany(errs []error, classify func(error) string) {
+	counts := make(map[string]int64, 2)
+	var firstErr error
+	for _, err := range errs {
+		result := classify(err)
+		counts[result]++
+		if result == ResultError && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for result, n := range counts {
+		o.record(result, n)
+	}
+
+	o.span.SetAttributes(attrCount.Int(len(errs)))
+	result := ResultOK
+	if firstErr != nil {
+		result = ResultError
+	}
+	o.finish(result, firstErr)
+}
+
+func (o *operation) record(result string, n int64) {
+	o.rt.ops.Add(o.ctx, n, metric.WithAttributes(attrOp.String(o.op), attrColor.String(o.color), attrResult.String(result)))
+}
+
+func (o *operation) finish(result string, err error) {
+	o.rt.duration.Record(o.ctx, time.Since(o.start).Seconds(),
+		metric.WithAttributes(attrOp.String(o.op), attrColor.String(o.color), attrResult.String(result)))
+
+	o.span.SetAttributes(attrResult.String(result))
+	if result == ResultError {
+		o.span.RecordError(err)
+		o.span.SetStatus(codes.Error, err.Error())
+	}
+	o.span.End()
+}
+
+func readResult(err error) string {
+	switch {
+	case err == nil:
+		return ResultHit
+	case errors.Is(err, verrors.ErrRouteTableNotFound):
+		return ResultMiss
+	default:
+		return ResultError
+	}
+}
+
+func writeResult(err error) string {
+	switch {
+	case err == nil:
+		return ResultOK
+	case errors.Is(err, verrors.ErrRouteTableNotFound):
+		// GetSet stores the value even if the old one not exists
+		return ResultMiss
+	default:
+		return ResultError
+	}
+}
+
+func conditionalResult(ok bool, err error) string {
+	switch {
+	case err != nil:
+		return ResultError
+	case !ok:
+		return ResultConflict
+	default:
+		return ResultOK
+	}
+}
+
+func (rt *RouteTable) Load(ctx context.Context, color string, oid int64) (string, error) {
+	ctx, o := rt.start(ctx, "Load", color, attrOID.Int64(oid))
+	addr, err := rt.base.Load(ctx, color, oid)
+	o.end(readResult(err), err)
+	return addr, err
+}
+
+func (rt *RouteTable) LoadGen(ctx context.Context, color string, oid int64) (string, int64, error) {
+	ctx, o := rt.start(ctx, "LoadGen", color, attrOID.Int64(oid))
+	addr, gen, err := rt.ext.LoadGen(ctx, color, oid)
+	o.end(readResult(err), err)
+	return addr, gen, err
+}
+
+func (rt *RouteTable) LoadEntry(ctx context.Context, color string, oid int64) (routetable.RouteEntry, error) {
+	ctx, o := rt.start(ctx, "LoadEntry", color, attrOID.Int64(oid))
+	entry, err := rt.ext.LoadEntry(ctx, color, oid)
+	o.end(readResult(err), err)
+	return entry, err
+}
+
+func (rt *RouteTable) LoadAndExpire(ctx context.Context, color string, oid int64) (string, error) {
+	ctx, o := rt.start(ctx, "LoadAndExpire", color, attrOID.Int64(oid))
+	addr, err := rt.base.LoadAndExpire(ctx, color, oid)
+	o.end(readResult(err), err)
+	return addr, err
+}
+
+func (rt *RouteTable) LoadMany(ctx context.Context, color string, oids []int64) ([]string, []error) {
+	ctx, o := rt.start(ctx, "LoadMany", color)
+	addrs, errs := rt.ext.LoadMany(ctx, color, oids)
+	o.endMany(errs, readResult)
+	return addrs, errs
+}
+
+func (rt *RouteTable) Store(ctx context.Context, color string, oid int64, addr string) error {
+	ctx, o := rt.start(ctx, "Store", color, attrOID.Int64(oid))
+	err := rt.base.Store(ctx, color, oid, addr)
+	o.end(writeResult(err), err)
+	return err
+}
+
+func (rt *RouteTable) StoreEntry(ctx context.Context, color string, oid int64, entry routetable.RouteEntry) error {
+	ctx, o := rt.start(ctx, "StoreEntry", color, attrOID.Int64(oid))
+	err := rt.ext.StoreEntry(ctx, color, oid, entry)
+	o.end(writeResult(err), err)
+	return err
+}
+
+func (rt *RouteTable) StoreMany(ctx context.Context, color string, oids []int64, addrs []string) []error {
+	ctx, o := rt.start(ctx, "StoreMany", color)
+	errs := rt.ext.StoreMany(ctx, color, oids, addrs)
+	o.endMany(errs, writeResult)
+	return errs
+}
+
+func (rt *RouteTable) GetSet(ctx context.Context, color string, oid int64, addr string) (string, error) {
+	ctx, o := rt.start(ctx, "GetSet", color, attrOID.Int64(oid))
+	old, err := rt.base.GetSet(ctx, color, oid, addr)
+	o.end(writeResult(err), err)
+	return old, err
+}
+
+func (rt *RouteTable) GetSetGen(ctx context.Context, color string, oid int64, addr string) (string, int64, error) {
+	ctx, o := rt.start(ctx, "GetSetGen", color, attrOID.Int64(oid))
+	old, gen, err := rt.ext.GetSetGen(ctx, color, oid, addr)
+	o.end(writeResult(err), err)
+	return old, gen, err
+}
+
+func (rt *RouteTable) GetSetEntry(ctx context.Context, color string, oid int64, entry routetable.RouteEntry) (routetable.RouteEntry, error) {
+	ctx, o := rt.start(ctx, "GetSetEntry", color, attrOID.Int64(oid))
+	old, err := rt.ext.GetSetEntry(ctx, color, oid, entry)
+	o.end(writeResult(err), err)
+	return old, err
+}
+
+func (rt *RouteTable) SetNx(ctx context.Context, color string, oid int64, addr string) (bool, string, error) {
+	ctx, o := rt.start(ctx, "SetNx", color, attrOID.Int64(oid))
+	ok, result, err := rt.base.SetNx(ctx, color, oid, addr)
+	o.end(conditionalResult(ok, err), err)
+	return ok, result, err
+}
+
+func (rt *RouteTable) SetNxGen(ctx context.Context, color string, oid int64, addr string) (bool, string, int64, error) {
+	ctx, o := rt.start(ctx, "SetNxGen", color, attrOID.Int64(oid))
+	ok, result, gen, err := rt.ext.SetNxGen(ctx, color, oid, addr)
+	o.end(conditionalResult(ok, err), err)
+	return ok, result, gen, err
+}
+
+func (rt *RouteTable) SetNxEntry(ctx context.Context, color string, oid int64, entry routetable.RouteEntry) (bool, routetable.RouteEntry, error) {
+	ctx, o := rt.start(ctx, "SetNxEntry", color, attrOID.Int64(oid))
+	ok, result, err := rt.ext.SetNxEntry(ctx, color, oid, entry)
+	o.end(conditionalResult(ok, err), err)
+	return ok, result, err
+}
+
+func (rt *RouteTable) CompareAndSwap(ctx context.Context, color string, oid int64, expectedGen int64, addr string) (bool, int64, error) {
+	ctx, o := rt.start(ctx, "CompareAndSwap", color, attrOID.Int64(oid))
+	ok, gen, err := rt.ext.CompareAndSwap(ctx, color, oid, expectedGen, addr)
+	o.end(conditionalResult(ok, err), err)
+	return ok, gen, err
+}
+
+func (rt *RouteTable) DelDelay(ctx context.Context, color string, oid int64, delay time.Duration) error {
+	ctx, o := rt.start(ctx, "DelDelay", color, attrOID.Int64(oid))
+	err := rt.base.DelDelay(ctx, color, oid, delay)
+	o.end(writeResult(err), err)
+	return err
+}
+
+func (rt *RouteTable) DelIfSame(ctx context.Context, color string, oid int64, value string) error {
+	ctx, o := rt.start(ctx, "DelIfSame", color, attrOID.Int64(oid))
+	err := rt.base.DelIfSame(ctx, color, oid, value)
+	o.end(writeResult(err), err)
+	return err
+}
+
+func (rt *RouteTable) Del(ctx context.Context, color string, oid int64) error {
+	ctx, o := rt.start(ctx, "Del", color, attrOID.Int64(oid))
+	err := rt.base.Del(ctx, color, oid)
+	o.end(writeResult(err), err)
+	return err
+}
+
+func (rt *RouteTable) DelMany(ctx context.Context, color string, oids []int64) []error {
+	ctx, o := rt.start(ctx, "DelMany", color)
+	errs := rt.ext.DelMany(ctx, color, oids)
+	o.endMany(errs, writeResult)
+	return errs
+}
+
+func (rt *RouteTable) Renew(ctx context.Context, color string, oids []int64, addr string) []error {
+	ctx, o := rt.start(ctx, "Renew", color)
+	errs := rt.ext.Renew(ctx, color, oids, addr)
+	o.endMany(errs, writeResult)
+	return errs
+}
+
+func (rt *RouteTable) ListByAddr(ctx context.Context, color string, addr string, cursor string, count int64) ([]int64, string, error) {
+	ctx, o := rt.start(ctx, "ListByAddr", color)
+	oids, next, err := rt.ext.ListByAddr(ctx, color, addr, cursor, count)
+	o.end(readResult(err), err)
+	return oids, next, err
+}
+
+// Watch records the subscription only, the events are not observed
+func (rt *RouteTable) Watch(ctx context.Context, color string) (<-chan routetable.Event, error) {
+	_, o := rt.start(ctx, "Watch", color)
+	events, err := rt.ext.Watch(ctx, color)
+	o.end(writeResult(err), err)
+	return events, err
+}
+
+func (rt *RouteTable) Draining(ctx context.Context, color string, addrs []string) ([]bool, error) {
+	ctx, o := rt.start(ctx, "Draining", color)
+	draining, err := rt.ext.Draining(ctx, color, addrs)
+	o.end(readResult(err), err)
+	return draining, err
+}
+
+func (rt *RouteTable) SetDraining(ctx context.Context, color string, addr string, draining bool) error {
+	ctx, o := rt.start(ctx, "SetDraining", color)
+	err := rt.ext.SetDraining(ctx, color, addr, draining)
+	o.end(writeResult(err), err)
+	return err
+}
+
+func (rt *RouteTable) LoadObject(ctx context.Context, color string, oid string) (string, error) {
+	ctx, o := rt.start(ctx, "Load", color, attrOID.String(oid))
+	addr, err := rt.obj.LoadObject(ctx, color, oid)
+	o.end(readResult(err), err)
+	return addr, err
+}
+
+func (rt *RouteTable) LoadAndExpireObject(ctx context.Context, color string, oid string) (string, error) {
+	ctx, o := rt.start(ctx, "LoadAndExpire", color, attrOID.String(oid))
+	addr, err := rt.obj.LoadAndExpireObject(ctx, color, oid)
+	o.end(readResult(err), err)
+	return addr, err
+}
+
+func (rt *RouteTable) LoadGenObject(ctx context.Context, color string, oid string) (string, int64, error) {
+	ctx, o := rt.start(ctx, "LoadGen", color, attrOID.String(oid))
+	addr, gen, err := rt.obj.LoadGenObject(ctx, color, oid)
+	o.end(readResult(err), err)
+	return addr, gen, err
+}
+
+func (rt *RouteTable) LoadEntryObject(ctx context.Context, color string, oid string) (routetable.RouteEntry, error) {
+	ctx, o := rt.start(ctx, "LoadEntry", color, attrOID.String(oid))
+	entry, err := rt.obj.LoadEntryObject(ctx, color, oid)
+	o.end(readResult(err), err)
+	return entry, err
+}
+
+func (rt *RouteTable) GetSetGenObject(ctx context.Context, color string, oid string, addr string) (string, int64, error) {
+	ctx, o := rt.start(ctx, "GetSetGen", color, attrOID.String(oid))
+	old, gen, err := rt.obj.GetSetGenObject(ctx, color, oid, addr)
+	o.end(writeResult(err), err)
+	return old, gen, err
+}
+
+func (rt *RouteTable) StoreObject(ctx context.Context, color string, oid string, addr string) error {
+	ctx, o := rt.start(ctx, "Store", color, attrOID.String(oid))
+	err := rt.obj.StoreObject(ctx, color, oid, addr)
+	o.end(writeResult(err), err)
+	return err
+}
+
+func (rt *RouteTable) StoreEntryObject(ctx context.Context, color string, oid string, entry routetable.RouteEntry) error {
+	ctx, o := rt.start(ctx, "StoreEntry", color, attrOID.String(oid))
+	err := rt.obj.StoreEntryObject(ctx, color, oid, entry)
+	o.end(writeResult(err), err)
+	return err
+}
+
+func (rt *RouteTable) GetSetEntryObject(ctx context.Context, color string, oid string, entry routetable.RouteEntry) (routetable.RouteEntry, error) {
+	ctx, o := rt.start(ctx, "GetSetEntry", color, attrOID.String(oid))
+	old, err := rt.obj.GetSetEntryObject(ctx, color, oid, entry)
+	o.end(writeResult(err), err)
+	return old, err
+}
+
+func (rt *RouteTable) SetNxEntryObject(ctx context.Context, color string, oid string, entry routetable.RouteEntry) (bool, routetable.RouteEntry, error) {
+	ctx, o := rt.start(ctx, "SetNxEntry", color, attrOID.String(oid))
+	ok, result, err := rt.obj.SetNxEntryObject(ctx, color, oid, entry)
+	o.end(conditionalResult(ok, err), err)
+	return ok, result, err
+}
+
+func (rt *RouteTable) CompareAndSwapObject(ctx context.Context, color string, oid string, expectedGen int64, addr string) (bool, int64, error) {
+	ctx, o := rt.start(ctx, "CompareAndSwap", color, attrOID.String(oid))
+	ok, gen, err := rt.obj.CompareAndSwapObject(ctx, color, oid, expectedGen, addr)
+	o.end(conditionalResult(ok, err), err)
+	return ok, gen, err
+}
+
+func (rt *RouteTable) DelDelayObject(ctx context.Context, color string, oid string, delay time.Duration) error {
+	ctx, o := rt.start(ctx, "DelDelay", color, attrOID.String(oid))
+	err := rt.obj.DelDelayObject(ctx, color, oid, delay)
+	o.end(writeResult(err), err)
+	return err
+}
+
+func (rt *RouteTable) DelIfSameObject(ctx context.Context, color string, oid string, value string) error {
+	ctx, o := rt.start(ctx, "DelIfSame", color, attrOID.String(oid))
+	err := rt.obj.DelIfSameObject(ctx, color, oid, value)
+	o.end(writeResult(err), err)
+	return err
+}
+
+func (rt *RouteTable) DelObject(ctx context.Context, color string, oid string) error {
+	ctx, o := rt.start(ctx, "Del", color, attrOID.String(oid))
+	err := rt.obj.DelObject(ctx, color, oid)
+	o.end(writeResult(err), err)
+	return err
+}
+
+func (rt *RouteTable) StoreManyObjects(ctx context.Context, color string, oids []string, addrs []string) []error {
+	ctx, o := rt.start(ctx, "StoreMany", color)
+	errs := rt.obj.StoreManyObjects(ctx, color, oids, addrs)
+	o.endMany(errs, writeResult)
+	return errs
+}
+
+func (rt *RouteTable) DelManyObjects(ctx context.Context, color string, oids []string) []error {
+	ctx, o := rt.start(ctx, "DelMany", color)
+	errs := rt.obj.DelManyObjects(ctx, color, oids)
+	o.endMany(errs, writeResult)
+	return errs
+}
+
+func (rt *RouteTable) RenewObjects(ctx context.Context, color string, oids []string, addr string) []error {
+	ctx, o := rt.start(ctx, "Renew", color)
+	errs := rt.obj.RenewObjects(ctx, color, oids, addr)
+	o.endMany(errs, writeResult)
+	return errs
+}
+
+func (rt *RouteTable) ListObjectsByAddr(ctx context.Context, color string, addr string, cursor string, count int64) ([]string, string, error) {
+	ctx, o := rt.start(ctx, "ListByAddr", color)
+	oids, next, err := rt.obj.ListObjectsByAddr(ctx, color, addr, cursor, count)
+	o.end(readResult(err), err)
+	return oids, next, err
+}
+
+// the methods of BaseRouteTable out of the RouteTable interfaces
+
+func unsupported(operation string) error {
+	return errors.Wrapf(verrors.ErrRouteTableUnsupported, "the route table does not support %s", operation)
+}
+
+func (rt *RouteTable) Range(ctx context.Context, color string, fn func(oids []string, entries []routetable.RouteEntry) error) error {
+	r, ok := rt.base.(routetable.Ranger)
+	if !ok {
+		return unsupported("Range")
+	}
+	ctx, o := rt.start(ctx, "Range", color)
+	err := r.Range(ctx, color, fn)
+	o.end(readResult(err), err)
+	return err
+}
+
+func (rt *RouteTable) TTL(ctx context.Context, color string, oid int64) (time.Duration, error) {
+	r, ok := rt.base.(routetable.TTLReader)
+	if !ok {
+		return 0, unsupported("TTL")
+	}
+	ctx, o := rt.start(ctx, "TTL", color, attrOID.Int64(oid))
+	ttl, err := r.TTL(ctx, color, oid)
+	o.end(readResult(err), err)
+	return ttl, err
+}
+
+func (rt *RouteTable) TTLObject(ctx context.Context, color string, oid string) (time.Duration, error) {
+	r, ok := rt.base.(routetable.TTLReader)
+	if !ok {
+		return 0, unsupported("TTL")
+	}
+	ctx, o := rt.start(ctx, "TTL", color, attrOID.String(oid))
+	ttl, err := r.TTLObject(ctx, color, oid)
+	o.end(readResult(err), err)
+	return ttl, err
+}
+
+func (rt *RouteTable) Export(ctx context.Context, color string, w routetable.SnapshotWriter) (int, error) {
+	r, ok := rt.base.(routetable.Exporter)
+	if !ok {
+		return 0, unsupported("Export")
+	}
+	ctx, o := rt.start(ctx, "Export", color)
+	n, err := r.Export(ctx, color, w)
+	o.end(readResult(err), err)
+	return n, err
+}
+
+func (rt *RouteTable) Import(ctx context.Context, color string, rd routetable.SnapshotReader, mode routetable.ImportMode) (routetable.ImportResult, error) {
+	r, ok := rt.base.(routetable.Importer)
+	if !ok {
+		return routetable.ImportResult{}, unsupported("Import")
+	}
+	ctx, o := rt.start(ctx, "Import", color)
+	result, err := r.Import(ctx, color, rd, mode)
+	o.end(writeResult(err), err)
+	return result, err
+}
+
+func (rt *RouteTable) MergeSID(ctx context.Context, color string, from, to int64) (routetable.MergeResult, error) {
+	r, ok := rt.base.(routetable.SIDMerger)
+	if !ok {
+		return routetable.MergeResult{}, unsupported("MergeSID")
+	}
+	ctx, o := rt.start(ctx, "MergeSID", color)
+	result, err := r.MergeSID(ctx, color, from, to)
+	o.end(writeResult(err), err)
+	return result, err
+}
+
+func (rt *RouteTable) MigrateKeys(ctx context.Context, color string, from, to routetable.KeyFormat) (int, error) {
+	r, ok := rt.base.(routetable.KeyMigrator)
+	if !ok {
+		return 0, unsupported("MigrateKeys")
+	}
+	ctx, o := rt.start(ctx, "MigrateKeys", color)
+	n, err := r.MigrateKeys(ctx, color, from, to)
+	o.end(writeResult(err), err)
+	return n, err
+}
+
+func (rt *RouteTable) MigrateToScope(ctx context.Context, color string) (int, error) {
+	r, ok := rt.base.(routetable.ScopeMigrator)
+	if !ok {
+		return 0, unsupported("MigrateToScope")
+	}
+	ctx, o := rt.start(ctx, "MigrateToScope", color)
+	n, err := r.MigrateToScope(ctx, color)
+	o.end(writeResult(err), err)
+	return n, err
+}
@@ -0,0 +1,110 @@
+package instrument
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable/memory"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRouteTable_Metrics(t *testing.T) {
+	ctx := context.Background()
+	reader := sdkmetric.NewManualReader()
+	spans := tracetest.NewSpanRecorder()
+
+	data := memory.NewRouteTable()
+	defer data.Close()
+	rt, err := NewRouteTable(routetable.New(data, "test"),
+		WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))),
+		WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))))
+	require.NoError(t, err)
+
+	_, err = rt.Load(ctx, "red", 1)
+	assert.Error(t, err)
+	ok, _, err := rt.SetNx(ctx, "red", 1, "a")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, _, err = rt.SetNx(ctx, "red", 1, "b")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	_, err = rt.Load(ctx, "red", 1)
+	require.NoError(t, err)
+	rt.LoadMany(ctx, "red", []int64{1, 2, 3})
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(ctx, &rm))
+	counts := collectCounts(t, rm)
+
+	assert.Equal(t, int64(1), counts["Load/miss"])
+	assert.Equal(t, int64(1), counts["Load/hit"])
+	assert.Equal(t, int64(1), counts["SetNx/ok"])
+	assert.Equal(t, int64(1), counts["SetNx/conflict"])
+	assert.Equal(t, int64(1), counts["LoadMany/hit"])
+	assert.Equal(t, int64(2), counts["LoadMany/miss"])
+	assert.Len(t, spans.Ended(), 5)
+}
+
+func TestRouteTable_Forward(t *testing.T) {
+	ctx := context.Background()
+	reader := sdkmetric.NewManualReader()
+
+	data := memory.NewRouteTable()
+	defer data.Close()
+	rt, err := NewRouteTable(routetable.New(data, "test"),
+		WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))))
+	require.NoError(t, err)
+	require.NoError(t, rt.Store(ctx, "red", 1, "a"))
+
+	// the methods of BaseRouteTable are found by the type assertions of the callers, such as the reconciler
+	var r routetable.RouteTable = rt
+	rg, ok := r.(routetable.Ranger)
+	require.True(t, ok)
+	var ranged []string
+	require.NoError(t, rg.Range(ctx, "red", func(oids []string, _ []routetable.RouteEntry) error {
+		ranged = append(ranged, oids...)
+		return nil
+	}))
+	assert.Equal(t, []string{"1"}, ranged)
+
+	ttl, err := rt.TTL(ctx, "red", 1)
+	require.NoError(t, err)
+	assert.Positive(t, ttl)
+	_, _, err = rt.ListByAddr(ctx, "red", "a", "", 10)
+	require.NoError(t, err)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(ctx, &rm))
+	counts := collectCounts(t, rm)
+	assert.Equal(t, int64(1), counts["Range/hit"])
+	assert.Equal(t, int64(1), counts["TTL/hit"])
+	assert.Equal(t, int64(1), counts["ListByAddr/hit"], "ListByAddr is recorded as a read")
+}
+
+func collectCounts(t *testing.T, rm metricdata.ResourceMetrics) map[string]int64 {
+	counts := make(map[string]int64)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "routetable.operations" {
+				continue
+			}
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			require.True(t, ok)
+			for _, dp := range sum.DataPoints {
+				op, _ := dp.Attributes.Value(attrOp)
+				result, _ := dp.Attributes.Value(attrResult)
+				color, _ := dp.Attributes.Value(attrColor)
+				assert.Equal(t, attribute.StringValue("red"), color)
+				counts[op.AsString()+"/"+result.AsString()] += dp.Value
+			}
+		}
+	}
+	return counts
+}
@@ -0,0 +1,101 @@
+package routetable
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// KeyFormat generates the keys of the routes in RouteTableData.
+// The oid is an opaque string, the int64 oids are formatted in decimal.
+type KeyFormat interface {
+	// Key returns the key of the oid
+	Key(name, color string, oid string) string
+	// Prefix returns the common prefix of the keys in the color
+	Prefix(name, color string) string
+	// Parse parses the oid from the key with the prefix
+	Parse(prefix, key string) (oid string, ok bool)
+}
+
+var (
+	// DefaultKeyFormat is r_{name}_{{color}}_{{oid}}. Redis Cluster only honours the first hash tag,
+	// so all the keys of a color are in the same slot.
+	DefaultKeyFormat KeyFormat = &bracedKeyFormat{prefix: "r_%s_{%s}_{"}
+	// ClusterKeyFormat is r_{name}_{color}_{{oid}}, the hash tag is the oid, so the keys are spread over the slots of Redis Cluster.
+	// The string oid should not contain "}", otherwise only the part before it is hashed.
+	ClusterKeyFormat KeyFormat = &bracedKeyFormat{prefix: "r_%s_%s_{"}
+)
+
+// bracedKeyFormat is the format of prefix + oid + "}"
+type bracedKeyFormat struct {
+	prefix string
+}
+
+func (f *bracedKeyFormat) Key(name, color string, oid string) string {
+	return f.Prefix(name, color) + oid + "}"
+}
+
+func (f *bracedKeyFormat) Prefix(name, color string) string {
+	return fmt.Sprintf(f.prefix, name, color)
+}
+
+func (f *bracedKeyFormat) Parse(prefix, key string) (string, bool) {
+	if !strings.HasPrefix(key, prefix) || !strings.HasSuffix(key, "}") || len(key) == len(prefix)+1 {
+		return "", false
+	}
+	return key[len(prefix) : len(key)-1], true
+}
+
+// KeyFunc adapts the key function of the int64 oids to KeyFormat, the keys are the oids between the prefix and the suffix
+// which are found by the keys of the oids 0 and 1. The string oids are formatted between the same prefix and suffix.
+// On Redis Cluster the key should have a "{...}" hash tag or no "}" at all, so the generation key of the redis
+// backend is in the same slot as the route key. The keys with "}" but no hash tag fail with CROSSSLOT.
+type KeyFunc func(name, color string, oid int64) string
+
+func (f KeyFunc) Key(name, color string, oid string) string {
+	if id, ok := parseOID(oid); ok && formatOID(id) == oid {
+		return f(name, color, id)
+	}
+	prefix, suffix := f.affixes(name, color)
+	return prefix + oid + suffix
+}
+
+func (f KeyFunc) Prefix(name, color string) string {
+	prefix, _ := f.affixes(name, color)
+	return prefix
+}
+
+// Parse assumes the suffix doesn't depend on the name and the color
+func (f KeyFunc) Parse(prefix, key string) (string, bool) {
+	_, suffix := f.affixes("name", "color")
+	if !strings.HasPrefix(key, prefix) || !strings.HasSuffix(key, suffix) || len(key) <= len(prefix)+len(suffix) {
+		return "", false
+	}
+	return key[len(prefix) : len(key)-len(suffix)], true
+}
+
+func (f KeyFunc) affixes(name, color string) (prefix, suffix string) {
+	k0, k1 := f(name, color, 0), f(name, color, 1)
+	n := 0
+	for n < len(k0) && n < len(k1) && k0[n] == k1[n] {
+		n++
+	}
+	m := 0
+	for m < len(k0)-n && m < len(k1)-n && k0[len(k0)-1-m] == k1[len(k1)-1-m] {
+		m++
+	}
+	return k0[:n], k0[len(k0)-m:]
+}
+
+func formatOID(oid int64) string {
+	return strconv.FormatInt(oid, 10)
+}
+
+// parseOID parses the int64 oid, the routes of the other oids are skipped by the int64 API
+func parseOID(oid string) (int64, bool) {
+	id, err := strconv.ParseInt(oid, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
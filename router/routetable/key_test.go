@@ -0,0 +1,102 @@
+package routetable_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	vctx "github.com/vulcan-frame/vulcan-pkg-app/context"
+	verrors "github.com/vulcan-frame/vulcan-pkg-app/errors"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable/memory"
+)
+
+func TestKeyFormat(t *testing.T) {
+	cases := []struct {
+		format routetable.KeyFormat
+		key    string
+	}{
+		{routetable.DefaultKeyFormat, "r_player_{blue}_{123}"},
+		{routetable.ClusterKeyFormat, "r_player_blue_{123}"},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.key, c.format.Key("player", "blue", "123"))
+
+		oid, ok := c.format.Parse(c.format.Prefix("player", "blue"), c.key)
+		assert.True(t, ok)
+		assert.Equal(t, "123", oid)
+
+		_, ok = c.format.Parse(c.format.Prefix("player", "red"), c.key)
+		assert.False(t, ok)
+	}
+}
+
+func TestObjectRouteTable(t *testing.T) {
+	ctx := context.Background()
+	data := memory.NewRouteTable()
+	defer data.Close()
+	rt := routetable.New(data, "guild")
+
+	// the int64 oid is the same route as its decimal string
+	require.NoError(t, rt.Store(ctx, "blue", 123, "a"))
+	addr, err := rt.LoadObject(ctx, "blue", "123")
+	require.NoError(t, err)
+	assert.Equal(t, "a", addr)
+
+	oid := vctx.ObjectKey{Module: "guild", ID: "6f1c2d3e-uuid"}.String()
+	ok, _, err := rt.SetNxEntryObject(ctx, "blue", oid, routetable.RouteEntry{Addr: "a", Node: "n1"})
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	entry, err := rt.LoadEntryObject(ctx, "blue", oid)
+	require.NoError(t, err)
+	assert.Equal(t, "n1", entry.Node)
+
+	oids, next, err := rt.ListObjectsByAddr(ctx, "blue", "a", "", 100)
+	require.NoError(t, err)
+	assert.Empty(t, next)
+	assert.ElementsMatch(t, []string{"123", "guild:6f1c2d3e-uuid"}, oids)
+
+	// the int64 API skips the other oids
+	ids, _, err := rt.ListByAddr(ctx, "blue", "a", "", 100)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{123}, ids)
+
+	var ranged []string
+	require.NoError(t, routetable.New(data, "guild").Range(ctx, "blue", func(oids []string, _ []routetable.RouteEntry) error {
+		ranged = append(ranged, oids...)
+		return nil
+	}))
+	assert.ElementsMatch(t, []string{"123", "guild:6f1c2d3e-uuid"}, ranged)
+
+	require.NoError(t, rt.DelIfSameObject(ctx, "blue", oid, "a"))
+	_, err = rt.LoadObject(ctx, "blue", oid)
+	assert.ErrorIs(t, err, verrors.ErrRouteTableNotFound)
+}
+
+func TestMigrateKeys(t *testing.T) {
+	ctx := context.Background()
+	data := memory.NewRouteTable()
+	defer data.Close()
+
+	legacy := routetable.New(data, "player")
+	cluster := routetable.New(data, "player", routetable.WithKeyFormat(routetable.ClusterKeyFormat))
+
+	require.NoError(t, legacy.Store(ctx, "blue", 1, "a"))
+	require.NoError(t, legacy.Store(ctx, "blue", 2, "a"))
+	require.NoError(t, cluster.Store(ctx, "blue", 2, "b"))
+
+	migrated, err := routetable.MigrateKeys(ctx, data, "player", "blue", routetable.DefaultKeyFormat, routetable.ClusterKeyFormat, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 2, migrated)
+
+	addrs, errs := cluster.LoadMany(ctx, "blue", []int64{1, 2})
+	assert.Equal(t, []error{nil, nil}, errs)
+	assert.Equal(t, []string{"a", "b"}, addrs)
+
+	_, err = legacy.Load(ctx, "blue", 1)
+	assert.Error(t, err)
+}
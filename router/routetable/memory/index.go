@@ -0,0 +1,88 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+)
+
+const (
+	scanBatchSize = 100
+)
+
+// ListByAddr pages through the keys with the prefix routed to the addr in the key order,
+// the cursor is the last key of the previous page.
+func (rt *RouteTable) ListByAddr(ctx context.Context, addr string, prefix string, cursor string, count int64) ([]string, string, error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	all := make([]string, 0, len(rt.index[addr]))
+	for key := range rt.index[addr] {
+		if !strings.HasPrefix(key, prefix) || (cursor != "" && key <= cursor) {
+			continue
+		}
+		if _, ok := rt.getLocked(key); ok {
+			all = append(all, key)
+		}
+	}
+	sort.Strings(all)
+
+	if count <= 0 {
+		count = 10
+	}
+	if int64(len(all)) <= count {
+		return all, "", nil
+	}
+	return all[:count], all[count-1], nil
+}
+
+func (rt *RouteTable) indexLocked(it *item) {
+	addr := routetable.AddrOf(it.value)
+	keys, ok := rt.index[addr]
+	if !ok {
+		keys = make(map[string]struct{})
+		rt.index[addr] = keys
+	}
+	keys[it.key] = struct{}{}
+}
+
+func (rt *RouteTable) unindexLocked(it *item) {
+	addr := routetable.AddrOf(it.value)
+	keys, ok := rt.index[addr]
+	if !ok {
+		return
+	}
+	delete(keys, it.key)
+	if len(keys) == 0 {
+		delete(rt.index, addr)
+	}
+}
+
+// Scan iterates the snapshot of the keys with the prefix in the key order
+func (rt *RouteTable) Scan(ctx context.Context, prefix string, fn func(keys []string) error) error {
+	rt.mu.Lock()
+	all := make([]string, 0)
+	for key := range rt.items {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if _, ok := rt.getLocked(key); ok {
+			all = append(all, key)
+		}
+	}
+	rt.mu.Unlock()
+
+	sort.Strings(all)
+	for start := 0; start < len(all); start += scanBatchSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		end := min(start+scanBatchSize, len(all))
+		if err := fn(all[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func (rt *RouteTable) SetMark(ctx context.Context, key string, dur time.Duration) error {
+	if dur <= 0 {
+		return errors.Errorf("%s SetMark failed [key %s] invalid expire time %s", errPrefix, key, dur)
+	}
+
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	rt.marks[key] = time.Now().Add(dur)
+	return nil
+}
+
+func (rt *RouteTable) DelMark(ctx context.Context, key string) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	delete(rt.marks, key)
+	return nil
+}
+
+// LoadMarks returns whether the marks exist, the expired marks are removed lazily
+func (rt *RouteTable) LoadMarks(ctx context.Context, keys []string) ([]bool, error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	now := time.Now()
+	marked := make([]bool, len(keys))
+	for i, key := range keys {
+		expireAt, ok := rt.marks[key]
+		if ok && !now.Before(expireAt) {
+			delete(rt.marks, key)
+			ok = false
+		}
+		marked[i] = ok
+	}
+	return marked, nil
+}
@@ -0,0 +1,502 @@
+package memory
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	verrors "github.com/vulcan-frame/vulcan-pkg-app/errors"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+)
+
+const (
+	errPrefix = "memory routeTable"
+)
+
+var (
+	_ routetable.RouteTableData           = (*RouteTable)(nil)
+	_ routetable.GenRouteTableData        = (*RouteTable)(nil)
+	_ routetable.BatchRouteTableData      = (*RouteTable)(nil)
+	_ routetable.WatchRouteTableData      = (*RouteTable)(nil)
+	_ routetable.IndexRouteTableData      = (*RouteTable)(nil)
+	_ routetable.ScanRouteTableData       = (*RouteTable)(nil)
+	_ routetable.TTLRouteTableData        = (*RouteTable)(nil)
+	_ routetable.SnapshotRouteTableData   = (*RouteTable)(nil)
+	_ routetable.ExpireManyRouteTableData = (*RouteTable)(nil)
+	_ routetable.MarkRouteTableData       = (*RouteTable)(nil)
+)
+
+// RouteTable is an in-process RouteTableData. It follows the semantics of the redis
+// implementation, so it can be used by single-node servers and unit tests instead of a live Redis.
+type RouteTable struct {
+	mu      sync.Mutex
+	items   map[string]*item
+	expires expireHeap
+	timer   *time.Timer
+	closed  bool
+
+	watchers map[*watcher]struct{}
+	index    map[string]map[string]struct{} // addr of the value -> keys
+	lastGen  int64
+	marks    map[string]time.Time // the marks of the nodes, see routetable.MarkRouteTableData
+}
+
+type item struct {
+	key      string
+	value    string
+	gen      int64
+	expireAt time.Time // zero means never expire
+	index    int       // index in the expire heap, -1 when not in the heap
+}
+
+func NewRouteTable() *RouteTable {
+	return &RouteTable{
+		items:    make(map[string]*item),
+		watchers: make(map[*watcher]struct{}),
+		index:    make(map[string]map[string]struct{}),
+		marks:    make(map[string]time.Time),
+	}
+}
+
+// Close stops the expiration timer and drops all the entries
+func (rt *RouteTable) Close() {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	if rt.timer != nil {
+		rt.timer.Stop()
+	}
+	rt.closed = true
+	rt.items = make(map[string]*item)
+	rt.expires = nil
+	rt.index = make(map[string]map[string]struct{})
+	rt.marks = make(map[string]time.Time)
+	for w := range rt.watchers {
+		rt.unwatchLocked(w)
+	}
+}
+
+func notFound(operation string, args ...interface{}) error {
+	return errors.Wrapf(errors.Wrapf(verrors.ErrRouteTableNotFound, "%s data not found", operation),
+		"%s %s failed %v", errPrefix, operation, args)
+}
+
+func (rt *RouteTable) Set(ctx context.Context, key string, addr string, dur time.Duration) error {
+	if dur <= 0 {
+		return errors.Errorf("%s Set failed [key %s addr %s] invalid expire time %s", errPrefix, key, addr, dur)
+	}
+
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	rt.setLocked(key, addr, dur)
+	return nil
+}
+
+func (rt *RouteTable) GetSet(ctx context.Context, key string, addr string, dur time.Duration) (string, error) {
+	old, _, err := rt.GetSetGen(ctx, key, addr, dur)
+	return old, err
+}
+
+// GetSetGen sets the value and the expiration, returns the old value and the new generation.
+// The same as redis, the value is stored even if the old one not exists, and ErrRouteTableNotFound is returned.
+func (rt *RouteTable) GetSetGen(ctx context.Context, key string, addr string, dur time.Duration) (string, int64, error) {
+	if dur <= 0 {
+		return "", 0, errors.Errorf("%s GetSet failed [key %s addr %s] invalid expire time %s", errPrefix, key, addr, dur)
+	}
+
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	it, ok := rt.getLocked(key)
+	old := ""
+	if ok {
+		old = it.value
+	}
+
+	gen := rt.setLocked(key, addr, dur)
+
+	if !ok {
+		return "", gen, notFound("GetSet", "key", key, "addr", addr)
+	}
+	return old, gen, nil
+}
+
+// SetNx sets the value if not exists with expiration, returns:
+// ok - true when key was set
+// result - current value (new value when ok=true)
+// err - operation error
+func (rt *RouteTable) SetNx(ctx context.Context, key string, addr string, dur time.Duration) (bool, string, error) {
+	ok, result, _, err := rt.SetNxGen(ctx, key, addr, dur)
+	return ok, result, err
+}
+
+// SetNxGen is SetNx which also returns the generation of the current value
+func (rt *RouteTable) SetNxGen(ctx context.Context, key string, addr string, dur time.Duration) (bool, string, int64, error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	if it, ok := rt.getLocked(key); ok {
+		return false, it.value, it.gen, nil
+	}
+
+	it := rt.storeLocked(key, addr)
+	rt.emitLocked(routetable.DataEvent{Key: key, Kind: routetable.EventSet, New: addr})
+	if dur > 0 {
+		rt.expireLocked(key, dur)
+	}
+	return true, addr, it.gen, nil
+}
+
+// CompareAndSwap sets the value only if the current generation is expectedGen, the generation of the missing key is 0.
+// It returns the new generation when swapped, otherwise the current generation.
+func (rt *RouteTable) CompareAndSwap(ctx context.Context, key string, expectedGen int64, addr string, dur time.Duration) (bool, int64, error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	var gen int64
+	old := ""
+	if it, ok := rt.getLocked(key); ok {
+		gen, old = it.gen, it.value
+	}
+	if gen != expectedGen {
+		return false, gen, nil
+	}
+
+	it := rt.storeLocked(key, addr)
+	rt.emitLocked(routetable.DataEvent{Key: key, Kind: routetable.EventSet, Old: old, New: addr})
+	if dur > 0 {
+		rt.expireLocked(key, dur)
+	}
+	return true, it.gen, nil
+}
+
+func (rt *RouteTable) Load(ctx context.Context, key string) (string, error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	it, ok := rt.getLocked(key)
+	if !ok {
+		return "", notFound("Load", "key", key)
+	}
+	return it.value, nil
+}
+
+func (rt *RouteTable) LoadGen(ctx context.Context, key string) (string, int64, error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	it, ok := rt.getLocked(key)
+	if !ok {
+		return "", 0, notFound("LoadGen", "key", key)
+	}
+	return it.value, it.gen, nil
+}
+
+// LoadAndExpire loads the value and resets the expiration like redis GETEX:
+// dur > 0 sets the expiration, dur == 0 removes it and dur < 0 keeps it unchanged.
+func (rt *RouteTable) LoadAndExpire(ctx context.Context, key string, dur time.Duration) (string, error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	it, ok := rt.getLocked(key)
+	if !ok {
+		return "", notFound("LoadAndExpire", "key", key)
+	}
+
+	switch {
+	case dur > 0:
+		rt.expireLocked(key, dur)
+	case dur == 0:
+		rt.persistLocked(it)
+	}
+	return it.value, nil
+}
+
+func (rt *RouteTable) Del(ctx context.Context, key string) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	rt.deleteLocked(key, routetable.EventDelete)
+	return nil
+}
+
+// DelIfSame deletes the key if its value has the same address as the value
+func (rt *RouteTable) DelIfSame(ctx context.Context, key string, value string) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	if it, ok := rt.getLocked(key); ok && routetable.SameAddr(it.value, value) {
+		rt.deleteLocked(key, routetable.EventDelete)
+	}
+	return nil
+}
+
+// Expire sets the expiration of the key, the key is deleted immediately when expiration <= 0.
+func (rt *RouteTable) Expire(ctx context.Context, key string, expiration time.Duration) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	if _, ok := rt.getLocked(key); !ok {
+		return nil
+	}
+	rt.expireLocked(key, expiration)
+	return nil
+}
+
+func (rt *RouteTable) ExpireMany(ctx context.Context, keys []string, expiration time.Duration) []error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	for _, key := range keys {
+		if _, ok := rt.getLocked(key); ok {
+			rt.expireLocked(key, expiration)
+		}
+	}
+	return make([]error, len(keys))
+}
+
+// TTL returns the remaining ttl of the key, 0 means no expiration
+func (rt *RouteTable) TTL(ctx context.Context, key string) (time.Duration, error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	it, ok := rt.getLocked(key)
+	if !ok {
+		return 0, notFound("TTL", "key", key)
+	}
+	if it.expireAt.IsZero() {
+		return 0, nil
+	}
+	return time.Until(it.expireAt), nil
+}
+
+// LoadManyTTL loads the values with the remaining ttl, the ttl is 0 if the key has no expiration
+func (rt *RouteTable) LoadManyTTL(ctx context.Context, keys []string) ([]string, []time.Duration, []error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	values := make([]string, len(keys))
+	ttls := make([]time.Duration, len(keys))
+	errs := make([]error, len(keys))
+	for i, key := range keys {
+		it, ok := rt.getLocked(key)
+		if !ok {
+			errs[i] = notFound("LoadManyTTL", "key", key)
+			continue
+		}
+		values[i] = it.value
+		if !it.expireAt.IsZero() {
+			ttls[i] = time.Until(it.expireAt)
+		}
+	}
+	return values, ttls, errs
+}
+
+func (rt *RouteTable) LoadMany(ctx context.Context, keys []string) ([]string, []error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	addrs := make([]string, len(keys))
+	errs := make([]error, len(keys))
+	for i, key := range keys {
+		it, ok := rt.getLocked(key)
+		if !ok {
+			errs[i] = notFound("LoadMany", "key", key)
+			continue
+		}
+		addrs[i] = it.value
+	}
+	return addrs, errs
+}
+
+func (rt *RouteTable) SetMany(ctx context.Context, keys []string, addrs []string, dur time.Duration) []error {
+	errs := make([]error, len(keys))
+	if len(keys) != len(addrs) || dur <= 0 {
+		for i, key := range keys {
+			errs[i] = errors.Errorf("%s SetMany failed [key %s] invalid arguments. addrs=%d dur=%s", errPrefix, key, len(addrs), dur)
+		}
+		return errs
+	}
+
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	for i, key := range keys {
+		rt.setLocked(key, addrs[i], dur)
+	}
+	return errs
+}
+
+// RenewMany resets the expiration of the keys owned by the addr,
+// ErrRouteTableNotFound is returned for the keys missing or owned by another addr.
+func (rt *RouteTable) RenewMany(ctx context.Context, keys []string, addr string, dur time.Duration) []error {
+	errs := make([]error, len(keys))
+	if dur <= 0 {
+		for i, key := range keys {
+			errs[i] = errors.Errorf("%s RenewMany failed [key %s] invalid expire time %s", errPrefix, key, dur)
+		}
+		return errs
+	}
+
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	for i, key := range keys {
+		it, ok := rt.getLocked(key)
+		if !ok || !routetable.SameAddr(it.value, addr) {
+			errs[i] = notFound("RenewMany", "key", key, "addr", addr)
+			continue
+		}
+		rt.expireLocked(key, dur)
+	}
+	return errs
+}
+
+func (rt *RouteTable) DelMany(ctx context.Context, keys []string) []error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	for _, key := range keys {
+		rt.deleteLocked(key, routetable.EventDelete)
+	}
+	return make([]error, len(keys))
+}
+
+// getLocked returns the alive item, the expired item is deleted lazily
+func (rt *RouteTable) getLocked(key string) (*item, bool) {
+	it, ok := rt.items[key]
+	if !ok {
+		return nil, false
+	}
+	if !it.expireAt.IsZero() && !time.Now().Before(it.expireAt) {
+		rt.deleteLocked(key, routetable.EventExpire)
+		return nil, false
+	}
+	return it, true
+}
+
+// setLocked stores the value with expiration and emits the set event, returns the new generation
+func (rt *RouteTable) setLocked(key string, value string, dur time.Duration) int64 {
+	old := ""
+	if it, ok := rt.getLocked(key); ok {
+		old = it.value
+	}
+	it := rt.storeLocked(key, value)
+	rt.emitLocked(routetable.DataEvent{Key: key, Kind: routetable.EventSet, Old: old, New: value})
+	rt.expireLocked(key, dur)
+	return it.gen
+}
+
+// storeLocked stores the value without expiration with a new generation, the same as redis SET without options
+func (rt *RouteTable) storeLocked(key string, value string) *item {
+	it, ok := rt.items[key]
+	if !ok {
+		it = &item{key: key, index: -1}
+		rt.items[key] = it
+	} else {
+		rt.unindexLocked(it)
+	}
+	rt.lastGen++
+	it.value = value
+	it.gen = rt.lastGen
+	rt.indexLocked(it)
+	rt.persistLocked(it)
+	return it
+}
+
+func (rt *RouteTable) expireLocked(key string, dur time.Duration) {
+	it, ok := rt.items[key]
+	if !ok {
+		return
+	}
+	if dur <= 0 {
+		rt.deleteLocked(key, routetable.EventDelete)
+		return
+	}
+
+	it.expireAt = time.Now().Add(dur)
+	if it.index < 0 {
+		heap.Push(&rt.expires, it)
+	} else {
+		heap.Fix(&rt.expires, it.index)
+	}
+	rt.scheduleLocked()
+}
+
+func (rt *RouteTable) persistLocked(it *item) {
+	it.expireAt = time.Time{}
+	if it.index >= 0 {
+		heap.Remove(&rt.expires, it.index)
+	}
+}
+
+func (rt *RouteTable) deleteLocked(key string, kind routetable.EventKind) {
+	it, ok := rt.items[key]
+	if !ok {
+		return
+	}
+	delete(rt.items, key)
+	rt.unindexLocked(it)
+	if it.index >= 0 {
+		heap.Remove(&rt.expires, it.index)
+	}
+	rt.emitLocked(routetable.DataEvent{Key: key, Kind: kind, Old: it.value})
+}
+
+// scheduleLocked resets the timer to the earliest expiration
+func (rt *RouteTable) scheduleLocked() {
+	if rt.closed || len(rt.expires) == 0 {
+		return
+	}
+
+	d := time.Until(rt.expires[0].expireAt)
+	if rt.timer == nil {
+		rt.timer = time.AfterFunc(d, rt.evict)
+		return
+	}
+	rt.timer.Reset(d)
+}
+
+func (rt *RouteTable) evict() {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	now := time.Now()
+	for len(rt.expires) > 0 && !now.Before(rt.expires[0].expireAt) {
+		it := heap.Pop(&rt.expires).(*item)
+		delete(rt.items, it.key)
+		rt.unindexLocked(it)
+		rt.emitLocked(routetable.DataEvent{Key: it.key, Kind: routetable.EventExpire, Old: it.value})
+	}
+	rt.scheduleLocked()
+}
+
+// expireHeap is a min-heap of items ordered by the expiration time
+type expireHeap []*item
+
+func (h expireHeap) Len() int           { return len(h) }
+func (h expireHeap) Less(i, j int) bool { return h[i].expireAt.Before(h[j].expireAt) }
+func (h expireHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *expireHeap) Push(x any) {
+	it := x.(*item)
+	it.index = len(*h)
+	*h = append(*h, it)
+}
+
+func (h *expireHeap) Pop() any {
+	old := *h
+	n := len(old)
+	it := old[n-1]
+	old[n-1] = nil
+	it.index = -1
+	*h = old[:n-1]
+	return it
+}
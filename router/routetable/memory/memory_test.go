@@ -0,0 +1,236 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	verrors "github.com/vulcan-frame/vulcan-pkg-app/errors"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable/routetabletest"
+)
+
+func TestRouteTable_SetAndLoad(t *testing.T) {
+	ctx := context.Background()
+	rt := NewRouteTable()
+	defer rt.Close()
+
+	_, err := rt.Load(ctx, "k")
+	assert.ErrorIs(t, err, verrors.ErrRouteTableNotFound)
+
+	require.NoError(t, rt.Set(ctx, "k", "a", time.Minute))
+	addr, err := rt.Load(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, "a", addr)
+
+	assert.Error(t, rt.Set(ctx, "k", "a", 0))
+}
+
+func TestRouteTable_SetNx(t *testing.T) {
+	ctx := context.Background()
+	rt := NewRouteTable()
+	defer rt.Close()
+
+	ok, result, err := rt.SetNx(ctx, "k", "a", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "a", result)
+
+	ok, result, err = rt.SetNx(ctx, "k", "b", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, "a", result)
+}
+
+func TestRouteTable_GetSet(t *testing.T) {
+	ctx := context.Background()
+	rt := NewRouteTable()
+	defer rt.Close()
+
+	old, err := rt.GetSet(ctx, "k", "a", time.Minute)
+	assert.ErrorIs(t, err, verrors.ErrRouteTableNotFound)
+	assert.Equal(t, "", old)
+
+	old, err = rt.GetSet(ctx, "k", "b", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "a", old)
+
+	addr, err := rt.Load(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, "b", addr)
+}
+
+func TestRouteTable_DelIfSame(t *testing.T) {
+	ctx := context.Background()
+	rt := NewRouteTable()
+	defer rt.Close()
+
+	require.NoError(t, rt.Set(ctx, "k", "a", time.Minute))
+	require.NoError(t, rt.DelIfSame(ctx, "k", "b"))
+	addr, err := rt.Load(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, "a", addr)
+
+	require.NoError(t, rt.DelIfSame(ctx, "k", "a"))
+	_, err = rt.Load(ctx, "k")
+	assert.ErrorIs(t, err, verrors.ErrRouteTableNotFound)
+}
+
+func TestRouteTable_Expire(t *testing.T) {
+	ctx := context.Background()
+	rt := NewRouteTable()
+	defer rt.Close()
+
+	require.NoError(t, rt.Set(ctx, "k1", "a", 20*time.Millisecond))
+	require.NoError(t, rt.Set(ctx, "k2", "b", time.Minute))
+
+	// LoadAndExpire extends the expiration
+	_, err := rt.LoadAndExpire(ctx, "k2", 20*time.Millisecond)
+	require.NoError(t, err)
+	require.NoError(t, rt.Set(ctx, "k3", "c", 20*time.Millisecond))
+	_, err = rt.LoadAndExpire(ctx, "k3", time.Minute)
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		rt.mu.Lock()
+		defer rt.mu.Unlock()
+		return len(rt.items) == 1
+	}, time.Second, 5*time.Millisecond)
+
+	addr, err := rt.Load(ctx, "k3")
+	require.NoError(t, err)
+	assert.Equal(t, "c", addr)
+
+	require.NoError(t, rt.Expire(ctx, "k3", 0))
+	_, err = rt.Load(ctx, "k3")
+	assert.ErrorIs(t, err, verrors.ErrRouteTableNotFound)
+}
+
+func TestRouteTable_Many(t *testing.T) {
+	ctx := context.Background()
+	rt := NewRouteTable()
+	defer rt.Close()
+
+	errs := rt.SetMany(ctx, []string{"k1", "k2"}, []string{"a", "b"}, time.Minute)
+	assert.Equal(t, []error{nil, nil}, errs)
+
+	addrs, errs := rt.LoadMany(ctx, []string{"k1", "k2", "k3"})
+	assert.Equal(t, []string{"a", "b", ""}, addrs)
+	assert.NoError(t, errs[0])
+	assert.NoError(t, errs[1])
+	assert.ErrorIs(t, errs[2], verrors.ErrRouteTableNotFound)
+
+	errs = rt.DelMany(ctx, []string{"k1", "k3"})
+	assert.Equal(t, []error{nil, nil}, errs)
+	_, err := rt.Load(ctx, "k1")
+	assert.ErrorIs(t, err, verrors.ErrRouteTableNotFound)
+}
+
+func TestRouteTable_Watch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	rt := NewRouteTable()
+	defer rt.Close()
+
+	ch, err := rt.Watch(ctx, "p_")
+	require.NoError(t, err)
+
+	require.NoError(t, rt.Set(ctx, "p_1", "a", 20*time.Millisecond))
+	require.NoError(t, rt.Set(ctx, "q_1", "a", time.Minute))
+	_, err = rt.GetSet(ctx, "p_2", "b", time.Minute)
+	assert.ErrorIs(t, err, verrors.ErrRouteTableNotFound)
+	require.NoError(t, rt.DelIfSame(ctx, "p_2", "b"))
+
+	expected := []routetable.DataEvent{
+		{Key: "p_1", Kind: routetable.EventSet, New: "a"},
+		{Key: "p_2", Kind: routetable.EventSet, New: "b"},
+		{Key: "p_2", Kind: routetable.EventDelete, Old: "b"},
+		{Key: "p_1", Kind: routetable.EventExpire, Old: "a"},
+	}
+	for _, ev := range expected {
+		select {
+		case got := <-ch:
+			assert.Equal(t, ev, got)
+		case <-time.After(time.Second):
+			t.Fatalf("event not received: %+v", ev)
+		}
+	}
+
+	cancel()
+	assert.Eventually(t, func() bool {
+		_, ok := <-ch
+		return !ok
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestRouteTable_ListByAddr(t *testing.T) {
+	ctx := context.Background()
+	rt := NewRouteTable()
+	defer rt.Close()
+
+	require.NoError(t, rt.Set(ctx, "p_1", "a", time.Minute))
+	require.NoError(t, rt.Set(ctx, "p_2", "a", time.Minute))
+	require.NoError(t, rt.Set(ctx, "p_3", "a", time.Minute))
+	require.NoError(t, rt.Set(ctx, "q_1", "a", time.Minute))
+	_, _, err := rt.SetNx(ctx, "p_4", "a", time.Minute)
+	require.NoError(t, err)
+	_, err = rt.GetSet(ctx, "p_2", "b", time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, rt.DelIfSame(ctx, "p_3", "a"))
+
+	keys, next, err := rt.ListByAddr(ctx, "a", "p_", "", 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"p_1"}, keys)
+	keys, next, err = rt.ListByAddr(ctx, "a", "p_", next, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"p_4"}, keys)
+	assert.Empty(t, next)
+
+	keys, _, err = rt.ListByAddr(ctx, "b", "p_", "", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"p_2"}, keys)
+}
+
+func TestRouteTable_Gen(t *testing.T) {
+	ctx := context.Background()
+	rt := NewRouteTable()
+	defer rt.Close()
+
+	ok, _, gen1, err := rt.SetNxGen(ctx, "k", "a", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	_, gen2, err := rt.GetSetGen(ctx, "k", "b", time.Minute)
+	require.NoError(t, err)
+	assert.Greater(t, gen2, gen1)
+
+	ok, gen, err := rt.CompareAndSwap(ctx, "k", gen1, "c", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, gen2, gen)
+
+	ok, gen3, err := rt.CompareAndSwap(ctx, "k", gen2, "c", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Greater(t, gen3, gen2)
+
+	addr, gen, err := rt.LoadGen(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, "c", addr)
+	assert.Equal(t, gen3, gen)
+
+	// the generation of the missing key is 0
+	ok, gen4, err := rt.CompareAndSwap(ctx, "k2", 0, "a", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Greater(t, gen4, gen3)
+}
+
+func TestConformance(t *testing.T) {
+	routetabletest.RunConformance(t, func(t *testing.T) routetable.RouteTableData {
+		rt := NewRouteTable()
+		t.Cleanup(rt.Close)
+		return rt
+	})
+}
@@ -0,0 +1,65 @@
+package memory
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/pkg/errors"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+)
+
+const (
+	watchBufferSize = 1024
+)
+
+type watcher struct {
+	prefix string
+	ch     chan routetable.DataEvent
+}
+
+// Watch returns the changes of the keys with the prefix.
+// The events are dropped when the channel is full, so the receiver should not be blocked.
+func (rt *RouteTable) Watch(ctx context.Context, prefix string) (<-chan routetable.DataEvent, error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	if rt.closed {
+		return nil, errors.Errorf("%s Watch failed [prefix %s] route table closed", errPrefix, prefix)
+	}
+
+	w := &watcher{
+		prefix: prefix,
+		ch:     make(chan routetable.DataEvent, watchBufferSize),
+	}
+	rt.watchers[w] = struct{}{}
+
+	go func() {
+		<-ctx.Done()
+		rt.mu.Lock()
+		defer rt.mu.Unlock()
+		rt.unwatchLocked(w)
+	}()
+	return w.ch, nil
+}
+
+func (rt *RouteTable) unwatchLocked(w *watcher) {
+	if _, ok := rt.watchers[w]; !ok {
+		return
+	}
+	delete(rt.watchers, w)
+	close(w.ch)
+}
+
+func (rt *RouteTable) emitLocked(ev routetable.DataEvent) {
+	for w := range rt.watchers {
+		if !strings.HasPrefix(ev.Key, w.prefix) {
+			continue
+		}
+		select {
+		case w.ch <- ev:
+		default:
+			log.Warnf("%s watcher is full, the event is dropped. prefix=%s key=%s kind=%s", errPrefix, w.prefix, ev.Key, ev.Kind)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package routetable
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/pkg/errors"
+	verrors "github.com/vulcan-frame/vulcan-pkg-app/errors"
+)
+
+// MigrateKeys rewrites the routes of the color from the keys in the old format to the new format with the ttl.
+// The route which already exists in the new format is kept, so it's safe to run while the services
+// have been switched to the new format. The old key is deleted only if it's not changed during the migration.
+func MigrateKeys(ctx context.Context, rtd RouteTableData, name, color string, from, to KeyFormat, ttl time.Duration) (migrated int, err error) {
+	if from.Prefix(name, color) == to.Prefix(name, color) {
+		return 0, errors.Errorf("the key formats are the same. prefix=%s", from.Prefix(name, color))
+	}
+	return moveKeys(ctx, rtd, from, name, color, func(oid string) string {
+		return to.Key(name, color, oid)
+	}, ttl)
+}
+
+// RenameKeys is MigrateKeys of the routes from the name to another in the same key format,
+// such as the name with the scope of WithSIDScope or WithZoneScope
+func RenameKeys(ctx context.Context, rtd RouteTableData, format KeyFormat, from, to, color string, ttl time.Duration) (migrated int, err error) {
+	if from == to {
+		return 0, errors.Errorf("the names are the same. name=%s", from)
+	}
+	return moveKeys(ctx, rtd, format, from, color, func(oid string) string {
+		return format.Key(to, color, oid)
+	}, ttl)
+}
+
+// moveKeys moves the routes of the name and color in the format to the keys of newKey
+func moveKeys(ctx context.Context, rtd RouteTableData, from KeyFormat, name, color string, newKey func(oid string) string, ttl time.Duration) (migrated int, err error) {
+	fromPrefix := from.Prefix(name, color)
+	err = Scan(ctx, rtd, fromPrefix, func(keys []string) error {
+		addrs, errs := LoadMany(ctx, rtd, keys)
+		for i, key := range keys {
+			if errs[i] != nil {
+				if errors.Is(errs[i], verrors.ErrRouteTableNotFound) {
+					continue
+				}
+				return errs[i]
+			}
+
+			oid, ok := from.Parse(fromPrefix, key)
+			if !ok {
+				log.Warnf("migrate route table key skipped, the key is not in the old format. key=%s", key)
+				continue
+			}
+
+			if _, _, err := rtd.SetNx(ctx, newKey(oid), addrs[i], ttl); err != nil {
+				return err
+			}
+			if err := rtd.DelIfSame(ctx, key, addrs[i]); err != nil {
+				return err
+			}
+			migrated++
+		}
+		return nil
+	})
+	return migrated, err
+}
+
+// MigrateKeys is MigrateKeys of the routes in the scope of the ctx, the routes are written with the ttl of the route table
+func (r *BaseRouteTable) MigrateKeys(ctx context.Context, color string, from, to KeyFormat) (int, error) {
+	return MigrateKeys(ctx, r.RouteTableData, r.scopedName(ctx), color, from, to, r.ttl)
+}
+
+// MigrateToScope moves the routes of the color written before WithSIDScope or WithZoneScope is enabled
+// to the scope of the ctx, the SID of the routes is set by NewSIDContext.
+// The route which already exists in the scope is kept, so it's safe to run while the services have been switched.
+func (r *BaseRouteTable) MigrateToScope(ctx context.Context, color string) (int, error) {
+	return RenameKeys(ctx, r.RouteTableData, r.keyFormat, r.name, r.scopedName(ctx), color, r.ttl)
+}
@@ -0,0 +1,93 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/redis/go-redis/v9"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable/cache"
+)
+
+const (
+	defaultInvalidateChannel = "rt_invalidate"
+)
+
+var _ cache.Broadcaster = (*Broadcaster)(nil)
+
+type BroadcasterOption func(*Broadcaster)
+
+func WithChannel(channel string) BroadcasterOption {
+	return func(b *Broadcaster) {
+		b.channel = channel
+	}
+}
+
+// Broadcaster broadcasts the invalidation messages of the route table cache over redis pub/sub in json
+type Broadcaster struct {
+	rdb     redis.UniversalClient
+	channel string
+}
+
+func NewBroadcaster(rdb redis.UniversalClient, opts ...BroadcasterOption) *Broadcaster {
+	b := &Broadcaster{
+		rdb:     rdb,
+		channel: defaultInvalidateChannel,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+func (b *Broadcaster) Publish(ctx context.Context, msg cache.Invalidation) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return wrapErr(err, "Publish", "channel", b.channel, "keys", msg.Keys)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	if err := b.rdb.Publish(ctx, b.channel, payload).Err(); err != nil {
+		return wrapErr(err, "Publish", "channel", b.channel, "keys", msg.Keys)
+	}
+	return nil
+}
+
+func (b *Broadcaster) Subscribe(ctx context.Context) (<-chan cache.Invalidation, error) {
+	ps := b.rdb.Subscribe(ctx, b.channel)
+	if _, err := ps.Receive(ctx); err != nil {
+		_ = ps.Close()
+		return nil, wrapErr(err, "Subscribe", "channel", b.channel)
+	}
+
+	ch := make(chan cache.Invalidation, 128)
+	go func() {
+		defer close(ch)
+		defer ps.Close()
+
+		msgs := ps.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-msgs:
+				if !ok {
+					return
+				}
+				var inv cache.Invalidation
+				if err := json.Unmarshal([]byte(msg.Payload), &inv); err != nil {
+					log.Errorf("%s decode invalidation failed. payload=%s err=%+v", errPrefix, msg.Payload, err)
+					continue
+				}
+				select {
+				case ch <- inv:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return ch, nil
+}
@@ -0,0 +1,65 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable/cache"
+)
+
+func newBroadcasterClient(t *testing.T) redis.UniversalClient {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+	return rdb
+}
+
+func TestBroadcaster(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rdb := newBroadcasterClient(t)
+
+	b := NewBroadcaster(rdb)
+	other := NewBroadcaster(rdb, WithChannel("other"))
+	ch, err := b.Subscribe(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, other.Publish(ctx, cache.Invalidation{Node: "n1", Keys: []string{"k0"}}))
+	require.NoError(t, b.Publish(ctx, cache.Invalidation{Node: "n1", Keys: []string{"k1", "k2"}}))
+	require.NoError(t, b.Publish(ctx, cache.Invalidation{Node: "n2", Keys: []string{"k3"}}))
+	for _, want := range []cache.Invalidation{
+		{Node: "n1", Keys: []string{"k1", "k2"}},
+		{Node: "n2", Keys: []string{"k3"}},
+	} {
+		select {
+		case msg := <-ch:
+			assert.Equal(t, want, msg)
+		case <-time.After(time.Second):
+			t.Fatalf("message %v not received", want)
+		}
+	}
+
+	cancel()
+	assert.Eventually(t, func() bool {
+		select {
+		case _, ok := <-ch:
+			return !ok
+		default:
+			return false
+		}
+	}, time.Second, 5*time.Millisecond, "closed when the ctx is done")
+}
+
+func TestBroadcaster_SubscribeFailed(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer rdb.Close()
+
+	_, err := NewBroadcaster(rdb).Subscribe(context.Background())
+	assert.Error(t, err)
+	assert.Error(t, NewBroadcaster(rdb).Publish(context.Background(), cache.Invalidation{Keys: []string{"k"}}))
+}
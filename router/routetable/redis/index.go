@@ -0,0 +1,214 @@
+package redis
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+)
+
+var (
+	// removeIfScoreScript removes the member from the index only if it has not been re-added since it was scanned
+	removeIfScoreScript = redis.NewScript(`
+local score = redis.call("ZSCORE", KEYS[1], ARGV[1])
+if score and tonumber(score) == tonumber(ARGV[2]) then
+    return redis.call("ZREM", KEYS[1], ARGV[1])
+end
+return 0`)
+
+	globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+)
+
+const indexPrefix = "ri_"
+
+// indexKey is the sorted set of the keys of the namespace routed to the addr, the score is the time of the last write
+// in microseconds. The namespace is the part of the keys before the hash tag of the oid, which is the name, the scope
+// and the color with the KeyFormats of routetable. The index of the other keys is shared by the namespaces.
+//
+// The index follows the writes and expires no earlier than its keys, so it's a superset of the keys routed to the addr.
+// The stale members are left by the expired keys and the failed writes of the index out of the slot of the keys,
+// they are filtered and removed when listing.
+func indexKey(ns, addr string) string {
+	if ns == "" {
+		return indexPrefix + "{" + addr + "}"
+	}
+	return indexPrefix + ns + addr
+}
+
+// keyNamespace returns the namespace of the key of prefix + oid + "}"
+func keyNamespace(key string) string {
+	if !strings.HasSuffix(key, "}") {
+		return ""
+	}
+	i := strings.LastIndexByte(key, '{')
+	if i < 0 {
+		return ""
+	}
+	return key[:i]
+}
+
+// prefixNamespace returns the namespace of the keys with the prefix, it's the same as keyNamespace of the keys
+func prefixNamespace(prefix string) string {
+	if !strings.HasSuffix(prefix, "{") {
+		return ""
+	}
+	return prefix[:len(prefix)-1]
+}
+
+// scriptIndex returns the prefix of the index keys of the key if they are in the slot of the key,
+// then the index is maintained by the scripts, otherwise it returns "" and the index is maintained by the caller.
+func scriptIndex(key string) string {
+	ns := keyNamespace(key)
+	if ns == "" {
+		return ""
+	}
+	if tag, ok := hashTag(indexPrefix + ns); ok && tag == slotKey(key) {
+		return indexPrefix + ns
+	}
+	return ""
+}
+
+// hashTag returns the hash tag of the key of Redis Cluster
+func hashTag(key string) (string, bool) {
+	i := strings.IndexByte(key, '{')
+	if i < 0 {
+		return "", false
+	}
+	j := strings.IndexByte(key[i+1:], '}')
+	if j <= 0 {
+		return "", false
+	}
+	return key[i+1 : i+1+j], true
+}
+
+// slotKey returns the part of the key hashed to the slot
+func slotKey(key string) string {
+	if tag, ok := hashTag(key); ok {
+		return tag
+	}
+	return key
+}
+
+// indexChange is a change of the route, the empty value is missing
+type indexChange struct {
+	key string
+	old string
+	new string
+	dur time.Duration
+}
+
+// reindex maintains the index of the keys out of the slot like the scripts after the changes, in a pipeline.
+// It's best effort, the failures are logged and the stale members are removed by ListByAddr.
+func (rt *RouteTable) reindex(ctx context.Context, operation string, changes ...indexChange) {
+	n := 0
+	for _, c := range changes {
+		if scriptIndex(c.key) == "" {
+			changes[n] = c
+			n++
+		}
+	}
+	if n == 0 {
+		return
+	}
+	changes = changes[:n]
+
+	cmds := rt.pipelined(ctx, func(pipeliner redis.Pipeliner) {
+		for _, c := range changes {
+			ns := keyNamespace(c.key)
+			if c.old != "" && (c.new == "" || !routetable.SameAddr(c.old, c.new)) {
+				pipeliner.ZRem(ctx, indexKey(ns, routetable.AddrOf(c.old)), c.key)
+			}
+			if c.new != "" {
+				addIndexScript.EvalSha(ctx, pipeliner, []string{indexKey(ns, routetable.AddrOf(c.new))}, c.key, ttlMillis(c.dur))
+			}
+		}
+	})
+	for _, cmd := range cmds {
+		if err := cmd.Err(); err != nil {
+			log.Errorf("%s %s update addr index failed. err=%+v", errPrefix, operation, err)
+			return
+		}
+	}
+}
+
+// ListByAddr scans the keys with the prefix which are routed to the addr.
+// The cursor is the decimal cursor of ZSCAN, the next cursor is empty when the scan is finished.
+func (rt *RouteTable) ListByAddr(ctx context.Context, addr string, prefix string, cursor string, count int64) (keys []string, next string, err error) {
+	var c uint64
+	if cursor != "" {
+		if c, err = strconv.ParseUint(cursor, 10, 64); err != nil {
+			return nil, "", wrapErr(errors.Wrapf(err, "invalid cursor"), "ListByAddr", "addr", addr, "cursor", cursor)
+		}
+	}
+
+	var n uint64
+	err = rt.do(ctx, "ListByAddr", true, func(ctx context.Context) error {
+		keys, n, err = rt.listByAddr(ctx, addr, prefix, c, count)
+		return err
+	})
+	if err != nil || n == 0 {
+		return keys, "", err
+	}
+	return keys, strconv.FormatUint(n, 10), nil
+}
+
+func (rt *RouteTable) listByAddr(ctx context.Context, addr string, prefix string, cursor uint64, count int64) ([]string, uint64, error) {
+	idx := indexKey(prefixNamespace(prefix), addr)
+	members, next, err := rt.rdb.ZScan(ctx, idx, cursor, globEscaper.Replace(prefix)+"*", count).Result()
+	if err != nil {
+		return nil, 0, wrapErr(err, "ListByAddr", "addr", addr, "prefix", prefix)
+	}
+	if len(members) == 0 {
+		return nil, next, nil
+	}
+
+	// members are [key, score, key, score, ...]
+	candidates := make([]string, 0, len(members)/2)
+	scores := make([]string, 0, len(members)/2)
+	for i := 0; i+1 < len(members); i += 2 {
+		candidates = append(candidates, members[i])
+		scores = append(scores, members[i+1])
+	}
+
+	// the keys may be in different slots in the cluster, so they are loaded by the pipeline instead of MGET
+	cmds := make([]*redis.StringCmd, len(candidates))
+	_, _ = rt.rdb.Pipelined(ctx, func(pipeliner redis.Pipeliner) error {
+		for i, key := range candidates {
+			cmds[i] = pipeliner.Get(ctx, key)
+		}
+		return nil
+	})
+
+	keys := make([]string, 0, len(candidates))
+	stale := make([]int, 0)
+	for i, key := range candidates {
+		v, err := cmds[i].Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return nil, 0, wrapErr(err, "ListByAddr", "addr", addr, "prefix", prefix)
+		}
+		if err == nil && routetable.AddrOf(v) == addr {
+			keys = append(keys, key)
+			continue
+		}
+		stale = append(stale, i)
+	}
+
+	if len(stale) > 0 {
+		_, err = rt.rdb.Pipelined(ctx, func(pipeliner redis.Pipeliner) error {
+			for _, i := range stale {
+				score, _ := strconv.ParseFloat(scores[i], 64)
+				removeIfScoreScript.Eval(ctx, pipeliner, []string{idx}, candidates[i], score)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, 0, wrapErr(errors.WithMessage(err, "remove stale index failed"), "ListByAddr", "addr", addr, "prefix", prefix)
+		}
+	}
+	return keys, next, nil
+}
@@ -0,0 +1,63 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+)
+
+// SetMark sets the mark as a plain key, it's not indexed or versioned like the routes
+func (rt *RouteTable) SetMark(ctx context.Context, key string, dur time.Duration) error {
+	if dur <= 0 {
+		return wrapErr(errors.Errorf("invalid expire time %s", dur), "SetMark", "key", key)
+	}
+
+	return rt.do(ctx, "SetMark", true, func(ctx context.Context) error {
+		if err := rt.rdb.Set(ctx, key, "1", dur).Err(); err != nil {
+			return wrapErr(err, "SetMark", "key", key)
+		}
+		return nil
+	})
+}
+
+func (rt *RouteTable) DelMark(ctx context.Context, key string) error {
+	return rt.do(ctx, "DelMark", true, func(ctx context.Context) error {
+		if err := rt.rdb.Del(ctx, key).Err(); err != nil {
+			return wrapErr(err, "DelMark", "key", key)
+		}
+		return nil
+	})
+}
+
+// LoadMarks checks the marks in a pipeline, the keys may be in different slots in the cluster
+func (rt *RouteTable) LoadMarks(ctx context.Context, keys []string) ([]bool, error) {
+	marked := make([]bool, len(keys))
+	if len(keys) == 0 {
+		return marked, nil
+	}
+
+	err := rt.do(ctx, "LoadMarks", true, func(ctx context.Context) error {
+		cmds := make([]*redis.IntCmd, len(keys))
+		_, _ = rt.rdb.Pipelined(ctx, func(pipeliner redis.Pipeliner) error {
+			for i, key := range keys {
+				cmds[i] = pipeliner.Exists(ctx, key)
+			}
+			return nil
+		})
+
+		for i, cmd := range cmds {
+			n, err := cmd.Result()
+			if err != nil {
+				return wrapErr(err, "LoadMarks", "key", keys[i])
+			}
+			marked[i] = n > 0
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return marked, nil
+}
@@ -2,12 +2,14 @@ package redis
 
 import (
 	"context"
+	"strconv"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/redis/go-redis/v9"
 	verrors "github.com/vulcan-frame/vulcan-pkg-app/errors"
 	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+	"go.opentelemetry.io/otel/metric"
 )
 
 const (
@@ -15,20 +17,22 @@ const (
 	errPrefix      = "redis routeTable"
 )
 
-var (
-	delIfSameScript = redis.NewScript(`
-if redis.call("GET", KEYS[1]) == ARGV[1] then
-    return redis.call("DEL", KEYS[1])
-else
-    return 1
-end`)
-)
-
 type Cmdable interface {
 	redis.Cmdable
 }
 
-var _ routetable.RouteTableData = (*RouteTable)(nil)
+var (
+	_ routetable.RouteTableData           = (*RouteTable)(nil)
+	_ routetable.GenRouteTableData        = (*RouteTable)(nil)
+	_ routetable.BatchRouteTableData      = (*RouteTable)(nil)
+	_ routetable.WatchRouteTableData      = (*RouteTable)(nil)
+	_ routetable.IndexRouteTableData      = (*RouteTable)(nil)
+	_ routetable.ScanRouteTableData       = (*RouteTable)(nil)
+	_ routetable.TTLRouteTableData        = (*RouteTable)(nil)
+	_ routetable.SnapshotRouteTableData   = (*RouteTable)(nil)
+	_ routetable.ExpireManyRouteTableData = (*RouteTable)(nil)
+	_ routetable.MarkRouteTableData       = (*RouteTable)(nil)
+)
 
 type Option func(*RouteTable)
 
@@ -38,19 +42,54 @@ func WithTimeout(dur time.Duration) Option {
 	}
 }
 
+// WithWatch enables Watch and the publish, the changes published to the watch channel are subscribed by the client.
+func WithWatch(client redis.UniversalClient) Option {
+	return func(r *RouteTable) {
+		r.sub = client
+		r.publish = true
+	}
+}
+
+// WithPublish sets whether the changes are published to the watch channel by the scripts writing them.
+// It's disabled by default and enabled by WithWatch, enable it on the nodes which don't watch
+// if their changes are watched by the other nodes.
+func WithPublish(enabled bool) Option {
+	return func(r *RouteTable) {
+		r.publish = enabled
+	}
+}
+
+// WithWatchChannel sets the prefix of the watch channels, the channel of the keys is namespaced by their prefix
+func WithWatchChannel(channel string) Option {
+	return func(r *RouteTable) {
+		r.watchChannel = channel
+	}
+}
+
 type RouteTable struct {
 	rdb     Cmdable
 	timeout time.Duration
+
+	sub          redis.UniversalClient
+	watchChannel string
+	publish      bool
+
+	retryPolicy RetryPolicy
+	retries     metric.Int64Counter
 }
 
 func NewRouteTable(rdb Cmdable, opts ...Option) *RouteTable {
 	rt := &RouteTable{
-		rdb:     rdb,
-		timeout: defaultTimeout,
+		rdb:          rdb,
+		timeout:      defaultTimeout,
+		watchChannel: defaultWatchChannel,
 	}
 	for _, opt := range opts {
 		opt(rt)
 	}
+	if rt.retryPolicy.enabled() {
+		rt.initMetrics()
+	}
 	return rt
 }
 
@@ -63,37 +102,63 @@ func wrapErr(err error, operation string, args ...interface{}) error {
 }
 
 func (rt *RouteTable) Set(ctx context.Context, key string, addr string, dur time.Duration) error {
-	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
-	defer cancel()
-
-	if err := rt.rdb.SetEx(ctx, key, addr, dur).Err(); err != nil {
-		return wrapErr(err, "Set", "key", key, "addr", addr)
+	if dur <= 0 {
+		return wrapErr(errors.Errorf("invalid expire time %s", dur), "Set", "key", key, "addr", addr)
 	}
-	return nil
-}
 
-func (rt *RouteTable) GetSet(ctx context.Context, key string, addr string, dur time.Duration) (string, error) {
-	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
-	defer cancel()
+	// the retry after an ambiguous failure may overwrite the value set by others in between, so Set is not idempotent
+	return rt.do(ctx, "Set", false, func(ctx context.Context) error {
+		var cmd *redis.Cmd
+		rt.pipelined(ctx, func(pipeliner redis.Pipeliner) {
+			cmd = setScript.EvalSha(ctx, pipeliner, routeKeys(key), rt.scriptArgs(key, addr, dur.Milliseconds())...)
+		})
 
-	var old string
-	cmders, err := rt.rdb.Pipelined(ctx, func(pipeliner redis.Pipeliner) error {
-		pipeliner.GetSet(ctx, key, addr)
-		pipeliner.Expire(ctx, key, dur)
+		old, _, err := parseSetResult(cmd)
+		if err != nil {
+			return wrapErr(err, "Set", "key", key, "addr", addr)
+		}
+		rt.reindex(ctx, "Set", indexChange{key: key, old: old, new: addr, dur: dur})
 		return nil
 	})
+}
 
-	if err := wrapErr(err, "GetSet", "key", key, "addr", addr); err != nil {
-		return "", err
+func (rt *RouteTable) GetSet(ctx context.Context, key string, addr string, dur time.Duration) (string, error) {
+	old, _, err := rt.GetSetGen(ctx, key, addr, dur)
+	return old, err
+}
+
+// GetSetGen sets the value with expiration, returns the old value and the new generation.
+// The value is stored even if the old one not exists, in which case ErrRouteTableNotFound is returned.
+func (rt *RouteTable) GetSetGen(ctx context.Context, key string, addr string, dur time.Duration) (string, int64, error) {
+	if dur <= 0 {
+		return "", 0, wrapErr(errors.Errorf("invalid expire time %s", dur), "GetSet", "key", key, "addr", addr)
 	}
 
-	for _, cmder := range cmders {
-		if cmd, ok := cmder.(*redis.StringCmd); ok && cmd.Name() == "getset" {
-			old = cmd.Val()
-			break
+	var (
+		old string
+		gen int64
+	)
+	err := rt.do(ctx, "GetSet", false, func(ctx context.Context) error {
+		var cmd *redis.Cmd
+		rt.pipelined(ctx, func(pipeliner redis.Pipeliner) {
+			cmd = setScript.EvalSha(ctx, pipeliner, routeKeys(key), rt.scriptArgs(key, addr, dur.Milliseconds())...)
+		})
+
+		var err error
+		if old, gen, err = parseSetResult(cmd); err != nil {
+			return wrapErr(err, "GetSet", "key", key, "addr", addr)
 		}
+		rt.reindex(ctx, "GetSet", indexChange{key: key, old: old, new: addr, dur: dur})
+		return nil
+	})
+	if err != nil {
+		return "", 0, err
+	}
+
+	if old == "" {
+		return "", gen, wrapErr(redis.Nil, "GetSet", "key", key, "addr", addr)
 	}
-	return old, nil
+	return old, gen, nil
 }
 
 // SetNx sets the value if not exists with expiration, returns:
@@ -101,103 +166,420 @@ func (rt *RouteTable) GetSet(ctx context.Context, key string, addr string, dur t
 // result - current value (new value when ok=true)
 // err - operation error
 func (rt *RouteTable) SetNx(ctx context.Context, key string, addr string, dur time.Duration) (bool, string, error) {
-	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
-	defer cancel()
+	ok, result, _, err := rt.SetNxGen(ctx, key, addr, dur)
+	return ok, result, err
+}
 
-	cmds, err := rt.rdb.Pipelined(ctx, func(pipeliner redis.Pipeliner) error {
-		pipeliner.SetNX(ctx, key, addr, dur)
-		pipeliner.Get(ctx, key)
+// SetNxGen is SetNx which also returns the generation of the current value
+func (rt *RouteTable) SetNxGen(ctx context.Context, key string, addr string, dur time.Duration) (bool, string, int64, error) {
+	var (
+		ok       bool
+		current  string
+		gen      int64
+		attempts int
+	)
+	err := rt.do(ctx, "SetNx", true, func(ctx context.Context) error {
+		attempts++
+		result, err := setNxScript.Run(ctx, rt.rdb, routeKeys(key), rt.scriptArgs(key, addr, dur.Milliseconds())...).Slice()
+		if err != nil {
+			return wrapErr(err, "SetNx", "key", key, "addr", addr)
+		}
+		if len(result) != 3 {
+			return wrapErr(errors.Errorf("unexpected script result: %v", result), "SetNx", "key", key)
+		}
+
+		ok = toInt64(result[0]) == 1
+		current = toString(result[1])
+		gen = toInt64(result[2])
+
+		// the value may be set by the failed attempt before the retry
+		if !ok && attempts > 1 && current == addr {
+			ok = true
+		}
+		if ok {
+			rt.reindex(ctx, "SetNx", indexChange{key: key, new: addr, dur: dur})
+		}
 		return nil
 	})
 	if err != nil {
-		return false, "", wrapErr(err, "SetNx", "key", key, "addr", addr)
-	}
-
-	if len(cmds) != 2 {
-		return false, "", wrapErr(errors.New("redis pipeline failed"), "SetNx", "key", key)
+		return false, "", 0, err
 	}
+	return ok, current, gen, nil
+}
 
-	var ok bool
-	if setCmd, okCmd := cmds[0].(*redis.BoolCmd); okCmd {
-		var errSet error
-		ok, errSet = setCmd.Result()
-		if errSet != nil {
-			return false, "", wrapErr(errSet, "SetNx", "key", key)
+// CompareAndSwap sets the value only if the current generation is expectedGen, the generation of the missing key is 0.
+// It returns the new generation when swapped, otherwise the current generation.
+func (rt *RouteTable) CompareAndSwap(ctx context.Context, key string, expectedGen int64, addr string, dur time.Duration) (bool, int64, error) {
+	var (
+		ok  bool
+		gen int64
+	)
+	// the retry after an ambiguous failure would see the generation of its own swap, so CompareAndSwap is not idempotent
+	err := rt.do(ctx, "CompareAndSwap", false, func(ctx context.Context) error {
+		result, err := casScript.Run(ctx, rt.rdb, routeKeys(key), rt.scriptArgs(key, expectedGen, addr, dur.Milliseconds())...).Slice()
+		if err != nil {
+			return wrapErr(err, "CompareAndSwap", "key", key, "addr", addr, "gen", expectedGen)
 		}
-	} else {
-		return false, "", wrapErr(errors.Errorf("unexpected SETNX response type: %T", cmds[0]), "SetNx", "key", key)
-	}
+		if len(result) != 3 {
+			return wrapErr(errors.Errorf("unexpected script result: %v", result), "CompareAndSwap", "key", key)
+		}
+
+		ok = toInt64(result[0]) == 1
+		old := toString(result[1])
+		gen = toInt64(result[2])
 
-	var currentValue string
-	if getCmd, okCmd := cmds[1].(*redis.StringCmd); okCmd {
-		val, errGet := getCmd.Result()
-		if errGet != nil && !errors.Is(errGet, redis.Nil) {
-			return false, "", wrapErr(errGet, "SetNx", "key", key)
+		if ok {
+			rt.reindex(ctx, "CompareAndSwap", indexChange{key: key, old: old, new: addr, dur: dur})
 		}
-		currentValue = val
-	} else {
-		return false, "", wrapErr(errors.Errorf("unexpected GET response type: %T", cmds[1]), "SetNx", "key", key)
+		return nil
+	})
+	if err != nil {
+		return false, 0, err
 	}
-
-	return ok, currentValue, nil
+	return ok, gen, nil
 }
 
 func (rt *RouteTable) Load(ctx context.Context, key string) (string, error) {
-	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
-	defer cancel()
+	var result string
+	err := rt.do(ctx, "Load", true, func(ctx context.Context) error {
+		var err error
+		if result, err = rt.rdb.Get(ctx, key).Result(); err != nil {
+			if errors.Is(err, redis.Nil) {
+				return wrapErr(verrors.ErrRouteTableNotFound, "Load", "key", key)
+			}
+			return wrapErr(err, "Load", "key", key)
+		}
+		return nil
+	})
+	return result, err
+}
 
-	result, err := rt.rdb.Get(ctx, key).Result()
-	if err != nil {
-		if errors.Is(err, redis.Nil) {
-			return "", wrapErr(verrors.ErrRouteTableNotFound, "Load", "key", key)
+// LoadGen loads the value and its generation, the generation is 0 if the key is written by the old version without generation
+func (rt *RouteTable) LoadGen(ctx context.Context, key string) (string, int64, error) {
+	var vals []interface{}
+	err := rt.do(ctx, "LoadGen", true, func(ctx context.Context) error {
+		// the generation key has the same hash tag, so MGET works in the cluster
+		var err error
+		if vals, err = rt.rdb.MGet(ctx, routeKeys(key)...).Result(); err != nil {
+			return wrapErr(err, "LoadGen", "key", key)
 		}
-		return result, wrapErr(err, "Load", "key", key)
+		return nil
+	})
+	if err != nil {
+		return "", 0, err
 	}
-	return result, nil
+	if len(vals) != 2 || vals[0] == nil {
+		return "", 0, wrapErr(redis.Nil, "LoadGen", "key", key)
+	}
+
+	gen, _ := strconv.ParseInt(toString(vals[1]), 10, 64)
+	return toString(vals[0]), gen, nil
 }
 
 func (rt *RouteTable) LoadAndExpire(ctx context.Context, key string, dur time.Duration) (string, error) {
-	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
-	defer cancel()
-
-	result, err := rt.rdb.GetEx(ctx, key, dur).Result()
+	var result string
+	err := rt.do(ctx, "LoadAndExpire", true, func(ctx context.Context) error {
+		var err error
+		if result, err = expireScript.Run(ctx, rt.rdb, routeKeys(key), rt.scriptArgs(key, ttlMillis(dur))...).Text(); err != nil {
+			return wrapErr(err, "LoadAndExpire", "key", key)
+		}
+		rt.reindex(ctx, "LoadAndExpire", indexChange{key: key, old: result, new: result, dur: dur})
+		return nil
+	})
 	if err != nil {
-		return "", wrapErr(err, "LoadAndExpire", "key", key)
+		return "", err
 	}
 	return result, nil
 }
 
 func (rt *RouteTable) Del(ctx context.Context, key string) error {
-	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
-	defer cancel()
-
-	if err := rt.rdb.Del(ctx, key).Err(); err != nil {
-		return wrapErr(err, "Del", "key", key)
-	}
-	return nil
+	// the retry after an ambiguous failure may delete the value set by others in between, so Del is not idempotent
+	return rt.do(ctx, "Del", false, func(ctx context.Context) error {
+		return rt.del(ctx, key, "Del")
+	})
 }
 
 func (rt *RouteTable) DelIfSame(ctx context.Context, key string, value string) error {
-	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
-	defer cancel()
+	return rt.do(ctx, "DelIfSame", true, func(ctx context.Context) error {
+		result, err := delIfSameScript.Run(ctx, rt.rdb, routeKeys(key), rt.scriptArgs(key, value)...).Slice()
+		if err != nil {
+			return wrapErr(err, "DelIfSame", "key", key, "value", value)
+		}
+		if len(result) == 0 {
+			return wrapErr(errors.Errorf("unexpected script result: %v", result), "DelIfSame", "key", key, "value", value)
+		}
+
+		if toInt64(result[0]) == 1 && len(result) == 2 {
+			rt.reindex(ctx, "DelIfSame", indexChange{key: key, old: toString(result[1])})
+		}
+		return nil
+	})
+}
+
+func (rt *RouteTable) Expire(ctx context.Context, key string, expiration time.Duration) error {
+	// the key is deleted when expiration <= 0, which is not idempotent like Del
+	return rt.do(ctx, "Expire", expiration > 0, func(ctx context.Context) error {
+		if expiration <= 0 {
+			return rt.del(ctx, key, "Expire")
+		}
+
+		cur, err := expireScript.Run(ctx, rt.rdb, routeKeys(key), rt.scriptArgs(key, ttlMillis(expiration))...).Text()
+		if errors.Is(err, redis.Nil) {
+			return nil
+		}
+		if err != nil {
+			return wrapErr(err, "Expire", "key", key)
+		}
+		rt.reindex(ctx, "Expire", indexChange{key: key, old: cur, new: cur, dur: expiration})
+		return nil
+	})
+}
 
-	result, err := delIfSameScript.Run(ctx, rt.rdb, []string{key}, value).Int64()
+func (rt *RouteTable) ExpireMany(ctx context.Context, keys []string, expiration time.Duration) []error {
+	errs := make([]error, len(keys))
+	if len(keys) == 0 {
+		return errs
+	}
+	if expiration <= 0 {
+		return rt.DelMany(ctx, keys)
+	}
+
+	rt.doMany(ctx, "ExpireMany", true, errs, func(ctx context.Context, idx []int) []error {
+		cmds := make([]*redis.Cmd, len(idx))
+		rt.pipelined(ctx, func(pipeliner redis.Pipeliner) {
+			for i, k := range idx {
+				cmds[i] = expireScript.EvalSha(ctx, pipeliner, routeKeys(keys[k]), rt.scriptArgs(keys[k], ttlMillis(expiration))...)
+			}
+		})
+
+		results := make([]error, len(idx))
+		changes := make([]indexChange, 0, len(idx))
+		for i, cmd := range cmds {
+			cur, err := cmd.Text()
+			if errors.Is(err, redis.Nil) {
+				continue
+			}
+			if err != nil {
+				results[i] = wrapErr(err, "ExpireMany", "key", keys[idx[i]])
+				continue
+			}
+			changes = append(changes, indexChange{key: keys[idx[i]], old: cur, new: cur, dur: expiration})
+		}
+		rt.reindex(ctx, "ExpireMany", changes...)
+		return results
+	})
+	return errs
+}
+
+// TTL returns the remaining ttl of the key, 0 means no expiration
+func (rt *RouteTable) TTL(ctx context.Context, key string) (time.Duration, error) {
+	var ttl time.Duration
+	err := rt.do(ctx, "TTL", true, func(ctx context.Context) error {
+		var err error
+		if ttl, err = rt.rdb.PTTL(ctx, key).Result(); err != nil {
+			return wrapErr(err, "TTL", "key", key)
+		}
+		return nil
+	})
 	if err != nil {
-		return wrapErr(err, "DelIfSame", "key", key, "value", value)
+		return 0, err
+	}
+	// go-redis returns -2 if the key not exists, -1 if the key has no expiration
+	switch ttl {
+	case -2:
+		return 0, wrapErr(redis.Nil, "TTL", "key", key)
+	case -1:
+		return 0, nil
 	}
+	return ttl, nil
+}
 
-	if result == 0 {
-		return wrapErr(errors.New("redis script execute failed"), "DelIfSame", "key", key, "value", value)
+func (rt *RouteTable) del(ctx context.Context, key string, operation string) error {
+	old, err := delScript.Run(ctx, rt.rdb, routeKeys(key), rt.scriptArgs(key)...).Text()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil
+		}
+		return wrapErr(err, operation, "key", key)
 	}
+	rt.reindex(ctx, operation, indexChange{key: key, old: old})
 	return nil
 }
 
-func (rt *RouteTable) Expire(ctx context.Context, key string, expiration time.Duration) error {
-	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
-	defer cancel()
+func (rt *RouteTable) LoadMany(ctx context.Context, keys []string) ([]string, []error) {
+	addrs := make([]string, len(keys))
+	errs := make([]error, len(keys))
+	if len(keys) == 0 {
+		return addrs, errs
+	}
 
-	if err := rt.rdb.Expire(ctx, key, expiration).Err(); err != nil {
-		return wrapErr(err, "Expire", "key", key)
+	rt.doMany(ctx, "LoadMany", true, errs, func(ctx context.Context, idx []int) []error {
+		// the keys may be in different slots in the cluster, so they are loaded by the pipeline instead of MGET
+		cmds := make([]*redis.StringCmd, len(idx))
+		_, _ = rt.rdb.Pipelined(ctx, func(pipeliner redis.Pipeliner) error {
+			for i, k := range idx {
+				cmds[i] = pipeliner.Get(ctx, keys[k])
+			}
+			return nil
+		})
+
+		results := make([]error, len(idx))
+		for i, cmd := range cmds {
+			val, err := cmd.Result()
+			if err != nil {
+				results[i] = wrapErr(err, "LoadMany", "key", keys[idx[i]])
+				continue
+			}
+			addrs[idx[i]] = val
+		}
+		return results
+	})
+	return addrs, errs
+}
+
+func (rt *RouteTable) SetMany(ctx context.Context, keys []string, addrs []string, dur time.Duration) []error {
+	errs := make([]error, len(keys))
+	if len(keys) != len(addrs) || dur <= 0 {
+		for i, key := range keys {
+			errs[i] = wrapErr(errors.Errorf("invalid arguments. addrs=%d dur=%s", len(addrs), dur), "SetMany", "key", key)
+		}
+		return errs
 	}
-	return nil
+	if len(keys) == 0 {
+		return errs
+	}
+
+	rt.doMany(ctx, "SetMany", false, errs, func(ctx context.Context, idx []int) []error {
+		cmds := make([]*redis.Cmd, len(idx))
+		rt.pipelined(ctx, func(pipeliner redis.Pipeliner) {
+			for i, k := range idx {
+				cmds[i] = setScript.EvalSha(ctx, pipeliner, routeKeys(keys[k]), rt.scriptArgs(keys[k], addrs[k], dur.Milliseconds())...)
+			}
+		})
+
+		results := make([]error, len(idx))
+		changes := make([]indexChange, 0, len(idx))
+		for i, cmd := range cmds {
+			k := idx[i]
+			old, _, err := parseSetResult(cmd)
+			if err != nil {
+				results[i] = wrapErr(err, "SetMany", "key", keys[k], "addr", addrs[k])
+				continue
+			}
+			changes = append(changes, indexChange{key: keys[k], old: old, new: addrs[k], dur: dur})
+		}
+		rt.reindex(ctx, "SetMany", changes...)
+		return results
+	})
+	return errs
+}
+
+// RenewMany resets the expiration of the keys owned by the addr in a pipeline,
+// ErrRouteTableNotFound is returned for the keys missing or owned by another addr.
+func (rt *RouteTable) RenewMany(ctx context.Context, keys []string, addr string, dur time.Duration) []error {
+	errs := make([]error, len(keys))
+	if dur <= 0 {
+		for i, key := range keys {
+			errs[i] = wrapErr(errors.Errorf("invalid expire time %s", dur), "RenewMany", "key", key)
+		}
+		return errs
+	}
+	if len(keys) == 0 {
+		return errs
+	}
+
+	rt.doMany(ctx, "RenewMany", true, errs, func(ctx context.Context, idx []int) []error {
+		cmds := make([]*redis.Cmd, len(idx))
+		rt.pipelined(ctx, func(pipeliner redis.Pipeliner) {
+			for i, k := range idx {
+				cmds[i] = renewScript.EvalSha(ctx, pipeliner, routeKeys(keys[k]), rt.scriptArgs(keys[k], addr, dur.Milliseconds())...)
+			}
+		})
+
+		results := make([]error, len(idx))
+		changes := make([]indexChange, 0, len(idx))
+		for i, cmd := range cmds {
+			renewed, err := cmd.Int64()
+			if err == nil && renewed == 0 {
+				err = redis.Nil
+			}
+			if err != nil {
+				results[i] = wrapErr(err, "RenewMany", "key", keys[idx[i]], "addr", addr)
+				continue
+			}
+			changes = append(changes, indexChange{key: keys[idx[i]], old: addr, new: addr, dur: dur})
+		}
+		rt.reindex(ctx, "RenewMany", changes...)
+		return results
+	})
+	return errs
+}
+
+func (rt *RouteTable) DelMany(ctx context.Context, keys []string) []error {
+	errs := make([]error, len(keys))
+	if len(keys) == 0 {
+		return errs
+	}
+
+	rt.doMany(ctx, "DelMany", false, errs, func(ctx context.Context, idx []int) []error {
+		cmds := make([]*redis.Cmd, len(idx))
+		rt.pipelined(ctx, func(pipeliner redis.Pipeliner) {
+			for i, k := range idx {
+				cmds[i] = delScript.EvalSha(ctx, pipeliner, routeKeys(keys[k]), rt.scriptArgs(keys[k])...)
+			}
+		})
+
+		results := make([]error, len(idx))
+		changes := make([]indexChange, 0, len(idx))
+		for i, cmd := range cmds {
+			k := idx[i]
+			old, err := cmd.Text()
+			if errors.Is(err, redis.Nil) {
+				continue
+			}
+			if err != nil {
+				results[i] = wrapErr(err, "DelMany", "key", keys[k])
+				continue
+			}
+			changes = append(changes, indexChange{key: keys[k], old: old})
+		}
+		rt.reindex(ctx, "DelMany", changes...)
+		return results
+	})
+	return errs
+}
+
+// ttlMillis is the expiration argument of expireScript like GETEX:
+// dur > 0 sets the expiration, dur == 0 removes it and dur < 0 keeps it unchanged.
+func ttlMillis(dur time.Duration) int64 {
+	switch {
+	case dur > 0:
+		return max(dur.Milliseconds(), 1)
+	case dur == 0:
+		return 0
+	default:
+		return -1
+	}
+}
+
+// parseSetResult parses the {old, gen} returned by setScript
+func parseSetResult(cmd *redis.Cmd) (old string, gen int64, err error) {
+	result, err := cmd.Slice()
+	if err != nil {
+		return "", 0, err
+	}
+	if len(result) != 2 {
+		return "", 0, errors.Errorf("unexpected script result: %v", result)
+	}
+	return toString(result[0]), toInt64(result[1]), nil
+}
+
+func toString(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+func toInt64(v interface{}) int64 {
+	i, _ := v.(int64)
+	return i
 }
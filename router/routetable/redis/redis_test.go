@@ -0,0 +1,279 @@
+package redis
+
+import (
+	"context"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	verrors "github.com/vulcan-frame/vulcan-pkg-app/errors"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable/routetabletest"
+)
+
+func TestConformance(t *testing.T) {
+	var mr *miniredis.Miniredis
+	routetabletest.RunConformance(t, func(t *testing.T) routetable.RouteTableData {
+		mr = miniredis.RunT(t)
+		rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		t.Cleanup(func() { _ = rdb.Close() })
+		return NewRouteTable(rdb)
+	}, routetabletest.WithAdvance(func(d time.Duration) {
+		mr.FastForward(d)
+	}))
+}
+
+// recoverHook clears the error of miniredis after it fails the commands for the times
+type recoverHook struct {
+	mr       *miniredis.Miniredis
+	msg      string
+	times    int
+	failures int
+}
+
+func (h *recoverHook) fail(msg string, times int) {
+	h.msg, h.times, h.failures = msg, times, 0
+	h.mr.SetError(msg)
+}
+
+func (h *recoverHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h *recoverHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		err := next(ctx, cmd)
+		h.recover(err)
+		return err
+	}
+}
+
+func (h *recoverHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		err := next(ctx, cmds)
+		h.recover(err)
+		return err
+	}
+}
+
+func (h *recoverHook) recover(err error) {
+	if err == nil || err.Error() != h.msg {
+		return
+	}
+	if h.failures++; h.failures >= h.times {
+		h.mr.SetError("")
+	}
+}
+
+func newRetryRouteTable(t *testing.T, opts ...Option) (*RouteTable, *recoverHook) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { _ = rdb.Close() })
+	hook := &recoverHook{mr: mr}
+	rdb.AddHook(hook)
+	return NewRouteTable(rdb, opts...), hook
+}
+
+func TestRetry(t *testing.T) {
+	ctx := context.Background()
+	policy := DefaultRetryPolicy()
+	policy.Backoff = time.Millisecond
+	rt, hook := newRetryRouteTable(t, WithRetry(policy))
+
+	hook.fail("LOADING Redis is loading the dataset in memory", 2)
+	ok, current, err := rt.SetNx(ctx, "k1", "a:1", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "a:1", current)
+	assert.Equal(t, 2, hook.failures)
+
+	// GetSet is not idempotent, but the rejected command is safe to retry
+	hook.fail("TRYAGAIN Multiple keys request during rehashing of slot", 1)
+	old, err := rt.GetSet(ctx, "k1", "b:1", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "a:1", old)
+
+	hook.fail("LOADING Redis is loading the dataset in memory", 1)
+	addrs, errs := rt.LoadMany(ctx, []string{"k1", "k2"})
+	assert.Equal(t, "b:1", addrs[0])
+	assert.NoError(t, errs[0])
+	assert.ErrorIs(t, errs[1], verrors.ErrRouteTableNotFound)
+}
+
+func TestRetry_Disabled(t *testing.T) {
+	ctx := context.Background()
+	rt, hook := newRetryRouteTable(t)
+
+	hook.fail("LOADING Redis is loading the dataset in memory", 1)
+	_, err := rt.Load(ctx, "k1")
+	assert.ErrorContains(t, err, "LOADING")
+}
+
+type redisError string
+
+func (e redisError) Error() string { return string(e) }
+
+func (redisError) RedisError() {}
+
+func TestRetry_Idempotent(t *testing.T) {
+	ctx := context.Background()
+	policy := DefaultRetryPolicy()
+	policy.Backoff = time.Millisecond
+	rt := NewRouteTable(nil, WithRetry(policy))
+
+	tests := []struct {
+		name       string
+		err        error
+		idempotent bool
+		calls      int
+	}{
+		{name: "timeout", err: context.DeadlineExceeded, idempotent: true, calls: 3},
+		{name: "timeout not idempotent", err: context.DeadlineExceeded, calls: 1},
+		{name: "eof not idempotent", err: io.EOF, calls: 1},
+		{name: "dial not idempotent", err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, calls: 3},
+		{name: "moved not idempotent", err: redisError("MOVED 3999 127.0.0.1:6381"), calls: 3},
+		{name: "not transient", err: redisError("ERR wrong number of arguments"), idempotent: true, calls: 1},
+		{name: "closed", err: redis.ErrClosed, idempotent: true, calls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := rt.do(ctx, "test", tt.idempotent, func(ctx context.Context) error {
+				calls++
+				return wrapErr(tt.err, "test")
+			})
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, tt.calls, calls)
+		})
+	}
+}
+
+func TestWatch_Publish(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+
+	// the writer doesn't watch, but its changes are published to the watchers
+	writer := NewRouteTable(rdb, WithPublish(true))
+	watcher := NewRouteTable(rdb, WithWatch(rdb))
+	ch, err := watcher.Watch(ctx, "r_player_{red}_{")
+	require.NoError(t, err)
+	assert.Equal(t, 1, mr.PubSubNumSub("rt_watch:r_player_{red}_")["rt_watch:r_player_{red}_"], "the channel is namespaced")
+
+	// the publish is disabled by default
+	require.NoError(t, NewRouteTable(rdb).Set(ctx, "r_player_{red}_{2}", "b:1", time.Minute))
+	require.NoError(t, writer.Set(ctx, "r_player_{red}_{1}", "a:1\x1f100", time.Minute))
+	require.NoError(t, writer.DelIfSame(ctx, "r_player_{red}_{1}", "a:1"))
+
+	want := []routetable.DataEvent{
+		{Key: "r_player_{red}_{1}", Kind: routetable.EventSet, New: "a:1\x1f100"},
+		{Key: "r_player_{red}_{1}", Kind: routetable.EventDelete, Old: "a:1\x1f100"},
+	}
+	for _, ev := range want {
+		select {
+		case got := <-ch:
+			assert.Equal(t, ev, got)
+		case <-time.After(time.Second):
+			t.Fatalf("event %v not received", ev)
+		}
+	}
+}
+
+func TestGenKey(t *testing.T) {
+	tests := []struct {
+		key string
+		gen string
+	}{
+		{key: "r_player_{red}_{1}", gen: "r_player_{red}_{1}:gen"},
+		{key: "r_player_red_{1}", gen: "r_player_red_{1}:gen"},
+		{key: "player:1", gen: "{player:1}:gen"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.gen, genKey(tt.key))
+		assert.Equal(t, slotKey(tt.key), slotKey(genKey(tt.key)), "in the same slot")
+	}
+
+	// the generation keys of the untagged keys are out of the prefix
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+	rt := NewRouteTable(rdb)
+
+	require.NoError(t, rt.Set(ctx, "player:1", "a:1", time.Minute))
+	assert.True(t, mr.Exists("{player:1}:gen"))
+	var keys []string
+	require.NoError(t, rt.Scan(ctx, "player:", func(batch []string) error {
+		keys = append(keys, batch...)
+		return nil
+	}))
+	assert.Equal(t, []string{"player:1"}, keys)
+}
+
+func TestIndex(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+	rt := NewRouteTable(rdb)
+
+	tests := []struct {
+		name   string
+		key    string
+		prefix string
+		index  string
+		script bool
+	}{
+		{name: "in the slot", key: "r_player_{red}_{1}", prefix: "r_player_{red}_{", index: "ri_r_player_{red}_", script: true},
+		{name: "out of the slot", key: "r_player_red_{1}", prefix: "r_player_red_{", index: "ri_r_player_red_"},
+		{name: "no namespace", key: "player:1", prefix: "player:", index: "ri_"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.script, scriptIndex(tt.key) != "")
+			idx := func(addr string) string { return indexKey(prefixNamespace(tt.prefix), addr) }
+			if tt.index != "ri_" {
+				assert.Equal(t, tt.index+"a:1", idx("a:1"))
+			}
+
+			require.NoError(t, rt.Set(ctx, tt.key, "a:1", time.Minute))
+			members, err := mr.ZMembers(idx("a:1"))
+			require.NoError(t, err)
+			assert.Equal(t, []string{tt.key}, members)
+			assert.GreaterOrEqual(t, mr.TTL(idx("a:1")), time.Minute, "the index expires no earlier than the route")
+
+			// the member of the old address is removed by the overwrite
+			_, err = rt.GetSet(ctx, tt.key, "b:1", time.Hour)
+			require.NoError(t, err)
+			assert.False(t, mr.Exists(idx("a:1")))
+			assert.GreaterOrEqual(t, mr.TTL(idx("b:1")), time.Hour)
+
+			keys, _, err := rt.ListByAddr(ctx, "b:1", tt.prefix, "", 10)
+			require.NoError(t, err)
+			assert.Equal(t, []string{tt.key}, keys)
+
+			require.NoError(t, rt.Del(ctx, tt.key))
+			assert.False(t, mr.Exists(idx("b:1")))
+		})
+	}
+
+	// the indexes of the tables are not shared
+	require.NoError(t, rt.Set(ctx, "r_player_{red}_{1}", "a:1", time.Minute))
+	require.NoError(t, rt.Set(ctx, "r_guild_{red}_{1}", "a:1", time.Minute))
+	members, err := mr.ZMembers("ri_r_player_{red}_a:1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"r_player_{red}_{1}"}, members)
+
+	// the renewal extends the index
+	errs := rt.RenewMany(ctx, []string{"r_player_{red}_{1}"}, "a:1", time.Hour)
+	require.NoError(t, errs[0])
+	assert.GreaterOrEqual(t, mr.TTL("ri_r_player_{red}_a:1"), time.Hour)
+}
@@ -0,0 +1,231 @@
+package redis
+
+import (
+	"context"
+	"io"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+const (
+	meterName = "github.com/vulcan-frame/vulcan-pkg-app/router/routetable/redis"
+
+	defaultRetryAttempts = 3
+	defaultRetryBackoff  = 20 * time.Millisecond
+	defaultRetryMaxDelay = 500 * time.Millisecond
+	defaultRetryJitter   = 0.5
+)
+
+// ErrorKind is the kind of the transient errors, the kinds can be combined by OR
+type ErrorKind uint8
+
+const (
+	ErrorKindTimeout  ErrorKind = 1 << iota // the attempt times out, the command may have been executed
+	ErrorKindConn                           // the connection is refused, reset or closed
+	ErrorKindLoading                        // LOADING, the server is loading the dataset
+	ErrorKindTryAgain                       // TRYAGAIN and CLUSTERDOWN, the cluster is resharding or failing over
+	ErrorKindMoved                          // MOVED and ASK which the client fails to follow, the slot is migrating
+
+	ErrorKindAll = ErrorKindTimeout | ErrorKindConn | ErrorKindLoading | ErrorKindTryAgain | ErrorKindMoved
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrorKindTimeout:
+		return "timeout"
+	case ErrorKindConn:
+		return "conn"
+	case ErrorKindLoading:
+		return "loading"
+	case ErrorKindTryAgain:
+		return "tryagain"
+	case ErrorKindMoved:
+		return "moved"
+	}
+	return "unknown"
+}
+
+// RetryPolicy is the retry of the transient errors, each attempt has the timeout of WithTimeout.
+// The operations which are not idempotent, such as Set, GetSet, CompareAndSwap and Del, are retried only if
+// the command is known to be rejected before it's executed, so the timeouts and the broken connections are not retried for them.
+// The retries of the redis client (Options.MaxRetries) are made within each attempt.
+type RetryPolicy struct {
+	MaxAttempts int           // the max attempts including the first one, the retry is disabled if it's <= 1
+	Backoff     time.Duration // the backoff before the first retry, it's doubled by each retry
+	MaxBackoff  time.Duration // the max backoff
+	Jitter      float64       // the random fraction of the backoff which is cut off, in [0, 1]
+	Kinds       ErrorKind     // the retryable error kinds
+}
+
+// DefaultRetryPolicy returns the policy of 3 attempts, the backoff starts from 20ms with 50% jitter and all the error kinds are retried
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: defaultRetryAttempts,
+		Backoff:     defaultRetryBackoff,
+		MaxBackoff:  defaultRetryMaxDelay,
+		Jitter:      defaultRetryJitter,
+		Kinds:       ErrorKindAll,
+	}
+}
+
+// WithRetry enables the retry of the transient errors, see RetryPolicy
+func WithRetry(policy RetryPolicy) Option {
+	return func(r *RouteTable) {
+		r.retryPolicy = policy
+	}
+}
+
+func (p RetryPolicy) enabled() bool {
+	return p.MaxAttempts > 1
+}
+
+// backoff returns the backoff before the nth retry, n starts from 1
+func (p RetryPolicy) backoff(n int) time.Duration {
+	d := p.MaxBackoff
+	if n <= 30 {
+		if exp := p.Backoff << (n - 1); exp > 0 && exp < d {
+			d = exp
+		}
+	}
+	if p.Jitter > 0 {
+		d -= time.Duration(min(p.Jitter, 1) * rand.Float64() * float64(d))
+	}
+	return d
+}
+
+// classify returns the kind of the transient error, 0 if the error is not transient.
+// ambiguous is true if the command may have been executed by the server.
+func classify(err error) (kind ErrorKind, ambiguous bool) {
+	if err == nil || errors.Is(err, redis.ErrClosed) {
+		return 0, false
+	}
+
+	var rerr redis.Error
+	if errors.As(err, &rerr) {
+		msg := rerr.Error()
+		switch {
+		case strings.HasPrefix(msg, "LOADING "):
+			return ErrorKindLoading, false
+		case strings.HasPrefix(msg, "TRYAGAIN "), strings.HasPrefix(msg, "CLUSTERDOWN "):
+			return ErrorKindTryAgain, false
+		case strings.HasPrefix(msg, "MOVED "), strings.HasPrefix(msg, "ASK "):
+			return ErrorKindMoved, false
+		}
+		return 0, false
+	}
+
+	// the command is not sent if the dial fails
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return ErrorKindConn, false
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return ErrorKindTimeout, true
+	}
+	if opErr != nil || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return ErrorKindConn, true
+	}
+	return 0, false
+}
+
+// retryable returns the kind of err if it should be retried, the ambiguous errors are retried only for the idempotent operations
+func (rt *RouteTable) retryable(err error, idempotent bool) (ErrorKind, bool) {
+	kind, ambiguous := classify(err)
+	if kind == 0 || rt.retryPolicy.Kinds&kind == 0 || (ambiguous && !idempotent) {
+		return kind, false
+	}
+	return kind, true
+}
+
+func (rt *RouteTable) initMetrics() {
+	var err error
+	if rt.retries, err = otel.Meter(meterName).Int64Counter("routetable.redis.retries",
+		metric.WithDescription("The count of the retries of the redis route table operations")); err != nil {
+		log.Errorf("%s create routetable.redis.retries metric failed. err=%+v", errPrefix, err)
+		rt.retries = noop.Int64Counter{}
+	}
+}
+
+// wait records the nth retry of the operation and sleeps for the backoff, it returns false if the ctx is done
+func (rt *RouteTable) wait(ctx context.Context, op string, n int, kind ErrorKind, err error) bool {
+	backoff := rt.retryPolicy.backoff(n)
+	log.Warnf("%s %s retry. attempt=%d backoff=%s kind=%s err=%v", errPrefix, op, n+1, backoff, kind, err)
+	rt.retries.Add(ctx, 1, metric.WithAttributes(attribute.String("op", op), attribute.String("kind", kind.String())))
+
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// do calls fn with the timeout of each attempt, and retries the transient errors by the policy.
+// idempotent tells whether fn is safe to be executed more than once.
+func (rt *RouteTable) do(ctx context.Context, op string, idempotent bool, fn func(ctx context.Context) error) error {
+	for n := 1; ; n++ {
+		actx, cancel := context.WithTimeout(ctx, rt.timeout)
+		err := fn(actx)
+		cancel()
+
+		if err == nil || n >= rt.retryPolicy.MaxAttempts || ctx.Err() != nil {
+			return err
+		}
+		kind, ok := rt.retryable(err, idempotent)
+		if !ok || !rt.wait(ctx, op, n, kind, err) {
+			return err
+		}
+	}
+}
+
+// doMany is do for the batch operations, fn is called with the indexes of the keys and returns their errors.
+// Only the keys failed by the transient errors are retried.
+func (rt *RouteTable) doMany(ctx context.Context, op string, idempotent bool, errs []error, fn func(ctx context.Context, idx []int) []error) {
+	idx := make([]int, len(errs))
+	for i := range idx {
+		idx[i] = i
+	}
+
+	for n := 1; ; n++ {
+		actx, cancel := context.WithTimeout(ctx, rt.timeout)
+		results := fn(actx, idx)
+		cancel()
+
+		var (
+			retry []int
+			kind  ErrorKind
+			first error
+		)
+		for i, err := range results {
+			errs[idx[i]] = err
+			if k, ok := rt.retryable(err, idempotent); ok {
+				if first == nil {
+					kind, first = k, err
+				}
+				retry = append(retry, idx[i])
+			}
+		}
+		if len(retry) == 0 || n >= rt.retryPolicy.MaxAttempts || ctx.Err() != nil {
+			return
+		}
+		if !rt.wait(ctx, op, n, kind, first) {
+			return
+		}
+		idx = retry
+	}
+}
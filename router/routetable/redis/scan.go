@@ -0,0 +1,115 @@
+package redis
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	scanCount = 100
+)
+
+// Scan iterates the keys with the prefix by SCAN. For redis cluster, all the masters are scanned
+// concurrently and the calls of fn are serialized.
+func (rt *RouteTable) Scan(ctx context.Context, prefix string, fn func(keys []string) error) error {
+	match := globEscaper.Replace(prefix) + "*"
+
+	cc, ok := rt.rdb.(*redis.ClusterClient)
+	if !ok {
+		return rt.scan(ctx, rt.rdb, match, fn)
+	}
+
+	var mu sync.Mutex
+	return cc.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+		return rt.scan(ctx, client, match, func(keys []string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			return fn(keys)
+		})
+	})
+}
+
+func (rt *RouteTable) scan(ctx context.Context, c redis.Cmdable, match string, fn func(keys []string) error) error {
+	var cursor uint64
+	for {
+		keys, next, err := rt.scanPage(ctx, c, cursor, match)
+		if err != nil {
+			return wrapErr(err, "Scan", "match", match, "cursor", cursor)
+		}
+		keys = excludeGenKeys(keys)
+		if len(keys) > 0 {
+			if err := fn(keys); err != nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+func (rt *RouteTable) scanPage(ctx context.Context, c redis.Cmdable, cursor uint64, match string) ([]string, uint64, error) {
+	var (
+		keys []string
+		next uint64
+	)
+	err := rt.do(ctx, "Scan", true, func(ctx context.Context) error {
+		var err error
+		keys, next, err = c.Scan(ctx, cursor, match, scanCount).Result()
+		return err
+	})
+	return keys, next, err
+}
+
+func excludeGenKeys(keys []string) []string {
+	result := keys[:0]
+	for _, key := range keys {
+		if !strings.HasSuffix(key, genSuffix) {
+			result = append(result, key)
+		}
+	}
+	return result
+}
+
+// LoadManyTTL loads the values with the remaining ttl in a pipeline, the ttl is 0 if the key has no expiration
+func (rt *RouteTable) LoadManyTTL(ctx context.Context, keys []string) ([]string, []time.Duration, []error) {
+	values := make([]string, len(keys))
+	ttls := make([]time.Duration, len(keys))
+	errs := make([]error, len(keys))
+	if len(keys) == 0 {
+		return values, ttls, errs
+	}
+
+	rt.doMany(ctx, "LoadManyTTL", true, errs, func(ctx context.Context, idx []int) []error {
+		getCmds := make([]*redis.StringCmd, len(idx))
+		ttlCmds := make([]*redis.DurationCmd, len(idx))
+		_, _ = rt.rdb.Pipelined(ctx, func(pipeliner redis.Pipeliner) error {
+			for i, k := range idx {
+				getCmds[i] = pipeliner.Get(ctx, keys[k])
+				ttlCmds[i] = pipeliner.PTTL(ctx, keys[k])
+			}
+			return nil
+		})
+
+		results := make([]error, len(idx))
+		for i, k := range idx {
+			val, err := getCmds[i].Result()
+			if err != nil {
+				results[i] = wrapErr(err, "LoadManyTTL", "key", keys[k])
+				continue
+			}
+			values[k] = val
+			// go-redis returns -1 if the key has no expiration, and -2 if it is expired after GET
+			if ttl, err := ttlCmds[i].Result(); err == nil && ttl > 0 {
+				ttls[k] = ttl
+			}
+		}
+		return results
+	})
+	return values, ttls, errs
+}
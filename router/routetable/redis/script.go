@@ -0,0 +1,246 @@
+package redis
+
+import (
+	"context"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// genSuffix is the suffix of the generation key, the generation key is in the same slot as the route key, see genKey
+	genSuffix = ":gen"
+)
+
+// genScriptPrefix is shared by the scripts writing the routes, KEYS[1] is the route key and KEYS[2] is the generation key.
+// The generation is max(the last generation + 1, the server time in microseconds), so it keeps increasing after the key is deleted.
+const genScriptPrefix = `
+local function nextGen()
+    local t = redis.call("TIME")
+    local gen = tonumber(t[1]) * 1000000 + tonumber(t[2])
+    local last = tonumber(redis.call("GET", KEYS[2]) or "0")
+    if gen <= last then
+        gen = last + 1
+    end
+    return gen
+end
+
+local function store(addr, ttl, gen)
+    if ttl > 0 then
+        redis.call("SET", KEYS[1], addr, "PX", ttl)
+        redis.call("SET", KEYS[2], string.format("%.0f", gen), "PX", ttl)
+    else
+        redis.call("SET", KEYS[1], addr)
+        redis.call("SET", KEYS[2], string.format("%.0f", gen))
+    end
+end
+`
+
+// addrOfScriptPrefix returns the address of the value like routetable.AddrOf, the separator is "\x1f"
+const addrOfScriptPrefix = `
+local function addrOf(v)
+    local i = string.find(v, "\31", 1, true)
+    if i then
+        return string.sub(v, 1, i - 1)
+    end
+    return v
+end
+`
+
+// indexScriptPrefix maintains the index of the addresses and publishes the changes, the last two ARGV are appended by
+// scriptArgs. The first is the prefix of the index keys, see indexKey, it's empty when the index is out of the slot of the
+// route key and maintained by the caller instead. The index keys are not declared in KEYS, they are in the same slot as
+// the route key when the prefix is not empty. The second is the watch channel, it's empty when the publish is disabled.
+const indexScriptPrefix = addrOfScriptPrefix + `
+local function indexAdd(idx, key, ttl)
+    local existed = redis.call("EXISTS", idx)
+    local t = redis.call("TIME")
+    redis.call("ZADD", idx, tonumber(t[1]) * 1000000 + tonumber(t[2]), key)
+    if ttl == 0 then
+        redis.call("PERSIST", idx)
+    elseif ttl > 0 then
+        local pttl = redis.call("PTTL", idx)
+        if existed == 0 or (pttl >= 0 and pttl < ttl) then
+            redis.call("PEXPIRE", idx, ttl)
+        end
+    end
+end
+
+local function reindex(key, old, new, ttl)
+    local prefix = ARGV[#ARGV - 1]
+    if prefix == "" then
+        return
+    end
+    if old and (not new or addrOf(old) ~= addrOf(new)) then
+        redis.call("ZREM", prefix .. addrOf(old), key)
+    end
+    if new then
+        indexAdd(prefix .. addrOf(new), key, ttl)
+    end
+end
+
+local function notify(key, kind, old, new)
+    local channel = ARGV[#ARGV]
+    if channel == "" then
+        return
+    end
+    redis.call("PUBLISH", channel, cjson.encode({k = key, t = kind, o = old or nil, n = new or nil}))
+end
+`
+
+var (
+	// setScript returns {old, gen}
+	setScript = redis.NewScript(genScriptPrefix + indexScriptPrefix + `
+local old = redis.call("GET", KEYS[1])
+local gen = nextGen()
+store(ARGV[1], tonumber(ARGV[2]), gen)
+reindex(KEYS[1], old, ARGV[1], tonumber(ARGV[2]))
+notify(KEYS[1], "set", old, ARGV[1])
+return {old, gen}`)
+
+	// setNxScript returns {ok, current, gen}
+	setNxScript = redis.NewScript(genScriptPrefix + indexScriptPrefix + `
+local cur = redis.call("GET", KEYS[1])
+if cur then
+    return {0, cur, tonumber(redis.call("GET", KEYS[2]) or "0")}
+end
+local gen = nextGen()
+store(ARGV[1], tonumber(ARGV[2]), gen)
+reindex(KEYS[1], false, ARGV[1], tonumber(ARGV[2]))
+notify(KEYS[1], "set", false, ARGV[1])
+return {1, ARGV[1], gen}`)
+
+	// casScript sets the route only if the generation is ARGV[1], the generation of the missing key is 0.
+	// It returns {ok, old, gen}, the gen is the new generation when ok, otherwise the current one.
+	casScript = redis.NewScript(genScriptPrefix + indexScriptPrefix + `
+local old = redis.call("GET", KEYS[1])
+local gen = 0
+if old then
+    gen = tonumber(redis.call("GET", KEYS[2]) or "0")
+end
+if gen ~= tonumber(ARGV[1]) then
+    return {0, old, gen}
+end
+gen = nextGen()
+store(ARGV[2], tonumber(ARGV[3]), gen)
+reindex(KEYS[1], old, ARGV[2], tonumber(ARGV[3]))
+notify(KEYS[1], "set", old, ARGV[2])
+return {1, old, gen}`)
+
+	// delScript deletes the route and the generation, returns the old value
+	delScript = redis.NewScript(indexScriptPrefix + `
+local old = redis.call("GET", KEYS[1])
+redis.call("DEL", KEYS[1], KEYS[2])
+if old then
+    reindex(KEYS[1], old, false, 0)
+    notify(KEYS[1], "delete", old, false)
+end
+return old`)
+
+	// delIfSameScript compares the addresses of the values, returns {1, deleted} when the route is deleted, {2} when the address is not the same
+	delIfSameScript = redis.NewScript(indexScriptPrefix + `
+local cur = redis.call("GET", KEYS[1])
+if cur and addrOf(cur) == addrOf(ARGV[1]) then
+    redis.call("DEL", KEYS[1], KEYS[2])
+    reindex(KEYS[1], cur, false, 0)
+    notify(KEYS[1], "delete", cur, false)
+    return {1, cur}
+else
+    return {2}
+end`)
+
+	// renewScript resets the expiration of the route and the generation if the route is owned by the address of ARGV[1],
+	// returns 1 when renewed, 0 when the route is missing or owned by another address
+	renewScript = redis.NewScript(indexScriptPrefix + `
+local cur = redis.call("GET", KEYS[1])
+if cur and addrOf(cur) == addrOf(ARGV[1]) then
+    redis.call("PEXPIRE", KEYS[1], ARGV[2])
+    redis.call("PEXPIRE", KEYS[2], ARGV[2])
+    reindex(KEYS[1], cur, cur, tonumber(ARGV[2]))
+    return 1
+end
+return 0`)
+
+	// expireScript sets the expiration of the route and the generation like GETEX: ARGV[1] > 0 sets the expiration,
+	// 0 removes it and < 0 keeps it unchanged. It returns the value, or nil when the route is missing.
+	expireScript = redis.NewScript(indexScriptPrefix + `
+local cur = redis.call("GET", KEYS[1])
+if not cur then
+    return false
+end
+local ttl = tonumber(ARGV[1])
+if ttl > 0 then
+    redis.call("PEXPIRE", KEYS[1], ttl)
+    redis.call("PEXPIRE", KEYS[2], ttl)
+elseif ttl == 0 then
+    redis.call("PERSIST", KEYS[1])
+    redis.call("PERSIST", KEYS[2])
+end
+reindex(KEYS[1], cur, cur, ttl)
+return cur`)
+
+	// addIndexScript adds ARGV[1] to the index KEYS[1] and extends its expiration to ARGV[2] at least,
+	// it's used when the index is out of the slot of the route key, so it has no scriptArgs
+	addIndexScript = redis.NewScript(indexScriptPrefix + `
+indexAdd(KEYS[1], ARGV[1], tonumber(ARGV[2]))
+return 1`)
+
+	scripts = []*redis.Script{setScript, setNxScript, casScript, delScript, delIfSameScript, renewScript, expireScript, addIndexScript}
+)
+
+// genKey returns the generation key in the slot of the route key, the scripts take both keys.
+// The key with a hash tag keeps it before the suffix, and the key without one is wrapped as the hash tag,
+// so the whole key is hashed for both. The key without a hash tag but with "}" can't be wrapped,
+// its generation key is in another slot and the scripts fail with CROSSSLOT on Redis Cluster.
+func genKey(key string) string {
+	if _, ok := hashTag(key); ok || strings.Contains(key, "}") {
+		return key + genSuffix
+	}
+	return "{" + key + "}" + genSuffix
+}
+
+// scriptArgs appends the ARGV of the index and the publish to the args of the script, see indexScriptPrefix
+func (rt *RouteTable) scriptArgs(key string, args ...interface{}) []interface{} {
+	channel := ""
+	if rt.publish {
+		channel = rt.channel(keyNamespace(key))
+	}
+	return append(args, scriptIndex(key), channel)
+}
+
+// routeKeys returns the KEYS of the scripts
+func routeKeys(key string) []string {
+	return []string{key, genKey(key)}
+}
+
+// pipelined runs the pipeline with the scripts by EVALSHA,
+// the scripts are loaded and the pipeline is retried once if they are not cached by the server.
+// It returns the commands of the last run.
+func (rt *RouteTable) pipelined(ctx context.Context, fn func(pipeliner redis.Pipeliner)) []redis.Cmder {
+	cmds, _ := rt.rdb.Pipelined(ctx, func(pipeliner redis.Pipeliner) error {
+		fn(pipeliner)
+		return nil
+	})
+
+	noScript := false
+	for _, cmd := range cmds {
+		if err := cmd.Err(); err != nil && redis.HasErrorPrefix(err, "NOSCRIPT") {
+			noScript = true
+			break
+		}
+	}
+	if !noScript {
+		return cmds
+	}
+
+	for _, s := range scripts {
+		if err := s.Load(ctx, rt.rdb).Err(); err != nil {
+			return cmds
+		}
+	}
+	cmds, _ = rt.rdb.Pipelined(ctx, func(pipeliner redis.Pipeliner) error {
+		fn(pipeliner)
+		return nil
+	})
+	return cmds
+}
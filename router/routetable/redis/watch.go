@@ -0,0 +1,88 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+)
+
+const (
+	defaultWatchChannel = "rt_watch"
+	// expiredPattern is the keyspace notifications of the expired keys, it requires `notify-keyspace-events Ex` on the redis server
+	expiredPattern  = "__keyevent@*__:expired"
+	watchBufferSize = 1024
+)
+
+func (rt *RouteTable) watching() bool {
+	return rt.sub != nil
+}
+
+// channel returns the watch channel of the namespace of the keys, see keyNamespace
+func (rt *RouteTable) channel(ns string) string {
+	if ns == "" {
+		return rt.watchChannel
+	}
+	return rt.watchChannel + ":" + ns
+}
+
+// Watch subscribes the changes of the keys with the prefix published by the route tables with the same watch channel,
+// and the expired keys from the keyspace notifications. The old value of the expired key is not provided.
+// The changes are published to the channel of the namespace of the keys, see keyNamespace.
+func (rt *RouteTable) Watch(ctx context.Context, prefix string) (<-chan routetable.DataEvent, error) {
+	if !rt.watching() {
+		return nil, wrapErr(errors.New("watch is not enabled"), "Watch", "prefix", prefix)
+	}
+
+	ps := rt.sub.Subscribe(ctx, rt.channel(prefixNamespace(prefix)))
+	if err := ps.PSubscribe(ctx, expiredPattern); err != nil {
+		_ = ps.Close()
+		return nil, wrapErr(err, "Watch", "prefix", prefix)
+	}
+	if _, err := ps.Receive(ctx); err != nil {
+		_ = ps.Close()
+		return nil, wrapErr(err, "Watch", "prefix", prefix)
+	}
+
+	ch := make(chan routetable.DataEvent, watchBufferSize)
+	go func() {
+		defer close(ch)
+		defer ps.Close()
+
+		msgs := ps.Channel()
+		for {
+			var msg *redis.Message
+			select {
+			case <-ctx.Done():
+				return
+			case m, ok := <-msgs:
+				if !ok {
+					return
+				}
+				msg = m
+			}
+
+			var ev routetable.DataEvent
+			if msg.Pattern == expiredPattern {
+				ev = routetable.DataEvent{Key: msg.Payload, Kind: routetable.EventExpire}
+			} else if err := json.Unmarshal([]byte(msg.Payload), &ev); err != nil {
+				log.Errorf("%s decode watch event failed. payload=%s err=%+v", errPrefix, msg.Payload, err)
+				continue
+			}
+			if !strings.HasPrefix(ev.Key, prefix) || strings.HasSuffix(ev.Key, genSuffix) {
+				continue
+			}
+
+			select {
+			case ch <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
@@ -2,7 +2,6 @@ package routetable
 
 import (
 	"context"
-	"fmt"
 	"time"
 )
 
@@ -22,6 +21,127 @@ type ReadOnlyRouteTable interface {
 	Load(ctx context.Context, color string, key int64) (addr string, err error)
 }
 
+// ExtendedRouteTable is RouteTable with the generations, the metadata, the batches, the index and the draining marks.
+// It's implemented by BaseRouteTable, use Extended to adapt the other implementations of RouteTable.
+type ExtendedRouteTable interface {
+	RouteTable
+
+	// LoadGen loads the addr and its generation, the generation increases monotonically on every write of the route,
+	// so it can be used as the fencing token of the owner
+	LoadGen(ctx context.Context, color string, key int64) (addr string, gen int64, err error)
+	// GetSetGen is GetSet which also returns the new generation
+	GetSetGen(ctx context.Context, color string, key int64, addr string) (old string, gen int64, err error)
+	// SetNxGen is SetNx which also returns the generation of the result
+	SetNxGen(ctx context.Context, color string, key int64, addr string) (ok bool, result string, gen int64, err error)
+	// CompareAndSwap sets the addr only if the current generation is expectedGen, the generation of the missing route is 0.
+	// It returns the new generation when swapped, otherwise the current generation.
+	CompareAndSwap(ctx context.Context, color string, key int64, expectedGen int64, addr string) (ok bool, gen int64, err error)
+
+	// LoadEntry loads the route with its metadata, the route written by the string-address API has only the Addr
+	LoadEntry(ctx context.Context, color string, key int64) (entry RouteEntry, err error)
+	// StoreEntry, GetSetEntry and SetNxEntry are the string-address methods with the metadata of the route
+	StoreEntry(ctx context.Context, color string, key int64, entry RouteEntry) error
+	GetSetEntry(ctx context.Context, color string, key int64, entry RouteEntry) (old RouteEntry, err error)
+	SetNxEntry(ctx context.Context, color string, key int64, entry RouteEntry) (ok bool, result RouteEntry, err error)
+
+	// LoadMany loads the addrs of the keys, the addrs and errs are in the same order as the keys
+	LoadMany(ctx context.Context, color string, keys []int64) (addrs []string, errs []error)
+	// StoreMany stores the addrs of the keys, the errs are in the same order as the keys
+	StoreMany(ctx context.Context, color string, keys []int64, addrs []string) (errs []error)
+	// DelMany deletes the keys, the errs are in the same order as the keys
+	DelMany(ctx context.Context, color string, keys []int64) (errs []error)
+	// Renew resets the ttl of the routes owned by the addr, it is called by the owner to keep its leases alive.
+	// The errs are in the same order as the keys, ErrRouteTableNotFound means the route is lost.
+	Renew(ctx context.Context, color string, keys []int64, addr string) (errs []error)
+
+	// Watch returns the changes of the routes in the color, the channel is closed when the ctx is done
+	// or after the EventError event when the watch fails
+	Watch(ctx context.Context, color string) (<-chan Event, error)
+	// ListByAddr pages through the keys routed to the addr, start with the empty cursor and stop when the next cursor is empty.
+	// The cursor is opaque and the count is a hint of the page size. The routes of the non-int64 oids are skipped.
+	ListByAddr(ctx context.Context, color string, addr string, cursor string, count int64) (keys []int64, next string, err error)
+
+	DrainingRouteTable
+}
+
+// DrainingRouteTable marks the draining nodes, the draining node is not assigned new routes by the master balancer
+type DrainingRouteTable interface {
+	// SetDraining marks or unmarks the node as draining
+	SetDraining(ctx context.Context, color string, addr string, draining bool) error
+	// Draining returns whether the nodes are draining, the result is in the same order as the addrs
+	Draining(ctx context.Context, color string, addrs []string) (draining []bool, err error)
+}
+
+// ObjectRouteTable is keyed by the string oids, such as the room codes, the UUIDs or the ObjectKey of the context package.
+// The int64 oids of RouteTable are the same routes as their decimal strings.
+// It's implemented by BaseRouteTable, use Objects to adapt the other implementations of RouteTable.
+type ObjectRouteTable interface {
+	LoadObject(ctx context.Context, color string, oid string) (addr string, err error)
+	LoadAndExpireObject(ctx context.Context, color string, oid string) (addr string, err error)
+	LoadGenObject(ctx context.Context, color string, oid string) (addr string, gen int64, err error)
+	LoadEntryObject(ctx context.Context, color string, oid string) (entry RouteEntry, err error)
+	StoreObject(ctx context.Context, color string, oid string, addr string) error
+	StoreEntryObject(ctx context.Context, color string, oid string, entry RouteEntry) error
+	GetSetGenObject(ctx context.Context, color string, oid string, addr string) (old string, gen int64, err error)
+	GetSetEntryObject(ctx context.Context, color string, oid string, entry RouteEntry) (old RouteEntry, err error)
+	SetNxEntryObject(ctx context.Context, color string, oid string, entry RouteEntry) (ok bool, result RouteEntry, err error)
+	CompareAndSwapObject(ctx context.Context, color string, oid string, expectedGen int64, addr string) (ok bool, gen int64, err error)
+	DelDelayObject(ctx context.Context, color string, oid string, delay time.Duration) error
+	DelIfSameObject(ctx context.Context, color string, oid string, value string) error
+	DelObject(ctx context.Context, color string, oid string) error
+	// StoreManyObjects, DelManyObjects and RenewObjects are StoreMany, DelMany and Renew of the string oids
+	StoreManyObjects(ctx context.Context, color string, oids []string, addrs []string) (errs []error)
+	DelManyObjects(ctx context.Context, color string, oids []string) (errs []error)
+	RenewObjects(ctx context.Context, color string, oids []string, addr string) (errs []error)
+	// ListObjectsByAddr is ListByAddr of all the oids
+	ListObjectsByAddr(ctx context.Context, color string, addr string, cursor string, count int64) (oids []string, next string, err error)
+
+	DrainingRouteTable
+}
+
+// The optional interfaces of RouteTable below are implemented by BaseRouteTable,
+// the decorators and the tools find them by the type assertions.
+
+// Ranger iterates the routes of the color, see BaseRouteTable.Range
+type Ranger interface {
+	Range(ctx context.Context, color string, fn func(oids []string, entries []RouteEntry) error) error
+}
+
+// TTLReader returns the remaining ttl of the routes
+type TTLReader interface {
+	TTL(ctx context.Context, color string, oid int64) (time.Duration, error)
+	TTLObject(ctx context.Context, color string, oid string) (time.Duration, error)
+}
+
+// Exporter writes the routes of the color to the snapshot, see BaseRouteTable.Export
+type Exporter interface {
+	Export(ctx context.Context, color string, w SnapshotWriter) (int, error)
+}
+
+// Importer reads the routes of the color from the snapshot, see BaseRouteTable.Import
+type Importer interface {
+	Import(ctx context.Context, color string, rd SnapshotReader, mode ImportMode) (ImportResult, error)
+}
+
+// SIDMerger moves the routes of a SID scope into another one, see BaseRouteTable.MergeSID
+type SIDMerger interface {
+	MergeSID(ctx context.Context, color string, from, to int64) (MergeResult, error)
+}
+
+// KeyMigrator moves the routes between the key formats, see BaseRouteTable.MigrateKeys
+type KeyMigrator interface {
+	MigrateKeys(ctx context.Context, color string, from, to KeyFormat) (int, error)
+}
+
+// ScopeMigrator moves the unscoped routes into the scope of the ctx, see BaseRouteTable.MigrateToScope
+type ScopeMigrator interface {
+	MigrateToScope(ctx context.Context, color string) (int, error)
+}
+
+// RouteTableData stores the routes as the encoded RouteEntry values.
+// The implementations index the values and compare them in DelIfSame by the address, see AddrOf.
+// The other features are the optional interfaces below, BaseRouteTable falls back or fails with
+// ErrRouteTableUnsupported when the backend doesn't implement them.
 type RouteTableData interface {
 	Load(ctx context.Context, key string) (addr string, err error)
 	LoadAndExpire(ctx context.Context, key string, dur time.Duration) (string, error)
@@ -33,10 +153,58 @@ type RouteTableData interface {
 	Del(ctx context.Context, key string) error
 }
 
-func NewRouteTable(name string, rt RouteTableData, opts ...Option) RouteTable {
-	return NewBaseRouteTable(rt, name, key, opts...)
+// GenRouteTableData is implemented by the backends which keep the generations of the routes,
+// it's required by the generation methods and CompareAndSwap of RouteTable.
+type GenRouteTableData interface {
+	LoadGen(ctx context.Context, key string) (addr string, gen int64, err error)
+	GetSetGen(ctx context.Context, key string, addr string, dur time.Duration) (old string, gen int64, err error)
+	SetNxGen(ctx context.Context, key string, addr string, dur time.Duration) (ok bool, result string, gen int64, err error)
+	CompareAndSwap(ctx context.Context, key string, expectedGen int64, addr string, dur time.Duration) (ok bool, gen int64, err error)
+}
+
+// BatchRouteTableData is implemented by the backends which read and write the keys in batches,
+// the keys are handled one by one if the backend doesn't implement it.
+type BatchRouteTableData interface {
+	LoadMany(ctx context.Context, keys []string) (addrs []string, errs []error)
+	SetMany(ctx context.Context, keys []string, addrs []string, dur time.Duration) (errs []error)
+	DelMany(ctx context.Context, keys []string) (errs []error)
+	// RenewMany resets the expiration of the keys owned by the addr,
+	// ErrRouteTableNotFound is returned for the keys missing or owned by another addr
+	RenewMany(ctx context.Context, keys []string, addr string, dur time.Duration) (errs []error)
+}
+
+// WatchRouteTableData is implemented by the backends which deliver the changes of the keys
+type WatchRouteTableData interface {
+	// Watch returns the changes of the keys with the prefix, the channel is closed when the ctx is done
+	// or after the EventError event when the watch fails
+	Watch(ctx context.Context, prefix string) (<-chan DataEvent, error)
+}
+
+// IndexRouteTableData is implemented by the backends which index the keys by the address of the values
+type IndexRouteTableData interface {
+	// ListByAddr pages through the keys with the prefix routed to the addr, the next cursor is empty when finished.
+	// The cursor is opaque to the callers, the backends which list in the key order use the last key of the page.
+	ListByAddr(ctx context.Context, addr string, prefix string, cursor string, count int64) (keys []string, next string, err error)
+}
+
+// ScanRouteTableData is implemented by the backends which can iterate the keys
+type ScanRouteTableData interface {
+	// Scan iterates all the keys with the prefix in batches, the iteration stops when fn returns an error
+	Scan(ctx context.Context, prefix string, fn func(keys []string) error) error
 }
 
-func key(name, color string, oid int64) string {
-	return fmt.Sprintf("r_%s_{%s}_{%d}", name, color, oid)
+// ExpireManyRouteTableData is implemented by the backends which can reset the expiration of the keys in a batch
+type ExpireManyRouteTableData interface {
+	// ExpireMany is Expire of the keys, the errs are in the same order as the keys
+	ExpireMany(ctx context.Context, keys []string, expiration time.Duration) (errs []error)
+}
+
+// TTLRouteTableData is implemented by the backends which can read the remaining ttl of the keys
+type TTLRouteTableData interface {
+	// TTL returns the remaining ttl of the key, 0 means no expiration
+	TTL(ctx context.Context, key string) (time.Duration, error)
+}
+
+func NewRouteTable(name string, rt RouteTableData, opts ...Option) RouteTable {
+	return New(rt, name, opts...)
 }
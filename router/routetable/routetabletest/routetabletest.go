@@ -0,0 +1,406 @@
+// Package routetabletest verifies the implementations of routetable.RouteTableData against the same contracts.
+//
+// Usage:
+//
+//	func TestConformance(t *testing.T) {
+//		routetabletest.RunConformance(t, func(t *testing.T) routetable.RouteTableData {
+//			rt := NewRouteTable()
+//			t.Cleanup(rt.Close)
+//			return rt
+//		})
+//	}
+package routetabletest
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	verrors "github.com/vulcan-frame/vulcan-pkg-app/errors"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+)
+
+const (
+	defaultShortTTL = time.Millisecond * 100
+	longTTL         = time.Hour
+	prefix          = "r_conformance_{blue}_{"
+)
+
+// Factory returns an empty RouteTableData, it's called once per case and the cleanup is registered by t.Cleanup
+type Factory func(t *testing.T) routetable.RouteTableData
+
+type Option func(*options)
+
+type options struct {
+	shortTTL time.Duration
+	advance  func(d time.Duration)
+}
+
+// WithShortTTL sets the ttl of the keys expected to expire, the default is 100ms.
+// It should be above the ttl granularity of the backend, such as the lease of etcd.
+func WithShortTTL(d time.Duration) Option {
+	return func(o *options) {
+		o.shortTTL = d
+	}
+}
+
+// WithAdvance sets how the time passes for the expiry cases, the default sleeps.
+// The backends with the fake clock can fast forward it, such as miniredis.
+func WithAdvance(f func(d time.Duration)) Option {
+	return func(o *options) {
+		o.advance = f
+	}
+}
+
+// RunConformance runs the contract cases of RouteTableData as the subtests of t.
+// The cases of the optional interfaces are skipped if the backend doesn't implement them,
+// and the batches are verified by the fallbacks of the routetable package.
+// Watch is not covered, the events delivered by the backends are different.
+func RunConformance(t *testing.T, factory Factory, opts ...Option) {
+	o := options{
+		shortTTL: defaultShortTTL,
+		advance:  time.Sleep,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	cases := []struct {
+		name string
+		fn   func(t *testing.T, rt routetable.RouteTableData, o *options)
+	}{
+		{"NotFound", testNotFound},
+		{"SetAndLoad", testSetAndLoad},
+		{"SetNx", testSetNx},
+		{"GetSet", testGetSet},
+		{"DelIfSame", testDelIfSame},
+		{"Expire", testExpire},
+		{"Gen", testGen},
+		{"Many", testMany},
+		{"RenewMany", testRenewMany},
+		{"ListByAddr", testListByAddr},
+		{"Scan", testScan},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.fn(t, factory(t), &o)
+		})
+	}
+}
+
+// as returns the optional interface of the backend, the case is skipped if it's not implemented
+func as[T any](t *testing.T, rt routetable.RouteTableData) T {
+	v, ok := rt.(T)
+	if !ok {
+		t.Skipf("%T doesn't implement %T", rt, (*T)(nil))
+	}
+	return v
+}
+
+func key(oid string) string {
+	return prefix + oid + "}"
+}
+
+func testNotFound(t *testing.T, rt routetable.RouteTableData, _ *options) {
+	ctx := context.Background()
+
+	_, err := rt.Load(ctx, key("1"))
+	assert.ErrorIs(t, err, verrors.ErrRouteTableNotFound, "Load")
+	_, err = rt.LoadAndExpire(ctx, key("1"), longTTL)
+	assert.ErrorIs(t, err, verrors.ErrRouteTableNotFound, "LoadAndExpire")
+	if gd, ok := rt.(routetable.GenRouteTableData); ok {
+		_, _, err = gd.LoadGen(ctx, key("1"))
+		assert.ErrorIs(t, err, verrors.ErrRouteTableNotFound, "LoadGen")
+	}
+
+	_, errs := routetable.LoadMany(ctx, rt, []string{key("1")})
+	require.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], verrors.ErrRouteTableNotFound, "LoadMany")
+
+	// the deletes of the missing keys are not errors
+	assert.NoError(t, rt.Del(ctx, key("1")), "Del")
+	assert.NoError(t, rt.DelIfSame(ctx, key("1"), "a"), "DelIfSame")
+}
+
+func testSetAndLoad(t *testing.T, rt routetable.RouteTableData, _ *options) {
+	ctx := context.Background()
+	entry := routetable.RouteEntry{Addr: "10.0.0.1:9000", Node: "n1", Version: "v1", AssignedAt: time.UnixMilli(1700000000000)}
+
+	require.NoError(t, rt.Set(ctx, key("1"), entry.Encode(), longTTL))
+	value, err := rt.Load(ctx, key("1"))
+	require.NoError(t, err)
+	assert.Equal(t, entry.Encode(), value, "the value is stored as it is")
+
+	value, err = rt.LoadAndExpire(ctx, key("1"), longTTL)
+	require.NoError(t, err)
+	assert.Equal(t, entry.Encode(), value)
+
+	require.NoError(t, rt.Set(ctx, key("1"), "b", longTTL))
+	value, err = rt.Load(ctx, key("1"))
+	require.NoError(t, err)
+	assert.Equal(t, "b", value, "Set overwrites")
+
+	require.NoError(t, rt.Del(ctx, key("1")))
+	_, err = rt.Load(ctx, key("1"))
+	assert.ErrorIs(t, err, verrors.ErrRouteTableNotFound, "Del")
+}
+
+func testSetNx(t *testing.T, rt routetable.RouteTableData, _ *options) {
+	ctx := context.Background()
+
+	ok, result, err := rt.SetNx(ctx, key("1"), "a", longTTL)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "a", result)
+
+	ok, result, err = rt.SetNx(ctx, key("1"), "b", longTTL)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, "a", result, "SetNx returns the current value")
+
+	value, err := rt.Load(ctx, key("1"))
+	require.NoError(t, err)
+	assert.Equal(t, "a", value)
+}
+
+func testGetSet(t *testing.T, rt routetable.RouteTableData, _ *options) {
+	ctx := context.Background()
+
+	old, err := rt.GetSet(ctx, key("1"), "a", longTTL)
+	assert.ErrorIs(t, err, verrors.ErrRouteTableNotFound, "GetSet of the missing key")
+	assert.Empty(t, old)
+	value, err := rt.Load(ctx, key("1"))
+	require.NoError(t, err)
+	assert.Equal(t, "a", value, "GetSet of the missing key sets the value")
+
+	old, err = rt.GetSet(ctx, key("1"), "b", longTTL)
+	require.NoError(t, err)
+	assert.Equal(t, "a", old, "GetSet returns the old value")
+	value, err = rt.Load(ctx, key("1"))
+	require.NoError(t, err)
+	assert.Equal(t, "b", value)
+}
+
+func testDelIfSame(t *testing.T, rt routetable.RouteTableData, _ *options) {
+	ctx := context.Background()
+	entry := routetable.RouteEntry{Addr: "a", Node: "n1"}
+
+	require.NoError(t, rt.Set(ctx, key("1"), entry.Encode(), longTTL))
+	require.NoError(t, rt.Set(ctx, key("2"), "a", longTTL))
+
+	require.NoError(t, rt.DelIfSame(ctx, key("1"), "b"))
+	value, err := rt.Load(ctx, key("1"))
+	require.NoError(t, err)
+	assert.Equal(t, entry.Encode(), value, "DelIfSame keeps the other value")
+
+	// the values are compared by the address
+	require.NoError(t, rt.DelIfSame(ctx, key("1"), "a"))
+	_, err = rt.Load(ctx, key("1"))
+	assert.ErrorIs(t, err, verrors.ErrRouteTableNotFound, "DelIfSame deletes the same address")
+
+	_, err = rt.Load(ctx, key("2"))
+	assert.NoError(t, err, "DelIfSame leaves the other keys")
+}
+
+func testExpire(t *testing.T, rt routetable.RouteTableData, o *options) {
+	ctx := context.Background()
+
+	require.NoError(t, rt.Set(ctx, key("1"), "a", o.shortTTL))
+	require.NoError(t, rt.Set(ctx, key("2"), "a", longTTL))
+	require.NoError(t, rt.Set(ctx, key("3"), "a", o.shortTTL))
+	_, err := rt.LoadAndExpire(ctx, key("3"), longTTL)
+	require.NoError(t, err)
+	ok, _, err := rt.SetNx(ctx, key("4"), "a", o.shortTTL)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	o.advance(o.shortTTL * 3)
+	assert.Eventually(t, func() bool {
+		_, err1 := rt.Load(ctx, key("1"))
+		_, err4 := rt.Load(ctx, key("4"))
+		return allNotFound(err1, err4)
+	}, o.shortTTL*20, o.shortTTL/4, "the keys expire after the ttl")
+
+	_, err = rt.Load(ctx, key("2"))
+	assert.NoError(t, err)
+	_, err = rt.Load(ctx, key("3"))
+	assert.NoError(t, err, "LoadAndExpire extends the ttl")
+
+	require.NoError(t, rt.Expire(ctx, key("2"), 0))
+	_, err = rt.Load(ctx, key("2"))
+	assert.ErrorIs(t, err, verrors.ErrRouteTableNotFound, "Expire 0 deletes the key")
+}
+
+// allNotFound returns whether all the errs are ErrRouteTableNotFound
+func allNotFound(errs ...error) bool {
+	for _, err := range errs {
+		if !errors.Is(err, verrors.ErrRouteTableNotFound) {
+			return false
+		}
+	}
+	return true
+}
+
+func testGen(t *testing.T, rt routetable.RouteTableData, _ *options) {
+	ctx := context.Background()
+	gd := as[routetable.GenRouteTableData](t, rt)
+
+	ok, _, gen1, err := gd.SetNxGen(ctx, key("1"), "a", longTTL)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, result, gen, err := gd.SetNxGen(ctx, key("1"), "b", longTTL)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, "a", result)
+	assert.Equal(t, gen1, gen, "SetNxGen returns the current generation")
+
+	old, gen2, err := gd.GetSetGen(ctx, key("1"), "b", longTTL)
+	require.NoError(t, err)
+	assert.Equal(t, "a", old)
+	assert.Greater(t, gen2, gen1)
+
+	ok, gen, err = gd.CompareAndSwap(ctx, key("1"), gen1, "c", longTTL)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, gen2, gen, "CompareAndSwap returns the current generation when not swapped")
+
+	ok, gen3, err := gd.CompareAndSwap(ctx, key("1"), gen2, "c", longTTL)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Greater(t, gen3, gen2)
+
+	value, gen, err := gd.LoadGen(ctx, key("1"))
+	require.NoError(t, err)
+	assert.Equal(t, "c", value)
+	assert.Equal(t, gen3, gen)
+
+	// the generation of the missing key is 0
+	ok, _, err = gd.CompareAndSwap(ctx, key("2"), 1, "a", longTTL)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, _, err = gd.CompareAndSwap(ctx, key("2"), 0, "a", longTTL)
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func testMany(t *testing.T, rt routetable.RouteTableData, _ *options) {
+	ctx := context.Background()
+
+	errs := routetable.SetMany(ctx, rt, []string{key("1"), key("2")}, []string{"a", "b"}, longTTL)
+	assert.Equal(t, []error{nil, nil}, errs)
+
+	values, errs := routetable.LoadMany(ctx, rt, []string{key("1"), key("3"), key("2")})
+	require.Len(t, errs, 3)
+	assert.NoError(t, errs[0])
+	assert.ErrorIs(t, errs[1], verrors.ErrRouteTableNotFound)
+	assert.NoError(t, errs[2])
+	assert.Equal(t, "a", values[0])
+	assert.Equal(t, "b", values[2])
+
+	errs = routetable.DelMany(ctx, rt, []string{key("1"), key("3")})
+	assert.Equal(t, []error{nil, nil}, errs)
+	_, err := rt.Load(ctx, key("1"))
+	assert.ErrorIs(t, err, verrors.ErrRouteTableNotFound)
+	_, err = rt.Load(ctx, key("2"))
+	assert.NoError(t, err)
+}
+
+func testRenewMany(t *testing.T, rt routetable.RouteTableData, o *options) {
+	ctx := context.Background()
+
+	require.NoError(t, rt.Set(ctx, key("1"), routetable.RouteEntry{Addr: "a", Node: "n1"}.Encode(), o.shortTTL))
+	require.NoError(t, rt.Set(ctx, key("2"), "b", o.shortTTL))
+
+	errs := routetable.RenewMany(ctx, rt, []string{key("1"), key("2"), key("3")}, "a", longTTL)
+	require.Len(t, errs, 3)
+	assert.NoError(t, errs[0], "RenewMany renews the key owned by the addr")
+	assert.ErrorIs(t, errs[1], verrors.ErrRouteTableNotFound, "RenewMany of the key owned by another addr")
+	assert.ErrorIs(t, errs[2], verrors.ErrRouteTableNotFound, "RenewMany of the missing key")
+
+	o.advance(o.shortTTL * 3)
+	assert.Eventually(t, func() bool {
+		_, err := rt.Load(ctx, key("2"))
+		return allNotFound(err)
+	}, o.shortTTL*20, o.shortTTL/4)
+	_, err := rt.Load(ctx, key("1"))
+	assert.NoError(t, err)
+}
+
+func testListByAddr(t *testing.T, rt routetable.RouteTableData, _ *options) {
+	ctx := context.Background()
+	id := as[routetable.IndexRouteTableData](t, rt)
+
+	require.NoError(t, rt.Set(ctx, key("1"), "a", longTTL))
+	require.NoError(t, rt.Set(ctx, key("2"), routetable.RouteEntry{Addr: "a", Node: "n1"}.Encode(), longTTL))
+	_, _, err := rt.SetNx(ctx, key("3"), "a", longTTL)
+	require.NoError(t, err)
+	require.NoError(t, rt.Set(ctx, key("4"), "a", longTTL))
+	require.NoError(t, rt.Set(ctx, "r_other_{blue}_{1}", "a", longTTL))
+	_, err = rt.GetSet(ctx, key("3"), "b", longTTL)
+	require.NoError(t, err)
+	require.NoError(t, rt.DelIfSame(ctx, key("4"), "a"))
+
+	assert.Equal(t, []string{key("1"), key("2")}, listAll(t, id, "a"), "the index follows the writes")
+	assert.Equal(t, []string{key("3")}, listAll(t, id, "b"))
+	assert.Empty(t, listAll(t, id, "c"))
+}
+
+// listAll pages through the keys of the addr with the page size 1
+func listAll(t *testing.T, id routetable.IndexRouteTableData, addr string) []string {
+	var (
+		all    []string
+		cursor string
+	)
+	for i := 0; i < 100; i++ {
+		keys, next, err := id.ListByAddr(context.Background(), addr, prefix, cursor, 1)
+		require.NoError(t, err)
+		all = append(all, keys...)
+		if next == "" {
+			sort.Strings(all)
+			return dedup(all)
+		}
+		cursor = next
+	}
+	t.Fatal("ListByAddr does not finish")
+	return nil
+}
+
+// dedup removes the repeated keys of the sorted keys, the pages may repeat the keys like SCAN of Redis
+func dedup(keys []string) []string {
+	result := keys[:0]
+	for i, k := range keys {
+		if i == 0 || k != keys[i-1] {
+			result = append(result, k)
+		}
+	}
+	return result
+}
+
+func testScan(t *testing.T, rt routetable.RouteTableData, _ *options) {
+	ctx := context.Background()
+	sd := as[routetable.ScanRouteTableData](t, rt)
+
+	for _, oid := range []string{"1", "2", "3"} {
+		require.NoError(t, rt.Set(ctx, key(oid), "a", longTTL))
+	}
+	require.NoError(t, rt.Set(ctx, "r_other_{blue}_{1}", "a", longTTL))
+
+	var all []string
+	require.NoError(t, sd.Scan(ctx, prefix, func(keys []string) error {
+		all = append(all, keys...)
+		return nil
+	}))
+	sort.Strings(all)
+	assert.Equal(t, []string{key("1"), key("2"), key("3")}, dedup(all))
+
+	stop := assert.AnError
+	err := sd.Scan(ctx, prefix, func(keys []string) error {
+		return stop
+	})
+	assert.ErrorIs(t, err, stop, "Scan stops with the error of fn")
+}
@@ -0,0 +1,128 @@
+package routetable
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/pkg/errors"
+	vctx "github.com/vulcan-frame/vulcan-pkg-app/context"
+	verrors "github.com/vulcan-frame/vulcan-pkg-app/errors"
+	"github.com/vulcan-frame/vulcan-pkg-app/profile"
+)
+
+// WithSIDScope scopes the keys by the SID of the ctx, so the servers of a multi-server game can reuse the oids.
+// The SID is read from NewSIDContext, then the metadata of vctx.CtxSID, the SID is 0 if neither is set.
+// The draining marks are not scoped, they belong to the nodes.
+// Enabling it renames all the keys, move the existing routes by MigrateToScope before the services are switched.
+func WithSIDScope() Option {
+	return func(r *BaseRouteTable) {
+		r.sidScope = true
+	}
+}
+
+// WithZoneScope scopes the keys by profile.Zone(), so the zones can share the route table backend
+func WithZoneScope() Option {
+	return func(r *BaseRouteTable) {
+		r.zone = profile.Zone
+	}
+}
+
+// WithZone scopes the keys by the zone, it's WithZoneScope for the tools which don't init the profile
+func WithZone(zone uint32) Option {
+	return func(r *BaseRouteTable) {
+		r.zone = func() uint32 { return zone }
+	}
+}
+
+type sidKey struct{}
+
+// NewSIDContext sets the SID of the route table calls, it's used by the background jobs without the metadata
+func NewSIDContext(ctx context.Context, sid int64) context.Context {
+	return context.WithValue(ctx, sidKey{}, sid)
+}
+
+// SIDFromContext returns the SID of the route table calls, see WithSIDScope
+func SIDFromContext(ctx context.Context) int64 {
+	if sid, ok := ctx.Value(sidKey{}).(int64); ok {
+		return sid
+	}
+	sid, err := vctx.SID(ctx)
+	if err != nil {
+		return 0
+	}
+	return sid
+}
+
+// scopedName returns the name with the scope, such as player_z1_s10
+func (r *BaseRouteTable) scopedName(ctx context.Context) string {
+	return r.nameOfSID(SIDFromContext(ctx))
+}
+
+func (r *BaseRouteTable) nameOfSID(sid int64) string {
+	name := r.name
+	if r.zone != nil {
+		name += "_z" + strconv.FormatUint(uint64(r.zone()), 10)
+	}
+	if r.sidScope {
+		name += "_s" + strconv.FormatInt(sid, 10)
+	}
+	return name
+}
+
+// MergeResult is the result of MergeSID
+type MergeResult struct {
+	Merged    int // the count of the routes moved to the target SID
+	Conflicts int // the count of the routes kept in the source SID because the oid is routed to another addr in the target SID
+}
+
+// MergeSID moves the routes of the color from the SID from to the SID to for the server merge, the routes keep the remaining ttl.
+// The route which already exists in the target SID is kept, and the source route of the conflict is left to the caller.
+// The source route is deleted only if it's not changed during the merge.
+func (r *BaseRouteTable) MergeSID(ctx context.Context, color string, from, to int64) (result MergeResult, err error) {
+	if !r.sidScope {
+		return result, errors.Errorf("the route table is not scoped by sid. name=%s", r.name)
+	}
+	if from == to {
+		return result, errors.Errorf("merge to the same sid. sid=%d", from)
+	}
+
+	fromPrefix := r.keyFormat.Prefix(r.nameOfSID(from), color)
+	toName := r.nameOfSID(to)
+	err = Scan(ctx, r.RouteTableData, fromPrefix, func(keys []string) error {
+		values, ttls, errs := r.loadManyTTL(ctx, keys)
+		for i, key := range keys {
+			if errs[i] != nil {
+				if errors.Is(errs[i], verrors.ErrRouteTableNotFound) {
+					continue
+				}
+				return errs[i]
+			}
+			oid, ok := r.keyFormat.Parse(fromPrefix, key)
+			if !ok {
+				continue
+			}
+
+			ttl := ttls[i]
+			if ttl <= 0 {
+				ttl = r.ttl
+			}
+			ok, current, err := r.RouteTableData.SetNx(ctx, r.keyFormat.Key(toName, color, oid), values[i], ttl)
+			if err != nil {
+				return err
+			}
+			if !ok && !SameAddr(current, values[i]) {
+				result.Conflicts++
+				log.Warnf("merge sid conflicts, the route is kept in the source sid. color=%s oid=%s from=%d to=%d source=%s target=%s",
+					color, oid, from, to, AddrOf(values[i]), AddrOf(current))
+				continue
+			}
+			if err := r.RouteTableData.DelIfSame(ctx, key, values[i]); err != nil {
+				return err
+			}
+			result.Merged++
+		}
+		return nil
+	})
+	return result, err
+}
@@ -0,0 +1,101 @@
+package routetable_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kratos/kratos/v2/metadata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	vctx "github.com/vulcan-frame/vulcan-pkg-app/context"
+	verrors "github.com/vulcan-frame/vulcan-pkg-app/errors"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable/memory"
+)
+
+func sidCtx(sid string) context.Context {
+	md := metadata.New()
+	md.Set(vctx.CtxSID, sid)
+	return metadata.NewServerContext(context.Background(), md)
+}
+
+func TestSIDScope(t *testing.T) {
+	ctx := context.Background()
+	data := memory.NewRouteTable()
+	defer data.Close()
+	rt := routetable.New(data, "player", routetable.WithSIDScope(), routetable.WithZoneScope())
+
+	require.NoError(t, rt.Store(sidCtx("1"), "blue", 100, "a"))
+	require.NoError(t, rt.Store(sidCtx("2"), "blue", 100, "b"))
+
+	_, err := data.Load(ctx, "r_player_z0_s1_{blue}_{100}")
+	require.NoError(t, err)
+
+	addr, err := rt.Load(routetable.NewSIDContext(ctx, 2), "blue", 100)
+	require.NoError(t, err)
+	assert.Equal(t, "b", addr)
+
+	// the sid is 0 without the metadata
+	_, err = rt.Load(ctx, "blue", 100)
+	assert.ErrorIs(t, err, verrors.ErrRouteTableNotFound)
+}
+
+func TestMigrateToScope(t *testing.T) {
+	ctx := context.Background()
+	data := memory.NewRouteTable()
+	defer data.Close()
+
+	unscoped := routetable.New(data, "player")
+	require.NoError(t, unscoped.Store(ctx, "blue", 100, "a"))
+	require.NoError(t, unscoped.StoreObject(ctx, "blue", "room-1", "a"))
+
+	rt := routetable.New(data, "player", routetable.WithSIDScope(), routetable.WithZone(3))
+	sctx := routetable.NewSIDContext(ctx, 7)
+	require.NoError(t, rt.Store(sctx, "blue", 100, "b"))
+
+	n, err := rt.MigrateToScope(sctx, "blue")
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	// the route already in the scope is kept
+	addr, err := rt.Load(sctx, "blue", 100)
+	require.NoError(t, err)
+	assert.Equal(t, "b", addr)
+	_, err = data.Load(ctx, "r_player_z3_s7_{blue}_{room-1}")
+	require.NoError(t, err)
+	_, err = unscoped.LoadObject(ctx, "blue", "room-1")
+	assert.ErrorIs(t, err, verrors.ErrRouteTableNotFound)
+
+	_, err = unscoped.MigrateToScope(ctx, "blue")
+	assert.Error(t, err, "the route table is not scoped")
+}
+
+func TestMergeSID(t *testing.T) {
+	data := memory.NewRouteTable()
+	defer data.Close()
+	rt := routetable.New(data, "player", routetable.WithSIDScope())
+	from, to := sidCtx("1"), sidCtx("2")
+
+	require.NoError(t, rt.Store(from, "blue", 1, "a"))
+	require.NoError(t, rt.Store(from, "blue", 2, "a"))
+	require.NoError(t, rt.Store(from, "blue", 3, "a"))
+	require.NoError(t, rt.Store(to, "blue", 2, "a"))
+	require.NoError(t, rt.Store(to, "blue", 3, "b"))
+
+	result, err := rt.MergeSID(context.Background(), "blue", 1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, routetable.MergeResult{Merged: 2, Conflicts: 1}, result)
+
+	addrs, errs := rt.LoadMany(to, "blue", []int64{1, 2, 3})
+	assert.Equal(t, []error{nil, nil, nil}, errs)
+	assert.Equal(t, []string{"a", "a", "b"}, addrs)
+
+	_, err = rt.Load(from, "blue", 1)
+	assert.ErrorIs(t, err, verrors.ErrRouteTableNotFound)
+	addr, err := rt.Load(from, "blue", 3)
+	require.NoError(t, err)
+	assert.Equal(t, "a", addr, "the conflict is kept in the source")
+
+	_, err = routetable.New(data, "player").MergeSID(context.Background(), "blue", 1, 2)
+	assert.Error(t, err)
+}
@@ -0,0 +1,295 @@
+package routetable
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+	verrors "github.com/vulcan-frame/vulcan-pkg-app/errors"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ImportMode is how Import handles the routes which already exist
+type ImportMode int
+
+const (
+	// ImportOverwrite overwrites the existing routes
+	ImportOverwrite ImportMode = iota
+	// ImportOnlyMissing keeps the existing routes
+	ImportOnlyMissing
+)
+
+// SnapshotRecord is a route in the snapshot. The key is not recorded,
+// so the snapshot can be imported to the route table in another key format.
+type SnapshotRecord struct {
+	OID   string `json:"oid"`   // the object string of the route, the int64 oids are in decimal
+	Value string `json:"value"` // the encoded RouteEntry
+	// TTL is the remaining ttl when exported, 0 means unknown or no expiration and the ttl of the route table is used
+	TTL time.Duration `json:"-"`
+}
+
+type jsonSnapshotRecord struct {
+	SnapshotRecord
+	TTLMs int64 `json:"ttl_ms,omitempty"`
+}
+
+// SnapshotWriter writes the records of Export
+type SnapshotWriter interface {
+	Write(record SnapshotRecord) error
+	// Flush writes the buffered records to the underlying writer
+	Flush() error
+}
+
+// SnapshotReader reads the records of Import, it returns io.EOF at the end
+type SnapshotReader interface {
+	Read() (SnapshotRecord, error)
+}
+
+// SnapshotRouteTableData is implemented by the backends which load the values with the remaining ttl in a batch.
+// The ttl is 0 if the key has no expiration.
+type SnapshotRouteTableData interface {
+	LoadManyTTL(ctx context.Context, keys []string) (values []string, ttls []time.Duration, errs []error)
+}
+
+// ImportResult is the result of Import
+type ImportResult struct {
+	Imported int // the count of the routes set
+	Skipped  int // the count of the routes kept in ImportOnlyMissing
+}
+
+// Export writes the routes of the color to the writer, the routes changed during the export may be missed.
+// The values and ttls are loaded in batches if the backend implements SnapshotRouteTableData, otherwise the values
+// are loaded by LoadMany and the ttls one key at a time by TTLRouteTableData, and the ttls are 0 without both.
+func (r *BaseRouteTable) Export(ctx context.Context, color string, w SnapshotWriter) (exported int, err error) {
+	prefix := r.keyFormat.Prefix(r.scopedName(ctx), color)
+	err = Scan(ctx, r.RouteTableData, prefix, func(keys []string) error {
+		values, ttls, errs := r.loadManyTTL(ctx, keys)
+		for i, key := range keys {
+			if errs[i] != nil {
+				if errors.Is(errs[i], verrors.ErrRouteTableNotFound) {
+					continue
+				}
+				return errs[i]
+			}
+			oid, ok := r.keyFormat.Parse(prefix, key)
+			if !ok {
+				continue
+			}
+			if err := w.Write(SnapshotRecord{OID: oid, Value: values[i], TTL: ttls[i]}); err != nil {
+				return errors.Wrapf(err, "write snapshot failed. key=%s", key)
+			}
+			exported++
+		}
+		return nil
+	})
+	if err != nil {
+		return exported, err
+	}
+	return exported, w.Flush()
+}
+
+// Import sets the routes of the color from the reader, the routes keep the remaining ttl of the export
+func (r *BaseRouteTable) Import(ctx context.Context, color string, rd SnapshotReader, mode ImportMode) (result ImportResult, err error) {
+	for {
+		record, err := rd.Read()
+		if errors.Is(err, io.EOF) {
+			return result, nil
+		}
+		if err != nil {
+			return result, errors.Wrapf(err, "read snapshot failed")
+		}
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+
+		if record.OID == "" {
+			return result, errors.Errorf("snapshot record has no oid")
+		}
+
+		key := r.key(ctx, color, record.OID)
+		ttl := record.TTL
+		if ttl <= 0 {
+			ttl = r.ttl
+		}
+
+		if mode == ImportOnlyMissing {
+			ok, _, err := r.RouteTableData.SetNx(ctx, key, record.Value, ttl)
+			if err != nil {
+				return result, err
+			}
+			if !ok {
+				result.Skipped++
+				continue
+			}
+		} else if err := r.RouteTableData.Set(ctx, key, record.Value, ttl); err != nil {
+			return result, err
+		}
+		result.Imported++
+	}
+}
+
+// loadManyTTL loads the values with the ttl, the ttl is 0 if the backend can't read it.
+// The ttls are read one key at a time if the backend implements only TTLRouteTableData.
+func (r *BaseRouteTable) loadManyTTL(ctx context.Context, keys []string) ([]string, []time.Duration, []error) {
+	if rtd, ok := asData[SnapshotRouteTableData](r.RouteTableData, true); ok {
+		return rtd.LoadManyTTL(ctx, keys)
+	}
+
+	values, errs := LoadMany(ctx, r.RouteTableData, keys)
+	ttls := make([]time.Duration, len(keys))
+	if rtd, ok := asData[TTLRouteTableData](r.RouteTableData, true); ok {
+		for i, key := range keys {
+			if errs[i] == nil {
+				ttls[i], _ = rtd.TTL(ctx, key)
+			}
+		}
+	}
+	return values, ttls, errs
+}
+
+type jsonSnapshotWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+// NewJSONSnapshotWriter writes the records as json lines
+func NewJSONSnapshotWriter(w io.Writer) SnapshotWriter {
+	bw := bufio.NewWriter(w)
+	return &jsonSnapshotWriter{w: bw, enc: json.NewEncoder(bw)}
+}
+
+func (w *jsonSnapshotWriter) Write(record SnapshotRecord) error {
+	return w.enc.Encode(jsonSnapshotRecord{SnapshotRecord: record, TTLMs: record.TTL.Milliseconds()})
+}
+
+func (w *jsonSnapshotWriter) Flush() error {
+	return w.w.Flush()
+}
+
+type jsonSnapshotReader struct {
+	dec *json.Decoder
+}
+
+// NewJSONSnapshotReader reads the json lines of NewJSONSnapshotWriter
+func NewJSONSnapshotReader(r io.Reader) SnapshotReader {
+	return &jsonSnapshotReader{dec: json.NewDecoder(r)}
+}
+
+func (r *jsonSnapshotReader) Read() (SnapshotRecord, error) {
+	var record jsonSnapshotRecord
+	if err := r.dec.Decode(&record); err != nil {
+		return SnapshotRecord{}, err
+	}
+	record.TTL = time.Duration(record.TTLMs) * time.Millisecond
+	return record.SnapshotRecord, nil
+}
+
+// the fields of the protobuf message
+//
+//	message SnapshotRecord {
+//	  string oid = 1;
+//	  string value = 2;
+//	  int64 ttl_ms = 3;
+//	}
+const (
+	maxSnapshotMessageSize = 1 << 20
+
+	protoFieldOID   protowire.Number = 1
+	protoFieldValue protowire.Number = 2
+	protoFieldTTL   protowire.Number = 3
+)
+
+type protoSnapshotWriter struct {
+	w   *bufio.Writer
+	buf []byte
+}
+
+// NewProtoSnapshotWriter writes the records as the length delimited protobuf messages
+func NewProtoSnapshotWriter(w io.Writer) SnapshotWriter {
+	return &protoSnapshotWriter{w: bufio.NewWriter(w)}
+}
+
+func (w *protoSnapshotWriter) Write(record SnapshotRecord) error {
+	var msg []byte
+	msg = protowire.AppendTag(msg, protoFieldOID, protowire.BytesType)
+	msg = protowire.AppendString(msg, record.OID)
+	msg = protowire.AppendTag(msg, protoFieldValue, protowire.BytesType)
+	msg = protowire.AppendString(msg, record.Value)
+	if ms := record.TTL.Milliseconds(); ms > 0 {
+		msg = protowire.AppendTag(msg, protoFieldTTL, protowire.VarintType)
+		msg = protowire.AppendVarint(msg, uint64(ms))
+	}
+
+	w.buf = protowire.AppendBytes(w.buf[:0], msg)
+	_, err := w.w.Write(w.buf)
+	return err
+}
+
+func (w *protoSnapshotWriter) Flush() error {
+	return w.w.Flush()
+}
+
+type protoSnapshotReader struct {
+	r *bufio.Reader
+}
+
+// NewProtoSnapshotReader reads the messages of NewProtoSnapshotWriter
+func NewProtoSnapshotReader(r io.Reader) SnapshotReader {
+	return &protoSnapshotReader{r: bufio.NewReader(r)}
+}
+
+func (r *protoSnapshotReader) Read() (SnapshotRecord, error) {
+	size, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return SnapshotRecord{}, err
+	}
+	if size > maxSnapshotMessageSize {
+		return SnapshotRecord{}, errors.Errorf("snapshot message too large. size=%d", size)
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(r.r, msg); err != nil {
+		return SnapshotRecord{}, errors.Wrapf(io.ErrUnexpectedEOF, "read snapshot message failed. size=%d", size)
+	}
+
+	var record SnapshotRecord
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		if n < 0 {
+			return record, protowire.ParseError(n)
+		}
+		msg = msg[n:]
+
+		switch {
+		case num == protoFieldOID && typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(msg)
+			if n < 0 {
+				return record, protowire.ParseError(n)
+			}
+			record.OID, msg = v, msg[n:]
+		case num == protoFieldValue && typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(msg)
+			if n < 0 {
+				return record, protowire.ParseError(n)
+			}
+			record.Value, msg = v, msg[n:]
+		case num == protoFieldTTL && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(msg)
+			if n < 0 {
+				return record, protowire.ParseError(n)
+			}
+			record.TTL, msg = time.Duration(v)*time.Millisecond, msg[n:]
+		default:
+			// skip the unknown fields for the compatibility
+			n := protowire.ConsumeFieldValue(num, typ, msg)
+			if n < 0 {
+				return record, protowire.ParseError(n)
+			}
+			msg = msg[n:]
+		}
+	}
+	return record, nil
+}
@@ -0,0 +1,77 @@
+package routetable_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable/memory"
+)
+
+func TestBaseRouteTable_ExportImport(t *testing.T) {
+	formats := map[string]struct {
+		writer func(io.Writer) routetable.SnapshotWriter
+		reader func(io.Reader) routetable.SnapshotReader
+	}{
+		"json":  {routetable.NewJSONSnapshotWriter, routetable.NewJSONSnapshotReader},
+		"proto": {routetable.NewProtoSnapshotWriter, routetable.NewProtoSnapshotReader},
+	}
+
+	for name, f := range formats {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			src := memory.NewRouteTable()
+			defer src.Close()
+			rt := routetable.New(src, "test", routetable.WithTTL(time.Hour))
+
+			entry := routetable.RouteEntry{Addr: "a", Node: "n1", Version: "1.0.0", AssignedAt: time.UnixMilli(1700000000123)}
+			require.NoError(t, rt.StoreEntry(ctx, "red", 1, entry))
+			require.NoError(t, rt.Store(ctx, "red", 2, "b"))
+			require.NoError(t, rt.Store(ctx, "blue", 3, "c"))
+			require.NoError(t, rt.StoreObject(ctx, "red", "room-1", "d"))
+			require.NoError(t, rt.DelDelay(ctx, "red", 2, time.Minute))
+
+			var buf bytes.Buffer
+			n, err := rt.Export(ctx, "red", f.writer(&buf))
+			require.NoError(t, err)
+			assert.Equal(t, 3, n)
+
+			dst := memory.NewRouteTable()
+			defer dst.Close()
+			restored := routetable.New(dst, "test", routetable.WithTTL(time.Hour), routetable.WithKeyFormat(routetable.ClusterKeyFormat))
+			require.NoError(t, restored.Store(ctx, "red", 2, "x"))
+
+			result, err := restored.Import(ctx, "red", f.reader(bytes.NewReader(buf.Bytes())), routetable.ImportOnlyMissing)
+			require.NoError(t, err)
+			assert.Equal(t, routetable.ImportResult{Imported: 2, Skipped: 1}, result)
+
+			got, err := restored.LoadEntry(ctx, "red", 1)
+			require.NoError(t, err)
+			assert.Equal(t, entry.Encode(), got.Encode())
+			addr, err := restored.Load(ctx, "red", 2)
+			require.NoError(t, err)
+			assert.Equal(t, "x", addr)
+			addr, err = restored.LoadObject(ctx, "red", "room-1")
+			require.NoError(t, err)
+			assert.Equal(t, "d", addr)
+
+			result, err = restored.Import(ctx, "red", f.reader(bytes.NewReader(buf.Bytes())), routetable.ImportOverwrite)
+			require.NoError(t, err)
+			assert.Equal(t, 3, result.Imported)
+			addr, err = restored.Load(ctx, "red", 2)
+			require.NoError(t, err)
+			assert.Equal(t, "b", addr)
+
+			// the remaining ttl is kept
+			ttl, err := restored.TTL(ctx, "red", 2)
+			require.NoError(t, err)
+			assert.LessOrEqual(t, ttl, time.Minute)
+			assert.Greater(t, ttl, time.Second*50)
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package sql
+
+import (
+	"context"
+	"strings"
+)
+
+const (
+	defaultListCount = 10
+	scanBatchSize    = 100
+)
+
+// prefixEnd returns the least string greater than all the strings with the prefix, "" means no upper bound.
+// The range is used instead of LIKE because the keys contain the wildcard "_".
+func prefixEnd(prefix string) string {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return string(end[:i+1])
+		}
+	}
+	return ""
+}
+
+// ListByAddr pages through the keys with the prefix routed to the addr in the key order by the index idx_addr_key,
+// the cursor is the last key of the previous page, so the page starts right after it.
+func (rt *RouteTable) ListByAddr(ctx context.Context, addr string, prefix string, cursor string, count int64) ([]string, string, error) {
+	if count <= 0 {
+		count = defaultListCount
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
+	defer cancel()
+
+	db := alive(rt.session(ctx), nowMilli()).Where("addr = ? AND route_key >= ?", addr, prefix)
+	if cursor != "" {
+		db = db.Where("route_key > ?", cursor)
+	}
+	if end := prefixEnd(prefix); end != "" {
+		db = db.Where("route_key < ?", end)
+	}
+
+	var keys []string
+	if err := db.Order("route_key").Limit(int(count)).Pluck("route_key", &keys).Error; err != nil {
+		return nil, "", wrapErr(err, "ListByAddr", "addr", addr, "prefix", prefix, "cursor", cursor)
+	}
+
+	next := ""
+	if int64(len(keys)) == count {
+		next = keys[len(keys)-1]
+	}
+	return filterPrefix(keys, prefix), next, nil
+}
+
+// Scan iterates the keys with the prefix in the key order by the pages of the key ranges
+func (rt *RouteTable) Scan(ctx context.Context, prefix string, fn func(keys []string) error) error {
+	end := prefixEnd(prefix)
+	last := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		reqCtx, cancel := context.WithTimeout(ctx, rt.timeout)
+		db := alive(rt.session(reqCtx), nowMilli()).Where("route_key >= ?", prefix)
+		if last != "" {
+			db = db.Where("route_key > ?", last)
+		}
+		if end != "" {
+			db = db.Where("route_key < ?", end)
+		}
+
+		var keys []string
+		err := db.Order("route_key").Limit(scanBatchSize).Pluck("route_key", &keys).Error
+		cancel()
+		if err != nil {
+			return wrapErr(err, "Scan", "prefix", prefix)
+		}
+		if len(keys) == 0 {
+			return nil
+		}
+
+		last = keys[len(keys)-1]
+		finished := len(keys) < scanBatchSize
+		if keys = filterPrefix(keys, prefix); len(keys) > 0 {
+			if err := fn(keys); err != nil {
+				return err
+			}
+		}
+		if finished {
+			return nil
+		}
+	}
+}
+
+// filterPrefix filters the keys matched by the case insensitive collations of some databases
+func filterPrefix(keys []string, prefix string) []string {
+	result := keys[:0]
+	for _, key := range keys {
+		if strings.HasPrefix(key, prefix) {
+			result = append(result, key)
+		}
+	}
+	return result
+}
@@ -0,0 +1,562 @@
+package sql
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/pkg/errors"
+	verrors "github.com/vulcan-frame/vulcan-pkg-app/errors"
+	vlog "github.com/vulcan-frame/vulcan-pkg-app/log"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+	"github.com/vulcan-frame/vulcan-pkg-app/trace"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	defaultTimeout       = 2 * time.Second
+	defaultTable         = "route_table"
+	defaultSweepInterval = time.Minute
+	errPrefix            = "sql routeTable"
+
+	// batchSize is the max count of the keys in a statement
+	batchSize = 500
+	// maxRetries is the max count of the retries when the row is changed during a conditional write
+	maxRetries = 16
+)
+
+var (
+	_ routetable.RouteTableData           = (*RouteTable)(nil)
+	_ routetable.GenRouteTableData        = (*RouteTable)(nil)
+	_ routetable.BatchRouteTableData      = (*RouteTable)(nil)
+	_ routetable.IndexRouteTableData      = (*RouteTable)(nil)
+	_ routetable.ScanRouteTableData       = (*RouteTable)(nil)
+	_ routetable.ExpireManyRouteTableData = (*RouteTable)(nil)
+)
+
+// Route is the row of a route
+type Route struct {
+	Key       string `gorm:"column:route_key;primaryKey;size:255;index:idx_addr_key,priority:2"`
+	Addr      string `gorm:"size:255;index:idx_addr_key,priority:1"` // the address of the value, see routetable.AddrOf
+	Value     string `gorm:"size:1024"`                              // the encoded routetable.RouteEntry
+	ExpiresAt int64  `gorm:"index"`                                  // unix milliseconds, 0 means never expire
+	Version   int64  // the generation of the route
+}
+
+type Option func(*RouteTable)
+
+func WithTimeout(dur time.Duration) Option {
+	return func(r *RouteTable) {
+		r.timeout = dur
+	}
+}
+
+// WithTable sets the name of the table, the default is "route_table"
+func WithTable(table string) Option {
+	return func(r *RouteTable) {
+		r.table = table
+	}
+}
+
+// WithSweepInterval sets the interval of removing the expired rows
+func WithSweepInterval(dur time.Duration) Option {
+	return func(r *RouteTable) {
+		r.sweepInterval = dur
+	}
+}
+
+// RouteTable is the durable RouteTableData on a SQL database by gorm.
+// The writes are the conditional updates on the version, which is the generation of the route.
+// The expired rows are invisible to the reads and removed by the background sweeper.
+// Watch is not supported.
+type RouteTable struct {
+	db            *gorm.DB
+	timeout       time.Duration
+	table         string
+	sweepInterval time.Duration
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewRouteTable migrates the table and starts the sweeper
+func NewRouteTable(db *gorm.DB, opts ...Option) (*RouteTable, error) {
+	rt := &RouteTable{
+		db:            db,
+		timeout:       defaultTimeout,
+		table:         defaultTable,
+		sweepInterval: defaultSweepInterval,
+	}
+	for _, opt := range opts {
+		opt(rt)
+	}
+
+	if err := db.Table(rt.table).AutoMigrate(&Route{}); err != nil {
+		return nil, errors.Wrapf(err, "%s migrate table %s failed", errPrefix, rt.table)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rt.cancel = cancel
+	rt.wg.Add(1)
+	go rt.sweepLoop(ctx)
+	return rt, nil
+}
+
+// Open opens the database with the project logger and the tracing plugin
+func Open(dialector gorm.Dialector, l *log.Helper) (*gorm.DB, error) {
+	db, err := gorm.Open(dialector, &gorm.Config{
+		Logger: vlog.WithGorm(l, logger.Config{
+			SlowThreshold:             200 * time.Millisecond,
+			LogLevel:                  logger.Warn,
+			IgnoreRecordNotFoundError: true,
+		}),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s open database failed", errPrefix)
+	}
+	if err = db.Use(&trace.GormTracingPlugin{}); err != nil {
+		return nil, errors.Wrapf(err, "%s use tracing plugin failed", errPrefix)
+	}
+	return db, nil
+}
+
+// Close stops the sweeper
+func (rt *RouteTable) Close() {
+	rt.cancel()
+	rt.wg.Wait()
+}
+
+func wrapErr(err error, operation string, args ...interface{}) error {
+	return errors.Wrapf(err, "%s %s failed %v", errPrefix, operation, args)
+}
+
+func notFound(operation string, args ...interface{}) error {
+	return wrapErr(errors.Wrapf(verrors.ErrRouteTableNotFound, "%s data not found", operation), operation, args...)
+}
+
+func (rt *RouteTable) session(ctx context.Context) *gorm.DB {
+	return rt.db.WithContext(ctx).Table(rt.table)
+}
+
+func nowMilli() int64 {
+	return time.Now().UnixMilli()
+}
+
+func expiresAt(dur time.Duration) int64 {
+	if dur <= 0 {
+		return 0
+	}
+	return time.Now().Add(dur).UnixMilli()
+}
+
+func (r *Route) alive(now int64) bool {
+	return r.ExpiresAt == 0 || r.ExpiresAt > now
+}
+
+// alive is the condition of the unexpired rows
+func alive(db *gorm.DB, now int64) *gorm.DB {
+	return db.Where("(expires_at = 0 OR expires_at > ?)", now)
+}
+
+func (rt *RouteTable) Set(ctx context.Context, key string, addr string, dur time.Duration) error {
+	if dur <= 0 {
+		return wrapErr(errors.Errorf("invalid expire time %s", dur), "Set", "key", key, "addr", addr)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
+	defer cancel()
+
+	if _, _, _, err := rt.put(ctx, key, addr, dur, nil); err != nil {
+		return wrapErr(err, "Set", "key", key, "addr", addr)
+	}
+	return nil
+}
+
+func (rt *RouteTable) GetSet(ctx context.Context, key string, addr string, dur time.Duration) (string, error) {
+	old, _, err := rt.GetSetGen(ctx, key, addr, dur)
+	return old, err
+}
+
+// GetSetGen sets the value with expiration, returns the old value and the new generation.
+// The value is stored even if the old one not exists, in which case ErrRouteTableNotFound is returned.
+func (rt *RouteTable) GetSetGen(ctx context.Context, key string, addr string, dur time.Duration) (string, int64, error) {
+	if dur <= 0 {
+		return "", 0, wrapErr(errors.Errorf("invalid expire time %s", dur), "GetSet", "key", key, "addr", addr)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
+	defer cancel()
+
+	prev, _, gen, err := rt.put(ctx, key, addr, dur, nil)
+	if err != nil {
+		return "", 0, wrapErr(err, "GetSet", "key", key, "addr", addr)
+	}
+	if prev == nil {
+		return "", gen, notFound("GetSet", "key", key, "addr", addr)
+	}
+	return prev.Value, gen, nil
+}
+
+// SetNx sets the value if not exists with expiration, returns:
+// ok - true when key was set
+// result - current value (new value when ok=true)
+// err - operation error
+func (rt *RouteTable) SetNx(ctx context.Context, key string, addr string, dur time.Duration) (bool, string, error) {
+	ok, result, _, err := rt.SetNxGen(ctx, key, addr, dur)
+	return ok, result, err
+}
+
+// SetNxGen is SetNx which also returns the generation of the current value
+func (rt *RouteTable) SetNxGen(ctx context.Context, key string, addr string, dur time.Duration) (bool, string, int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
+	defer cancel()
+
+	prev, ok, gen, err := rt.put(ctx, key, addr, dur, func(prev *Route) bool {
+		return prev == nil
+	})
+	if err != nil {
+		return false, "", 0, wrapErr(err, "SetNx", "key", key, "addr", addr)
+	}
+	if !ok {
+		return false, prev.Value, prev.Version, nil
+	}
+	return true, addr, gen, nil
+}
+
+// CompareAndSwap sets the value only if the current generation is expectedGen, the generation of the missing key is 0.
+// It returns the new generation when swapped, otherwise the current generation.
+func (rt *RouteTable) CompareAndSwap(ctx context.Context, key string, expectedGen int64, addr string, dur time.Duration) (bool, int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
+	defer cancel()
+
+	prev, ok, gen, err := rt.put(ctx, key, addr, dur, func(prev *Route) bool {
+		return generation(prev) == expectedGen
+	})
+	if err != nil {
+		return false, 0, wrapErr(err, "CompareAndSwap", "key", key, "addr", addr, "gen", expectedGen)
+	}
+	if !ok {
+		return false, generation(prev), nil
+	}
+	return true, gen, nil
+}
+
+func generation(r *Route) int64 {
+	if r == nil {
+		return 0
+	}
+	return r.Version
+}
+
+func (rt *RouteTable) Load(ctx context.Context, key string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
+	defer cancel()
+
+	r, err := rt.get(ctx, key)
+	if err != nil {
+		return "", wrapErr(err, "Load", "key", key)
+	}
+	if r == nil {
+		return "", notFound("Load", "key", key)
+	}
+	return r.Value, nil
+}
+
+func (rt *RouteTable) LoadGen(ctx context.Context, key string) (string, int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
+	defer cancel()
+
+	r, err := rt.get(ctx, key)
+	if err != nil {
+		return "", 0, wrapErr(err, "LoadGen", "key", key)
+	}
+	if r == nil {
+		return "", 0, notFound("LoadGen", "key", key)
+	}
+	return r.Value, r.Version, nil
+}
+
+// LoadAndExpire loads the value and resets the expiration like redis GETEX:
+// dur > 0 sets the expiration, dur == 0 removes it and dur < 0 keeps it unchanged.
+func (rt *RouteTable) LoadAndExpire(ctx context.Context, key string, dur time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
+	defer cancel()
+
+	r, err := rt.get(ctx, key)
+	if err != nil {
+		return "", wrapErr(err, "LoadAndExpire", "key", key)
+	}
+	if r == nil {
+		return "", notFound("LoadAndExpire", "key", key)
+	}
+	if dur >= 0 {
+		if err := rt.expire(ctx, []string{key}, "", dur); err != nil {
+			return "", wrapErr(err, "LoadAndExpire", "key", key)
+		}
+	}
+	return r.Value, nil
+}
+
+func (rt *RouteTable) Del(ctx context.Context, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
+	defer cancel()
+
+	if err := rt.session(ctx).Where("route_key = ?", key).Delete(&Route{}).Error; err != nil {
+		return wrapErr(err, "Del", "key", key)
+	}
+	return nil
+}
+
+// DelIfSame deletes the key if its value has the same address as the value
+func (rt *RouteTable) DelIfSame(ctx context.Context, key string, value string) error {
+	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
+	defer cancel()
+
+	err := rt.session(ctx).Where("route_key = ? AND addr = ?", key, routetable.AddrOf(value)).Delete(&Route{}).Error
+	if err != nil {
+		return wrapErr(err, "DelIfSame", "key", key, "value", value)
+	}
+	return nil
+}
+
+// Expire sets the expiration of the key, the key is deleted immediately when expiration <= 0.
+func (rt *RouteTable) Expire(ctx context.Context, key string, expiration time.Duration) error {
+	if expiration <= 0 {
+		return rt.Del(ctx, key)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
+	defer cancel()
+
+	if err := rt.expire(ctx, []string{key}, "", expiration); err != nil {
+		return wrapErr(err, "Expire", "key", key)
+	}
+	return nil
+}
+
+func (rt *RouteTable) ExpireMany(ctx context.Context, keys []string, expiration time.Duration) []error {
+	errs := make([]error, len(keys))
+	if expiration <= 0 {
+		return rt.DelMany(ctx, keys)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
+	defer cancel()
+
+	for start := 0; start < len(keys); start += batchSize {
+		end := min(start+batchSize, len(keys))
+		if err := rt.expire(ctx, keys[start:end], "", expiration); err != nil {
+			for i := start; i < end; i++ {
+				errs[i] = wrapErr(err, "ExpireMany", "key", keys[i])
+			}
+		}
+	}
+	return errs
+}
+
+func (rt *RouteTable) LoadMany(ctx context.Context, keys []string) ([]string, []error) {
+	addrs := make([]string, len(keys))
+	errs := make([]error, len(keys))
+
+	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
+	defer cancel()
+
+	now := nowMilli()
+	for start := 0; start < len(keys); start += batchSize {
+		batch := keys[start:min(start+batchSize, len(keys))]
+
+		var rows []Route
+		err := alive(rt.session(ctx), now).Where("route_key IN ?", batch).Find(&rows).Error
+		values := make(map[string]string, len(rows))
+		for _, r := range rows {
+			values[r.Key] = r.Value
+		}
+
+		for i, key := range batch {
+			if err != nil {
+				errs[start+i] = wrapErr(err, "LoadMany", "key", key)
+				continue
+			}
+			v, ok := values[key]
+			if !ok {
+				errs[start+i] = notFound("LoadMany", "key", key)
+				continue
+			}
+			addrs[start+i] = v
+		}
+	}
+	return addrs, errs
+}
+
+func (rt *RouteTable) SetMany(ctx context.Context, keys []string, addrs []string, dur time.Duration) []error {
+	errs := make([]error, len(keys))
+	if len(keys) != len(addrs) || dur <= 0 {
+		for i, key := range keys {
+			errs[i] = wrapErr(errors.Errorf("invalid arguments. addrs=%d dur=%s", len(addrs), dur), "SetMany", "key", key)
+		}
+		return errs
+	}
+
+	for i, key := range keys {
+		errs[i] = rt.Set(ctx, key, addrs[i], dur)
+	}
+	return errs
+}
+
+func (rt *RouteTable) DelMany(ctx context.Context, keys []string) []error {
+	errs := make([]error, len(keys))
+
+	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
+	defer cancel()
+
+	for start := 0; start < len(keys); start += batchSize {
+		batch := keys[start:min(start+batchSize, len(keys))]
+		if err := rt.session(ctx).Where("route_key IN ?", batch).Delete(&Route{}).Error; err != nil {
+			for i, key := range batch {
+				errs[start+i] = wrapErr(err, "DelMany", "key", key)
+			}
+		}
+	}
+	return errs
+}
+
+// RenewMany resets the expiration of the keys owned by the addr,
+// ErrRouteTableNotFound is returned for the keys missing or owned by another addr.
+func (rt *RouteTable) RenewMany(ctx context.Context, keys []string, addr string, dur time.Duration) []error {
+	errs := make([]error, len(keys))
+	if dur <= 0 {
+		for i, key := range keys {
+			errs[i] = wrapErr(errors.Errorf("invalid expire time %s", dur), "RenewMany", "key", key)
+		}
+		return errs
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
+	defer cancel()
+
+	addr = routetable.AddrOf(addr)
+	now := nowMilli()
+	for start := 0; start < len(keys); start += batchSize {
+		batch := keys[start:min(start+batchSize, len(keys))]
+
+		var owned []string
+		err := alive(rt.session(ctx), now).Where("route_key IN ? AND addr = ?", batch, addr).Pluck("route_key", &owned).Error
+		if err == nil && len(owned) > 0 {
+			err = rt.expire(ctx, owned, addr, dur)
+		}
+
+		ownedSet := make(map[string]struct{}, len(owned))
+		for _, key := range owned {
+			ownedSet[key] = struct{}{}
+		}
+		for i, key := range batch {
+			if err != nil {
+				errs[start+i] = wrapErr(err, "RenewMany", "key", key, "addr", addr)
+				continue
+			}
+			if _, ok := ownedSet[key]; !ok {
+				errs[start+i] = notFound("RenewMany", "key", key, "addr", addr)
+			}
+		}
+	}
+	return errs
+}
+
+// get returns the alive row, nil if not exists
+func (rt *RouteTable) get(ctx context.Context, key string) (*Route, error) {
+	var rows []Route
+	if err := alive(rt.session(ctx), nowMilli()).Where("route_key = ?", key).Limit(1).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	return &rows[0], nil
+}
+
+// put writes the value if cond accepts the current alive row, the nil cond accepts any row.
+// The write is retried when the row is changed concurrently. It returns the previous alive row,
+// whether the value is written and the new generation.
+// The generation is max(the last generation + 1, the current time in microseconds), so it keeps increasing after the row is deleted.
+func (rt *RouteTable) put(ctx context.Context, key string, value string, dur time.Duration, cond func(prev *Route) bool) (*Route, bool, int64, error) {
+	for range maxRetries {
+		var rows []Route
+		if err := rt.session(ctx).Where("route_key = ?", key).Limit(1).Find(&rows).Error; err != nil {
+			return nil, false, 0, err
+		}
+
+		var row, prev *Route
+		if len(rows) > 0 {
+			row = &rows[0]
+			if row.alive(nowMilli()) {
+				prev = row
+			}
+		}
+		if cond != nil && !cond(prev) {
+			return prev, false, 0, nil
+		}
+
+		gen := time.Now().UnixMicro()
+		if row != nil && gen <= row.Version {
+			gen = row.Version + 1
+		}
+		next := Route{Key: key, Addr: routetable.AddrOf(value), Value: value, ExpiresAt: expiresAt(dur), Version: gen}
+
+		var result *gorm.DB
+		if row == nil {
+			result = rt.session(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&next)
+		} else {
+			result = rt.session(ctx).Where("route_key = ? AND version = ?", key, row.Version).
+				Select("addr", "value", "expires_at", "version").Updates(&next)
+		}
+		if result.Error != nil {
+			return nil, false, 0, result.Error
+		}
+		if result.RowsAffected == 1 {
+			return prev, true, gen, nil
+		}
+	}
+	return nil, false, 0, errors.Errorf("too many conflicts")
+}
+
+// expire sets the expiration of the alive rows, the empty addr matches any address
+func (rt *RouteTable) expire(ctx context.Context, keys []string, addr string, dur time.Duration) error {
+	db := alive(rt.session(ctx), nowMilli()).Where("route_key IN ?", keys)
+	if addr != "" {
+		db = db.Where("addr = ?", addr)
+	}
+	return db.Update("expires_at", expiresAt(dur)).Error
+}
+
+func (rt *RouteTable) sweepLoop(ctx context.Context) {
+	defer rt.wg.Done()
+
+	ticker := time.NewTicker(rt.sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := rt.Sweep(ctx); err != nil {
+				log.Errorf("%s sweep failed. table=%s err=%+v", errPrefix, rt.table, err)
+			}
+		}
+	}
+}
+
+// Sweep removes the expired rows, returns the count of the removed rows
+func (rt *RouteTable) Sweep(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
+	defer cancel()
+
+	result := rt.session(ctx).Where("expires_at > 0 AND expires_at <= ?", nowMilli()).Delete(&Route{})
+	if result.Error != nil {
+		return 0, wrapErr(result.Error, "Sweep")
+	}
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,143 @@
+package sql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	verrors "github.com/vulcan-frame/vulcan-pkg-app/errors"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable"
+	"github.com/vulcan-frame/vulcan-pkg-app/router/routetable/routetabletest"
+	"gorm.io/driver/sqlite"
+)
+
+func newTestRouteTable(t *testing.T, opts ...Option) *RouteTable {
+	db, err := Open(sqlite.Open("file::memory:"), log.NewHelper(log.DefaultLogger))
+	require.NoError(t, err)
+	// the memory database is per connection
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+
+	rt, err := NewRouteTable(db, opts...)
+	require.NoError(t, err)
+	t.Cleanup(rt.Close)
+	return rt
+}
+
+func TestRouteTable(t *testing.T) {
+	ctx := context.Background()
+	rt := newTestRouteTable(t)
+
+	_, err := rt.Load(ctx, "r_1")
+	assert.ErrorIs(t, err, verrors.ErrRouteTableNotFound)
+
+	ok, result, gen1, err := rt.SetNxGen(ctx, "r_1", "a", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "a", result)
+
+	ok, result, gen, err := rt.SetNxGen(ctx, "r_1", "b", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, "a", result)
+	assert.Equal(t, gen1, gen)
+
+	old, gen2, err := rt.GetSetGen(ctx, "r_1", "b", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "a", old)
+	assert.Greater(t, gen2, gen1)
+
+	ok, _, err = rt.CompareAndSwap(ctx, "r_1", gen1, "c", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, gen3, err := rt.CompareAndSwap(ctx, "r_1", gen2, "c", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	_, err = rt.GetSet(ctx, "r_2", "c", time.Minute)
+	assert.ErrorIs(t, err, verrors.ErrRouteTableNotFound)
+
+	keys, next, err := rt.ListByAddr(ctx, "c", "r_", "", 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"r_1"}, keys)
+	assert.Equal(t, "r_1", next, "the cursor is the last key")
+	keys, _, err = rt.ListByAddr(ctx, "c", "r_", next, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"r_2"}, keys)
+
+	require.NoError(t, rt.DelIfSame(ctx, "r_1", "a"))
+	addr, err := rt.Load(ctx, "r_1")
+	require.NoError(t, err)
+	assert.Equal(t, "c", addr)
+	require.NoError(t, rt.DelIfSame(ctx, "r_1", "c"))
+	_, err = rt.Load(ctx, "r_1")
+	assert.ErrorIs(t, err, verrors.ErrRouteTableNotFound)
+
+	// the generation keeps increasing after the route is deleted
+	ok, gen4, err := rt.CompareAndSwap(ctx, "r_1", 0, "d", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Greater(t, gen4, gen3)
+
+	errs := rt.SetMany(ctx, []string{"r_3", "r_4"}, []string{"d", "d"}, time.Minute)
+	assert.Equal(t, []error{nil, nil}, errs)
+	addrs, errs := rt.LoadMany(ctx, []string{"r_3", "r_5"})
+	assert.Equal(t, "d", addrs[0])
+	assert.NoError(t, errs[0])
+	assert.ErrorIs(t, errs[1], verrors.ErrRouteTableNotFound)
+
+	var scanned []string
+	require.NoError(t, rt.Scan(ctx, "r_", func(keys []string) error {
+		scanned = append(scanned, keys...)
+		return nil
+	}))
+	assert.Equal(t, []string{"r_1", "r_2", "r_3", "r_4"}, scanned)
+
+	errs = rt.DelMany(ctx, []string{"r_3", "r_4"})
+	assert.Equal(t, []error{nil, nil}, errs)
+	_, err = rt.Load(ctx, "r_3")
+	assert.ErrorIs(t, err, verrors.ErrRouteTableNotFound)
+}
+
+func TestRouteTable_Expire(t *testing.T) {
+	ctx := context.Background()
+	rt := newTestRouteTable(t, WithSweepInterval(time.Millisecond*50))
+
+	require.NoError(t, rt.Set(ctx, "r_1", "a", time.Millisecond*100))
+	require.NoError(t, rt.Set(ctx, "r_2", "a", time.Millisecond*100))
+	require.NoError(t, rt.Set(ctx, "r_3", "b", time.Millisecond*100))
+
+	errs := rt.RenewMany(ctx, []string{"r_1", "r_3"}, "a", time.Minute)
+	assert.NoError(t, errs[0])
+	assert.ErrorIs(t, errs[1], verrors.ErrRouteTableNotFound)
+
+	_, err := rt.LoadAndExpire(ctx, "r_3", time.Minute)
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 200)
+
+	_, err = rt.Load(ctx, "r_2")
+	assert.ErrorIs(t, err, verrors.ErrRouteTableNotFound)
+	_, err = rt.Load(ctx, "r_1")
+	assert.NoError(t, err)
+	_, err = rt.Load(ctx, "r_3")
+	assert.NoError(t, err)
+
+	var count int64
+	require.NoError(t, rt.session(ctx).Where("route_key = ?", "r_2").Count(&count).Error)
+	assert.Equal(t, int64(0), count, "the expired row is swept")
+
+	require.NoError(t, rt.Expire(ctx, "r_1", 0))
+	_, err = rt.Load(ctx, "r_1")
+	assert.ErrorIs(t, err, verrors.ErrRouteTableNotFound)
+}
+
+func TestConformance(t *testing.T) {
+	routetabletest.RunConformance(t, func(t *testing.T) routetable.RouteTableData {
+		return newTestRouteTable(t, WithSweepInterval(time.Millisecond*50))
+	})
+}